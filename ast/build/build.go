@@ -0,0 +1,239 @@
+package build
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// operators maps each operator to the type of its token.
+var operators = map[string]token.TokenType{
+	"=":  token.ASSIGN,
+	"+":  token.PLUS,
+	"-":  token.MINUS,
+	"!":  token.BANG,
+	"*":  token.ASTERISK,
+	"/":  token.SLASH,
+	"<":  token.LT,
+	">":  token.GT,
+	"==": token.EQ,
+	"!=": token.NOT_EQ,
+}
+
+// Program returns a Program consisting of the specified statements.
+func Program(stmts ...ast.Statement) *ast.Program {
+	if stmts == nil {
+		stmts = []ast.Statement{}
+	}
+	return &ast.Program{Statements: stmts}
+}
+
+// Let returns a let statement binding value to the specified name.
+func Let(name string, value ast.Expression) *ast.LetStatement {
+	return &ast.LetStatement{
+		Token: keyword("let"),
+		Name:  Ident(name),
+		Value: value,
+	}
+}
+
+// Return returns a return statement returning value.
+func Return(value ast.Expression) *ast.ReturnStatement {
+	return &ast.ReturnStatement{
+		Token:       keyword("return"),
+		ReturnValue: value,
+	}
+}
+
+// ExprStmt returns an expression statement wrapping expr.
+func ExprStmt(expr ast.Expression) *ast.ExpressionStatement {
+	return &ast.ExpressionStatement{Token: firstToken(expr), Expression: expr}
+}
+
+// Ident returns an identifier with the specified name. It panics if name is
+// a keyword.
+func Ident(name string) *ast.Identifier {
+	if t := token.LookupIdent(name); t != token.IDENT {
+		panic(fmt.Sprintf("build: %q is a keyword, not an identifier", name))
+	}
+	return &ast.Identifier{
+		Token: token.Token{Type: token.IDENT, Literal: name},
+		Value: name,
+	}
+}
+
+// Int returns an integer literal with the specified value.
+func Int(value int64) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{
+		Token: token.Token{Type: token.INT,
+			Literal: strconv.FormatInt(value, 10)},
+		Value: value,
+	}
+}
+
+// Bool returns a boolean literal with the specified value.
+func Bool(value bool) *ast.Boolean {
+	return &ast.Boolean{Token: keyword(strconv.FormatBool(value)), Value: value}
+}
+
+// If returns an if expression. The alternative may be nil, for an if
+// expression with no else branch.
+func If(condition ast.Expression, consequence,
+	alternative *ast.BlockStatement) *ast.IfExpression {
+	return &ast.IfExpression{
+		Token:       keyword("if"),
+		Condition:   condition,
+		Consequence: consequence,
+		Alternative: alternative,
+	}
+}
+
+// Block returns a block statement consisting of the specified statements.
+func Block(stmts ...ast.Statement) *ast.BlockStatement {
+	if stmts == nil {
+		stmts = []ast.Statement{}
+	}
+	return &ast.BlockStatement{
+		Token:      token.Token{Type: token.LBRACE, Literal: "{"},
+		Statements: stmts,
+	}
+}
+
+// Fn returns a function literal with the specified parameter names and body.
+func Fn(params []string, body *ast.BlockStatement) *ast.FunctionLiteral {
+	idents := []*ast.Identifier{}
+	for _, p := range params {
+		idents = append(idents, Ident(p))
+	}
+	return &ast.FunctionLiteral{
+		Token:      keyword("fn"),
+		Parameters: idents,
+		Body:       body,
+	}
+}
+
+// Call returns a call expression calling function with the specified
+// arguments.
+func Call(function ast.Expression, args ...ast.Expression) *ast.CallExpression {
+	if args == nil {
+		args = []ast.Expression{}
+	}
+	return &ast.CallExpression{
+		Token:     token.Token{Type: token.LPAREN, Literal: "("},
+		Function:  function,
+		Arguments: args,
+	}
+}
+
+// Str returns a string literal with the specified value.
+func Str(value string) *ast.StringLiteral {
+	return &ast.StringLiteral{
+		Token: token.Token{Type: token.STRING, Literal: value},
+		Value: value,
+	}
+}
+
+// Hash returns a hash literal with the specified pairs.
+func Hash(pairs ...*ast.HashPair) *ast.HashLiteral {
+	if pairs == nil {
+		pairs = []*ast.HashPair{}
+	}
+	return &ast.HashLiteral{
+		Token: token.Token{Type: token.LBRACE, Literal: "{"},
+		Pairs: pairs,
+	}
+}
+
+// Pair returns a key-value pair for a hash literal.
+func Pair(key, value ast.Expression) *ast.HashPair {
+	return &ast.HashPair{Key: key, Value: value}
+}
+
+// Array returns an array literal with the specified elements.
+func Array(elements ...ast.Expression) *ast.ArrayLiteral {
+	if elements == nil {
+		elements = []ast.Expression{}
+	}
+	return &ast.ArrayLiteral{
+		Token:    token.Token{Type: token.LBRACKET, Literal: "["},
+		Elements: elements,
+	}
+}
+
+// Index returns an index expression indexing left by index.
+func Index(left, index ast.Expression) *ast.IndexExpression {
+	return &ast.IndexExpression{
+		Token: token.Token{Type: token.LBRACKET, Literal: "["},
+		Left:  left,
+		Index: index,
+	}
+}
+
+// Prefix returns a prefix expression applying operator to right. It panics if
+// operator is not an operator.
+func Prefix(operator string, right ast.Expression) *ast.PrefixExpression {
+	return &ast.PrefixExpression{
+		Token:    operatorToken(operator),
+		Operator: operator,
+		Right:    right,
+	}
+}
+
+// Infix returns an infix expression applying operator to left and right. It
+// panics if operator is not an operator.
+func Infix(left ast.Expression, operator string,
+	right ast.Expression) *ast.InfixExpression {
+	return &ast.InfixExpression{
+		Token:    operatorToken(operator),
+		Left:     left,
+		Operator: operator,
+		Right:    right,
+	}
+}
+
+func keyword(literal string) token.Token {
+	return token.Token{Type: token.LookupIdent(literal), Literal: literal}
+}
+
+func operatorToken(operator string) token.Token {
+	t, ok := operators[operator]
+	if !ok {
+		panic(fmt.Sprintf("build: unknown operator %q", operator))
+	}
+	return token.Token{Type: t, Literal: operator}
+}
+
+// firstToken returns the token an expression starts with, which is the token
+// the parser gives to the statement containing it.
+func firstToken(expr ast.Expression) token.Token {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		return firstToken(expr.Left)
+	case *ast.CallExpression:
+		return firstToken(expr.Function)
+	case *ast.IndexExpression:
+		return firstToken(expr.Left)
+	case *ast.Identifier:
+		return expr.Token
+	case *ast.IntegerLiteral:
+		return expr.Token
+	case *ast.PrefixExpression:
+		return expr.Token
+	case *ast.Boolean:
+		return expr.Token
+	case *ast.IfExpression:
+		return expr.Token
+	case *ast.FunctionLiteral:
+		return expr.Token
+	case *ast.ArrayLiteral:
+		return expr.Token
+	case *ast.StringLiteral:
+		return expr.Token
+	case *ast.HashLiteral:
+		return expr.Token
+	default:
+		return token.Token{}
+	}
+}
@@ -0,0 +1,97 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+func TestBuildMatchesParser(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected *ast.Program
+	}{
+		{"x;", Program(ExprStmt(Ident("x")))},
+		{"5;", Program(ExprStmt(Int(5)))},
+		{"-a * b;", Program(ExprStmt(
+			Infix(Prefix("-", Ident("a")), "*", Ident("b"))))},
+		{"1 + 2 == !y;", Program(ExprStmt(
+			Infix(Infix(Int(1), "+", Int(2)), "==", Prefix("!", Ident("y")))))},
+		{"a != b; c < d > e;", Program(
+			ExprStmt(Infix(Ident("a"), "!=", Ident("b"))),
+			ExprStmt(Infix(Infix(Ident("c"), "<", Ident("d")), ">", Ident("e"))),
+		)},
+		{"let t = !true == false;", Program(
+			Let("t", Infix(Prefix("!", Bool(true)), "==", Bool(false))))},
+		{"if (x) { y } else { return z; }", Program(ExprStmt(
+			If(Ident("x"), Block(ExprStmt(Ident("y"))), Block(Return(Ident("z"))))))},
+		{"let add = fn(a, b) { a + b }; add(1, 2)(3);", Program(
+			Let("add", Fn([]string{"a", "b"},
+				Block(ExprStmt(Infix(Ident("a"), "+", Ident("b")))))),
+			ExprStmt(Call(Call(Ident("add"), Int(1), Int(2)), Int(3))),
+		)},
+		{"fn() {}();", Program(ExprStmt(Call(Fn(nil, Block()))))},
+		{`let h = {"a": 1, b: "c"}; {};`, Program(
+			Let("h", Hash(Pair(Str("a"), Int(1)), Pair(Ident("b"), Str("c")))),
+			ExprStmt(Hash()),
+		)},
+		{"[1, x][0]; [];", Program(
+			ExprStmt(Index(Array(Int(1), Ident("x")), Int(0))),
+			ExprStmt(Array()),
+		)},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+		opts := ast.CompareOptions{IgnorePositions: true}
+		if d := ast.Diff(tt.expected, program, opts); d != "" {
+			t.Errorf("built tree differs from parsed %q at %s", tt.input, d)
+		}
+	}
+}
+
+func TestBuildString(t *testing.T) {
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{Let("x", Infix(Int(1), "+", Ident("y"))), "let x = (1 + y);"},
+		{Return(Prefix("-", Int(7))), "return (-7);"},
+		{Program(Let("a", Int(1)), Return(Ident("a"))), "let a = 1;return a;"},
+	}
+
+	for _, tt := range tests {
+		if tt.node.String() != tt.expected {
+			t.Errorf("String() wrong. expected=%q, got=%q", tt.expected,
+				tt.node.String())
+		}
+	}
+}
+
+func TestBuildPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"keyword identifier", func() { Ident("let") }},
+		{"unknown prefix operator", func() { Prefix("~", Int(1)) }},
+		{"unknown infix operator", func() { Infix(Int(1), "%", Int(2)) }},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", tt.name)
+				}
+			}()
+			tt.fn()
+		}()
+	}
+}
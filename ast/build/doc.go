@@ -0,0 +1,11 @@
+// Package build constructs abstract syntax trees for Monkey programs without
+// going through the lexer and parser. It fills in tokens consistent with the
+// ones the parser would produce, so the nodes it returns have the same
+// TokenLiteral and String values as parsed nodes. (Tokens carry no position,
+// since built nodes don't come from program text.)
+//
+// For example, the following builds the program "let x = (1 + y);":
+//  prog := build.Program(
+//      build.Let("x", build.Infix(build.Int(1), "+", build.Ident("y"))),
+//  )
+package build
@@ -0,0 +1,48 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/adamvinueza/monkey/token"
+)
+
+// Comment represents a single "//" comment.
+type Comment struct {
+	Token token.Token // the token.COMMENT token
+	Text  string      // the text of the comment, including the "//"
+}
+
+// CommentGroup represents a sequence of comments on consecutive lines, with no
+// other tokens between them.
+type CommentGroup struct {
+	List []*Comment
+}
+
+// Text returns the text of the comment group without the comment markers,
+// one line per comment. For example, the group
+//  // add returns
+//  //   the sum of x and y.
+// has the text "add returns\n  the sum of x and y.\n". A nil CommentGroup has
+// empty text.
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+	var lines []string
+	for _, c := range g.List {
+		line := strings.TrimPrefix(c.Text, "//")
+		line = strings.TrimPrefix(line, " ")
+		lines = append(lines, strings.TrimRight(line, " \t\r"))
+	}
+	// drop leading and trailing blank lines
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,128 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/adamvinueza/monkey/token"
+)
+
+// CompareOptions controls which parts of a Node are considered by Equal and
+// Diff.
+type CompareOptions struct {
+	// IgnoreTokens causes the Token fields of nodes to be skipped entirely,
+	// so that only the structure and values of the nodes are compared.
+	IgnoreTokens bool
+	// IgnorePositions causes the positions of tokens to be skipped, while
+	// their types and literals are still compared.
+	IgnorePositions bool
+	// IgnoreComments causes comments attached to nodes to be skipped.
+	IgnoreComments bool
+}
+
+var (
+	tokenType        = reflect.TypeOf(token.Token{})
+	commentGroupType = reflect.TypeOf(&CommentGroup{})
+)
+
+// Equal reports whether a and b are structurally equal abstract syntax trees,
+// subject to opts.
+func Equal(a, b Node, opts CompareOptions) bool {
+	return Diff(a, b, opts) == ""
+}
+
+// Diff compares a and b and describes the first mismatch found, in the form
+// "PATH: DESCRIPTION", where PATH is the sequence of fields leading from the
+// root to the mismatching node. For example:
+//
+//	.Statements[0].Value.Right: *ast.Identifier != *ast.IntegerLiteral
+//
+// Diff returns the empty string if a and b are equal.
+func Diff(a, b Node, opts CompareOptions) string {
+	return diff("", reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem(),
+		opts)
+}
+
+func diff(path string, a, b reflect.Value, opts CompareOptions) string {
+	switch a.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if a.Type() == commentGroupType && opts.IgnoreComments {
+			return ""
+		}
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() && b.IsNil() {
+				return ""
+			}
+			return mismatch(path, describe(a), describe(b))
+		}
+		if a.Kind() == reflect.Interface && a.Elem().Type() != b.Elem().Type() {
+			return mismatch(path, a.Elem().Type(), b.Elem().Type())
+		}
+		return diff(path, a.Elem(), b.Elem(), opts)
+	case reflect.Struct:
+		if a.Type() == tokenType {
+			return diffTokens(path, a.Interface().(token.Token),
+				b.Interface().(token.Token), opts)
+		}
+		for i := 0; i < a.NumField(); i++ {
+			name := a.Type().Field(i).Name
+			if d := diff(path+"."+name, a.Field(i), b.Field(i), opts); d != "" {
+				return d
+			}
+		}
+		return ""
+	case reflect.Slice:
+		for i := 0; i < a.Len() && i < b.Len(); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			if d := diff(elemPath, a.Index(i), b.Index(i), opts); d != "" {
+				return d
+			}
+		}
+		if a.Len() != b.Len() {
+			return fmt.Sprintf("%s: length %d != %d", pathOrRoot(path),
+				a.Len(), b.Len())
+		}
+		return ""
+	default:
+		if a.Interface() != b.Interface() {
+			return mismatch(path, fmt.Sprintf("%#v", a.Interface()),
+				fmt.Sprintf("%#v", b.Interface()))
+		}
+		return ""
+	}
+}
+
+func diffTokens(path string, a, b token.Token, opts CompareOptions) string {
+	if opts.IgnoreTokens {
+		return ""
+	}
+	if opts.IgnorePositions {
+		a.Pos, b.Pos = token.Position{}, token.Position{}
+	}
+	if a != b {
+		return mismatch(path, fmt.Sprintf("{%s %q %s}", a.Type, a.Literal, a.Pos),
+			fmt.Sprintf("{%s %q %s}", b.Type, b.Literal, b.Pos))
+	}
+	return ""
+}
+
+func describe(v reflect.Value) interface{} {
+	if v.IsNil() {
+		return "nil"
+	}
+	if v.Kind() == reflect.Interface {
+		return v.Elem().Type()
+	}
+	return v.Type()
+}
+
+func mismatch(path string, a, b interface{}) string {
+	return fmt.Sprintf("%s: %v != %v", pathOrRoot(path), a, b)
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
@@ -0,0 +1,124 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/adamvinueza/monkey/token"
+)
+
+func TestEqualAndDiff(t *testing.T) {
+	tests := []struct {
+		a, b         Node
+		opts         CompareOptions
+		expectedDiff string
+	}{
+		{
+			letStatement("x", intLiteral(1, 1, 9), 1),
+			letStatement("x", intLiteral(1, 1, 9), 1),
+			CompareOptions{},
+			"",
+		},
+		{
+			letStatement("x", intLiteral(1, 1, 9), 1),
+			letStatement("x", intLiteral(1, 2, 9), 2),
+			CompareOptions{},
+			`.Statements[0].Token: {LET "let" 1:1} != {LET "let" 2:1}`,
+		},
+		{
+			letStatement("x", intLiteral(1, 1, 9), 1),
+			letStatement("x", intLiteral(1, 2, 9), 2),
+			CompareOptions{IgnorePositions: true},
+			"",
+		},
+		{
+			letStatement("x", intLiteral(1, 1, 9), 1),
+			letStatement("y", intLiteral(1, 1, 9), 1),
+			CompareOptions{IgnorePositions: true},
+			`.Statements[0].Name.Token: {IDENT "x" -} != {IDENT "y" -}`,
+		},
+		{
+			letStatement("x", intLiteral(1, 1, 9), 1),
+			letStatement("y", intLiteral(1, 1, 9), 1),
+			CompareOptions{IgnoreTokens: true},
+			`.Statements[0].Name.Value: "x" != "y"`,
+		},
+		{
+			letStatement("x", intLiteral(1, 1, 9), 1),
+			letStatement("x", &Identifier{Value: "y"}, 1),
+			CompareOptions{IgnoreTokens: true},
+			".Statements[0].Value: *ast.IntegerLiteral != *ast.Identifier",
+		},
+		{
+			letStatement("x", intLiteral(1, 1, 9), 1),
+			letStatement("x", nil, 1),
+			CompareOptions{IgnoreTokens: true},
+			".Statements[0].Value: *ast.IntegerLiteral != nil",
+		},
+		{
+			letStatement("x", intLiteral(1, 1, 9), 1),
+			&Program{},
+			CompareOptions{},
+			".Statements: length 1 != 0",
+		},
+		{
+			&Program{},
+			&Identifier{Value: "x"},
+			CompareOptions{},
+			"(root): *ast.Program != *ast.Identifier",
+		},
+	}
+
+	for i, tt := range tests {
+		d := Diff(tt.a, tt.b, tt.opts)
+		if d != tt.expectedDiff {
+			t.Errorf("tests[%d] - Diff wrong. expected=%q, got=%q", i,
+				tt.expectedDiff, d)
+		}
+		expectedEqual := tt.expectedDiff == ""
+		if eq := Equal(tt.a, tt.b, tt.opts); eq != expectedEqual {
+			t.Errorf("tests[%d] - Equal wrong. expected=%t, got=%t", i,
+				expectedEqual, eq)
+		}
+	}
+}
+
+func letStatement(name string, value Expression, line int) *Program {
+	return &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let",
+					Pos: token.Position{Line: line, Column: 1}},
+				Name: &Identifier{
+					Token: token.Token{Type: token.IDENT, Literal: name,
+						Pos: token.Position{Line: line, Column: 5}},
+					Value: name,
+				},
+				Value: value,
+			},
+		},
+	}
+}
+
+func intLiteral(value int64, line, column int) *IntegerLiteral {
+	return &IntegerLiteral{
+		Token: token.Token{Type: token.INT, Literal: "1",
+			Pos: token.Position{Line: line, Column: column}},
+		Value: value,
+	}
+}
+
+func TestDiffIgnoreComments(t *testing.T) {
+	a := letStatement("x", intLiteral(1, 2, 9), 2)
+	b := letStatement("x", intLiteral(1, 2, 9), 2)
+	a.Statements[0].(*LetStatement).Doc = &CommentGroup{
+		List: []*Comment{{Text: "// x is one"}},
+	}
+
+	expected := ".Statements[0].Doc: *ast.CommentGroup != nil"
+	if d := Diff(a, b, CompareOptions{}); d != expected {
+		t.Errorf("Diff wrong. expected=%q, got=%q", expected, d)
+	}
+	if d := Diff(a, b, CompareOptions{IgnoreComments: true}); d != "" {
+		t.Errorf("Diff wrong. expected=%q, got=%q", "", d)
+	}
+}
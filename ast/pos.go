@@ -0,0 +1,58 @@
+package ast
+
+import "github.com/adamvinueza/monkey/token"
+
+// Pos returns the position of the first token of node in the program text.
+// This differs from the position of the node's own token for nodes whose token
+// is an operator, such as the + in "x + y", which comes after their first
+// token. Pos returns the zero Position for nodes without positions, such as
+// nodes that were not produced by a parser.
+func Pos(node Node) token.Position {
+	switch node := node.(type) {
+	case *Program:
+		if len(node.Statements) > 0 {
+			return Pos(node.Statements[0])
+		}
+	case *LetStatement:
+		return node.Token.Pos
+	case *ReturnStatement:
+		return node.Token.Pos
+	case *ExpressionStatement:
+		return node.Token.Pos
+	case *BlockStatement:
+		return node.Token.Pos
+	case *Identifier:
+		return node.Token.Pos
+	case *IntegerLiteral:
+		return node.Token.Pos
+	case *Boolean:
+		return node.Token.Pos
+	case *PrefixExpression:
+		return node.Token.Pos
+	case *InfixExpression:
+		return Pos(node.Left)
+	case *IfExpression:
+		return node.Token.Pos
+	case *FunctionLiteral:
+		return node.Token.Pos
+	case *CallExpression:
+		return Pos(node.Function)
+	case *ArrayLiteral:
+		return node.Token.Pos
+	case *IndexExpression:
+		return Pos(node.Left)
+	case *StringLiteral:
+		return node.Token.Pos
+	case *HashLiteral:
+		return node.Token.Pos
+	case *TypeName:
+		return node.Token.Pos
+	case *ArrayType:
+		return node.Token.Pos
+	case *HashType:
+		return node.Token.Pos
+	case *FunctionType:
+		return node.Token.Pos
+	}
+	return token.Position{}
+}
@@ -0,0 +1,43 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/adamvinueza/monkey/token"
+)
+
+func TestPos(t *testing.T) {
+	pos := func(line, column int) token.Position {
+		return token.Position{Line: line, Column: column}
+	}
+	x := &Identifier{Token: token.Token{Pos: pos(1, 5)}, Value: "x"}
+	one := &IntegerLiteral{Token: token.Token{Pos: pos(2, 1)}, Value: 1}
+
+	tests := []struct {
+		node     Node
+		expected token.Position
+	}{
+		{x, pos(1, 5)},
+		{&InfixExpression{Token: token.Token{Pos: pos(1, 7)}, Left: x,
+			Right: one}, pos(1, 5)},
+		{&CallExpression{Token: token.Token{Pos: pos(1, 6)}, Function: x},
+			pos(1, 5)},
+		{&IndexExpression{Token: token.Token{Pos: pos(1, 6)}, Left: x,
+			Index: one}, pos(1, 5)},
+		{&Program{Statements: []Statement{
+			&ExpressionStatement{Token: one.Token, Expression: one},
+		}}, pos(2, 1)},
+		{&ArrayType{Token: token.Token{Pos: pos(3, 8)},
+			Element: &TypeName{Token: token.Token{Pos: pos(3, 9)}, Name: "int"}},
+			pos(3, 8)},
+		{&Program{}, token.Position{}},
+		{nil, token.Position{}},
+	}
+
+	for i, tt := range tests {
+		if p := Pos(tt.node); p != tt.expected {
+			t.Errorf("tests[%d] - Pos wrong. expected=%s, got=%s", i,
+				tt.expected, p)
+		}
+	}
+}
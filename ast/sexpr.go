@@ -0,0 +1,131 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// SExpr returns a representation of the tree rooted at node as an
+// S-expression, which, unlike String, shows the kind of every node. For
+// example, the program "let x = 1 + y;" is represented as
+//  (program (let x (infix + (int 1) (ident y))))
+// A missing node is represented as "nil".
+func SExpr(node Node) string {
+	var out bytes.Buffer
+	writeSExpr(&out, node)
+	return out.String()
+}
+
+func writeSExpr(out *bytes.Buffer, node Node) {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		out.WriteString("nil")
+		return
+	}
+	switch node := node.(type) {
+	case *Program:
+		out.WriteString("(program")
+		for _, s := range node.Statements {
+			out.WriteString(" ")
+			writeSExpr(out, s)
+		}
+		out.WriteString(")")
+	case *LetStatement:
+		out.WriteString("(let ")
+		out.WriteString(node.Name.Value)
+		out.WriteString(" ")
+		if node.Type != nil {
+			writeSExpr(out, node.Type)
+			out.WriteString(" ")
+		}
+		writeSExpr(out, node.Value)
+		out.WriteString(")")
+	case *ReturnStatement:
+		writeList(out, "return", node.ReturnValue)
+	case *ExpressionStatement:
+		writeList(out, "expr", node.Expression)
+	case *BlockStatement:
+		out.WriteString("(block")
+		for _, s := range node.Statements {
+			out.WriteString(" ")
+			writeSExpr(out, s)
+		}
+		out.WriteString(")")
+	case *Identifier:
+		fmt.Fprintf(out, "(ident %s)", node.Value)
+	case *IntegerLiteral:
+		fmt.Fprintf(out, "(int %d)", node.Value)
+	case *Boolean:
+		fmt.Fprintf(out, "(bool %t)", node.Value)
+	case *IfExpression:
+		if node.Alternative == nil {
+			writeList(out, "if", node.Condition, node.Consequence)
+		} else {
+			writeList(out, "if", node.Condition, node.Consequence,
+				node.Alternative)
+		}
+	case *FunctionLiteral:
+		out.WriteString("(fn (")
+		for i, p := range node.Parameters {
+			if i > 0 {
+				out.WriteString(" ")
+			}
+			if i < len(node.ParameterTypes) && node.ParameterTypes[i] != nil {
+				out.WriteString("(" + p.Value + " ")
+				writeSExpr(out, node.ParameterTypes[i])
+				out.WriteString(")")
+			} else {
+				out.WriteString(p.Value)
+			}
+		}
+		out.WriteString(") ")
+		if node.ReturnType != nil {
+			writeSExpr(out, node.ReturnType)
+			out.WriteString(" ")
+		}
+		writeSExpr(out, node.Body)
+		out.WriteString(")")
+	case *CallExpression:
+		nodes := []Node{node.Function}
+		for _, a := range node.Arguments {
+			nodes = append(nodes, a)
+		}
+		writeList(out, "call", nodes...)
+	case *ArrayLiteral:
+		nodes := []Node{}
+		for _, e := range node.Elements {
+			nodes = append(nodes, e)
+		}
+		writeList(out, "array", nodes...)
+	case *IndexExpression:
+		writeList(out, "index", node.Left, node.Index)
+	case *StringLiteral:
+		fmt.Fprintf(out, "(string %q)", node.Value)
+	case *HashLiteral:
+		out.WriteString("(hash")
+		for _, pair := range node.Pairs {
+			out.WriteString(" ")
+			writeList(out, "pair", pair.Key, pair.Value)
+		}
+		out.WriteString(")")
+	case *PrefixExpression:
+		writeList(out, "prefix "+node.Operator, node.Right)
+	case *InfixExpression:
+		writeList(out, "infix "+node.Operator, node.Left, node.Right)
+	case Type:
+		fmt.Fprintf(out, "(type %s)", node)
+	default:
+		fmt.Fprintf(out, "(%T)", node)
+	}
+}
+
+// writeList writes an S-expression starting with head and followed by nodes.
+func writeList(out *bytes.Buffer, head string, nodes ...Node) {
+	out.WriteString("(")
+	out.WriteString(head)
+	for _, n := range nodes {
+		out.WriteString(" ")
+		writeSExpr(out, n)
+	}
+	out.WriteString(")")
+}
@@ -0,0 +1,94 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/adamvinueza/monkey/token"
+)
+
+func TestSExpr(t *testing.T) {
+	x := &Identifier{Value: "x"}
+	one := &IntegerLiteral{Value: 1}
+	tests := []struct {
+		node     Node
+		expected string
+	}{
+		{&Program{}, "(program)"},
+		{x, "(ident x)"},
+		{one, "(int 1)"},
+		{&PrefixExpression{Operator: "-", Right: one}, "(prefix - (int 1))"},
+		{
+			&InfixExpression{Left: one, Operator: "+", Right: x},
+			"(infix + (int 1) (ident x))",
+		},
+		{&ExpressionStatement{Expression: x}, "(expr (ident x))"},
+		{&ReturnStatement{ReturnValue: one}, "(return (int 1))"},
+		{&ReturnStatement{}, "(return nil)"},
+		{
+			&Program{Statements: []Statement{
+				&LetStatement{
+					Token: token.Token{Type: token.LET, Literal: "let"},
+					Name:  x,
+					Value: &InfixExpression{Left: one, Operator: "*", Right: x},
+				},
+				&ExpressionStatement{Expression: x},
+			}},
+			"(program (let x (infix * (int 1) (ident x))) (expr (ident x)))",
+		},
+		{(*LetStatement)(nil), "nil"},
+		{&Boolean{Value: true}, "(bool true)"},
+		{
+			&IfExpression{
+				Condition:   x,
+				Consequence: &BlockStatement{},
+			},
+			"(if (ident x) (block))",
+		},
+		{
+			&IfExpression{
+				Condition: x,
+				Consequence: &BlockStatement{Statements: []Statement{
+					&ExpressionStatement{Expression: one},
+				}},
+				Alternative: &BlockStatement{Statements: []Statement{
+					&ReturnStatement{ReturnValue: x},
+				}},
+			},
+			"(if (ident x) (block (expr (int 1))) (block (return (ident x))))",
+		},
+		{
+			&FunctionLiteral{
+				Parameters: []*Identifier{x, {Value: "y"}},
+				Body:       &BlockStatement{},
+			},
+			"(fn (x y) (block))",
+		},
+		{
+			&CallExpression{Function: x, Arguments: []Expression{one, x}},
+			"(call (ident x) (int 1) (ident x))",
+		},
+		{&ArrayLiteral{}, "(array)"},
+		{
+			&IndexExpression{
+				Left:  &ArrayLiteral{Elements: []Expression{one, x}},
+				Index: one,
+			},
+			"(index (array (int 1) (ident x)) (int 1))",
+		},
+		{&StringLiteral{Value: `say "hi"`}, `(string "say \"hi\"")`},
+		{
+			&HashLiteral{Pairs: []*HashPair{
+				{Key: &StringLiteral{Value: "a"}, Value: one},
+				{Key: x, Value: x},
+			}},
+			`(hash (pair (string "a") (int 1)) (pair (ident x) (ident x)))`,
+		},
+	}
+
+	for i, tt := range tests {
+		if s := SExpr(tt.node); s != tt.expected {
+			t.Errorf("tests[%d] - SExpr wrong. expected=%q, got=%q", i,
+				tt.expected, s)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/adamvinueza/monkey/token"
+)
+
+// Type is a type annotation, such as the "int" in "let x: int = 5;".
+// Annotations don't change what programs do; they are read by package
+// typecheck.
+type Type interface {
+	Node
+	typeNode()
+}
+
+// TypeName represents a type written as a name, such as "int" or "string".
+type TypeName struct {
+	Token token.Token // the token.IDENT token
+	Name  string
+}
+
+func (tn *TypeName) typeNode()            {}
+func (tn *TypeName) TokenLiteral() string { return tn.Token.Literal }
+func (tn *TypeName) String() string       { return tn.Name }
+
+// ArrayType represents the type of arrays of elements of the same type, such
+// as "[int]".
+type ArrayType struct {
+	Token   token.Token // the '[' token
+	Element Type
+}
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) String() string {
+	return "[" + at.Element.String() + "]"
+}
+
+// HashType represents the type of hashes whose keys and values each have the
+// same type, such as "{string: int}".
+type HashType struct {
+	Token token.Token // the '{' token
+	Key   Type
+	Value Type
+}
+
+func (ht *HashType) typeNode()            {}
+func (ht *HashType) TokenLiteral() string { return ht.Token.Literal }
+func (ht *HashType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+// FunctionType represents the type of functions, such as
+// "fn(int, int): bool".
+type FunctionType struct {
+	Token      token.Token // the 'fn' token
+	Parameters []Type
+	Result     Type
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ft.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(ft.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString("): ")
+	out.WriteString(ft.Result.String())
+
+	return out.String()
+}
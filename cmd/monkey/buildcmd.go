@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adamvinueza/monkey/gogen"
+	"github.com/adamvinueza/monkey/jsgen"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/optimize"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+const buildUsage = "build [-O] [-js] [-o file] file"
+
+var buildCommand = &command{name: "build", usage: buildUsage, run: runBuild}
+
+// runBuild translates the specified Monkey file to a Go program, or with -js a
+// JavaScript program, which writes the same output as "monkey run" does.
+func runBuild(args []string) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	output := flags.String("o", "",
+		"write the program to `file` instead of the source file with extension .go or .js")
+	optimized := flags.Bool("O", false, "optimize the program")
+	js := flags.Bool("js", false, "translate to JavaScript instead of Go")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: monkey %s\n", buildUsage)
+		return 2
+	}
+	path := flags.Arg(0)
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey build: %s\n", err)
+		return 1
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+		}
+		return 1
+	}
+	if *optimized {
+		program = optimize.Program(program)
+	}
+	translate, ext := gogen.Program, ".go"
+	if *js {
+		translate, ext = jsgen.Program, ".js"
+	}
+	data, err := translate(program, path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey build: %s: %s\n", path, err)
+		return 1
+	}
+
+	out := *output
+	if out == "" {
+		out = strings.TrimSuffix(path, filepath.Ext(path)) + ext
+	}
+	if err := ioutil.WriteFile(out, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey build: %s\n", err)
+		return 1
+	}
+	return 0
+}
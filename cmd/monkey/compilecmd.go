@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adamvinueza/monkey/compiler"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/optimize"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+const compileUsage = "compile [-O] [-o file] file"
+
+var compileCommand = &command{name: "compile", usage: compileUsage,
+	run: runCompile}
+
+// moduleExt is the extension of compiled module files.
+const moduleExt = ".mkc"
+
+// runCompile compiles the specified Monkey file to a compiled module, which
+// "monkey run" runs in the virtual machine.
+func runCompile(args []string) int {
+	flags := flag.NewFlagSet("compile", flag.ContinueOnError)
+	output := flags.String("o", "",
+		"write the module to `file` instead of the source file with extension "+
+			moduleExt)
+	optimized := flags.Bool("O", false, "optimize the program")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: monkey %s\n", compileUsage)
+		return 2
+	}
+	path := flags.Arg(0)
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey compile: %s\n", err)
+		return 1
+	}
+	b, err := compileSource(path, src, *optimized)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey compile: %s\n", err)
+		return 1
+	}
+	data, err := compiler.Marshal(b)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey compile: %s: %s\n", path, err)
+		return 1
+	}
+
+	out := *output
+	if out == "" {
+		out = strings.TrimSuffix(path, filepath.Ext(path)) + moduleExt
+	}
+	if err := ioutil.WriteFile(out, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey compile: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// compileSource parses and compiles src, read from path. If optimized is true,
+// the program is optimized before it is compiled, and its bytecode after.
+func compileSource(path string, src []byte,
+	optimized bool) (*compiler.Bytecode, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s: %s", path, strings.Join(p.Errors(), "; "))
+	}
+	if optimized {
+		program = optimize.Program(program)
+	}
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if optimized {
+		return compiler.Peephole(c.Bytecode()), nil
+	}
+	return c.Bytecode(), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/adamvinueza/monkey/compiler"
+)
+
+const disasmUsage = "disasm [-O] file"
+
+var disasmCommand = &command{name: "disasm", usage: disasmUsage,
+	run: runDisasm}
+
+// runDisasm writes a listing of the bytecode of the specified file, which is
+// either a Monkey file, which is compiled, or a compiled module. The listing
+// of a Monkey file shows the source line of each instruction.
+func runDisasm(args []string) int {
+	flags := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	optimized := flags.Bool("O", false,
+		"optimize the program, if it isn't compiled already")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: monkey %s\n", disasmUsage)
+		return 2
+	}
+	path := flags.Arg(0)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey disasm: %s\n", err)
+		return 1
+	}
+	var b *compiler.Bytecode
+	var src string
+	if compiler.IsModule(data) {
+		b, err = compiler.Unmarshal(data)
+		if err != nil {
+			err = fmt.Errorf("%s: %s", path, err)
+		}
+	} else {
+		src = string(data)
+		b, err = compileSource(path, data, *optimized)
+	}
+	if err == nil {
+		err = compiler.Disassemble(os.Stdout, b, src)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey disasm: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adamvinueza/monkey/doc"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+const docUsage = "doc [-html] [-title title] [-o file] file..."
+
+var docCommand = &command{name: "doc", usage: docUsage, run: runDoc}
+
+// runDoc writes documentation for the functions in the specified Monkey
+// files, as Markdown or as a standalone HTML page.
+func runDoc(args []string) int {
+	flags := flag.NewFlagSet("doc", flag.ContinueOnError)
+	asHTML := flags.Bool("html", false,
+		"write a standalone HTML page instead of Markdown")
+	title := flags.String("title", "Monkey documentation",
+		"the `title` of the HTML page")
+	output := flags.String("o", "", "write to `file` instead of standard output")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: monkey %s\n", docUsage)
+		return 2
+	}
+
+	var modules []*doc.Module
+	for _, path := range flags.Args() {
+		m, err := parseModule(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey doc: %s\n", err)
+			return 1
+		}
+		modules = append(modules, m)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey doc: %s\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var err error
+	if *asHTML {
+		err = doc.WriteHTML(w, *title, modules)
+	} else {
+		err = doc.WriteMarkdown(w, modules)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey doc: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseModule returns the documentation for the Monkey file at path, naming the
+// module after the file.
+func parseModule(path string) (*doc.Module, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s: %s", path, strings.Join(p.Errors(), "; "))
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return doc.New(name, program), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/adamvinueza/monkey/repl"
+)
+
+// command is a subcommand of the monkey tool, such as "monkey doc".
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int // returns the exit status
+}
+
+var commands = []*command{
+	buildCommand,
+	compileCommand,
+	disasmCommand,
+	docCommand,
+	runCommand,
+	testCommand,
+	vetCommand,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		startREPL()
+		return
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			os.Exit(c.run(os.Args[2:]))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "monkey: unknown command %q\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage:\n\tmonkey\t(starts the REPL)\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "\tmonkey %s\n", c.usage)
+	}
+}
+
+func startREPL() {
+	user, err := user.Current()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Hello %s! This is the Monkey programming language!\n",
+		user.Username)
+	fmt.Printf("Feel free to type in commands\n")
+	repl.Start(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/adamvinueza/monkey/compiler"
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/optimize"
+	"github.com/adamvinueza/monkey/parser"
+	"github.com/adamvinueza/monkey/regvm"
+	"github.com/adamvinueza/monkey/vm"
+)
+
+const runUsage = "run [-O] [-vm | -reg] file"
+
+var runCommand = &command{name: "run", usage: runUsage, run: runRun}
+
+// runRun evaluates the specified Monkey file, or runs the specified compiled
+// module in the virtual machine. If evaluation fails, it writes the error and
+// a trace of the active function calls to standard error.
+func runRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	useVM := flags.Bool("vm", false,
+		"compile the file and run it in the virtual machine")
+	useReg := flags.Bool("reg", false,
+		"compile the file and run it in the register machine")
+	optimized := flags.Bool("O", false, "optimize the program before running it")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: monkey %s\n", runUsage)
+		return 2
+	}
+	path := flags.Arg(0)
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey run: %s\n", err)
+		return 1
+	}
+	if compiler.IsModule(src) {
+		b, err := compiler.Unmarshal(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey run: %s: %s\n", path, err)
+			return 1
+		}
+		return runVM(path, b)
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+		}
+		return 1
+	}
+	if *optimized {
+		program = optimize.Program(program)
+	}
+
+	if *useVM && *useReg {
+		fmt.Fprintln(os.Stderr, "monkey run: -vm and -reg are exclusive")
+		return 2
+	}
+	if *useReg {
+		compiled, err := regvm.Compile(program)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			return 1
+		}
+		return runRegister(path, compiled)
+	}
+	if *useVM {
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			return 1
+		}
+		b := c.Bytecode()
+		if *optimized {
+			b = compiler.Peephole(b)
+		}
+		return runVM(path, b)
+	}
+	evaluated := evaluator.Eval(program, object.NewEnvironment())
+	if err, ok := evaluated.(*object.Error); ok {
+		fmt.Fprint(os.Stderr, err.Trace(path))
+		return 1
+	}
+	return 0
+}
+
+// runVM runs b, compiled from path, in the virtual machine.
+func runVM(path string, b *compiler.Bytecode) int {
+	machine := vm.New(b)
+	if err := machine.Run(); err != nil {
+		if rerr, ok := err.(*vm.RuntimeError); ok {
+			fmt.Fprint(os.Stderr, rerr.Err.Trace(path))
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// runRegister runs program, compiled from path, in the register machine.
+func runRegister(path string, program *regvm.Program) int {
+	machine := regvm.New(program)
+	if err := machine.Run(); err != nil {
+		if rerr, ok := err.(*vm.RuntimeError); ok {
+			fmt.Fprint(os.Stderr, rerr.Err.Trace(path))
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		}
+		return 1
+	}
+	return 0
+}
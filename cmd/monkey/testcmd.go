@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+const testUsage = "test [-v] [-run regexp] [-timeout d] [path...]"
+
+var testCommand = &command{name: "test", usage: testUsage, run: runTest}
+
+const (
+	testFileSuffix = "_test.mk" // ends the names of files holding tests
+	testPrefix     = "test_"    // starts the names of tests
+	// testTimeout is how long a test, or the evaluation of a file, may run
+	// by default.
+	testTimeout = 10 * time.Second
+)
+
+// runTest runs the tests in the specified Monkey files, and in the files
+// ending in "_test.mk" in the specified directories and their subdirectories,
+// by default the current directory. A test is a function without parameters
+// bound by a top-level let statement to a name starting with "test_", and it
+// fails if calling it fails, as when an assertion doesn't hold, or if it runs
+// longer than the timeout. runTest writes the position and error of each test
+// that fails, and whether each file passed. The exit status is 1 if any test
+// failed.
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	verbose := flags.Bool("v", false, "also list the tests that pass")
+	run := flags.String("run", "",
+		"run only the tests whose names match `regexp`")
+	timeout := flags.Duration("timeout", testTimeout,
+		"fail a test, or a file, that runs longer than `d` (0 for no limit)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	match, err := regexp.Compile(*run)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey test: -run: %s\n", err)
+		return 2
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := findTestFiles(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey test: %s\n", err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "monkey test: no test files")
+		return 1
+	}
+	status := 0
+	for _, path := range files {
+		if !testFile(path, match, *verbose, *timeout) {
+			status = 1
+		}
+	}
+	return status
+}
+
+// findTestFiles returns the files among paths, followed by the test files in
+// the directories among them.
+func findTestFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path,
+			func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() &&
+					strings.HasSuffix(path, testFileSuffix) {
+					files = append(files, path)
+				}
+				return err
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// testFile runs the tests in the file at path whose names match, reporting
+// whether they all passed. The program in the file is evaluated first, so
+// that the tests can use the values it binds. The evaluation, and each test,
+// is stopped after timeout, unless it is 0.
+func testFile(path string, match *regexp.Regexp, verbose bool,
+	timeout time.Duration) bool {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey test: %s\n", err)
+		return false
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+		}
+		fmt.Printf("FAIL\t%s\n", path)
+		return false
+	}
+	env := object.NewEnvironment()
+	ctx, cancel := withTimeout(timeout)
+	result, err := evaluator.EvalContext(ctx, program, env, evaluator.Limits{})
+	cancel()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, stopped(path, err))
+		fmt.Printf("FAIL\t%s\n", path)
+		return false
+	}
+	if err, ok := result.(*object.Error); ok {
+		fmt.Fprint(os.Stderr, err.Trace(path))
+		fmt.Printf("FAIL\t%s\n", path)
+		return false
+	}
+
+	passed, failed := 0, 0
+	for _, s := range program.Statements {
+		let, ok := s.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, testPrefix) ||
+			!match.MatchString(let.Name.Value) {
+			continue
+		}
+		// Other values bound to such names, like the data of tests, aren't
+		// tests.
+		literal, ok := let.Value.(*ast.FunctionLiteral)
+		if !ok {
+			continue
+		}
+		name, pos := let.Name.Value, ast.Pos(let.Name)
+		var failure string
+		if len(literal.Parameters) != 0 {
+			failure = fmt.Sprintf("\t%s:%s: %s must have no parameters\n", path,
+				ast.Pos(literal), name)
+		} else {
+			fn, _ := env.Get(name)
+			failure = runTestFunction(path, fn, timeout)
+		}
+		if failure != "" {
+			failed++
+			fmt.Printf("--- FAIL: %s (%s:%s)\n%s", name, path, pos, failure)
+		} else {
+			passed++
+			if verbose {
+				fmt.Printf("--- PASS: %s (%s:%s)\n", name, path, pos)
+			}
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("FAIL\t%s\t%d passed, %d failed\n", path, passed, failed)
+		return false
+	}
+	fmt.Printf("ok  \t%s\t%d passed\n", path, passed)
+	return true
+}
+
+// runTestFunction calls fn, a test in the file at path, stopping it after
+// timeout, unless it is 0. It returns a description of how the test failed,
+// or "" if it passed. The description gives the position of the failure,
+// followed by the calls within the test that led to it, innermost first.
+func runTestFunction(path string, fn object.Object,
+	timeout time.Duration) string {
+	ctx, cancel := withTimeout(timeout)
+	defer cancel()
+	result, err := evaluator.ApplyContext(ctx, fn, nil, evaluator.Limits{})
+	if err != nil {
+		return "\t" + stopped(path, err) + "\n"
+	}
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		return ""
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "\t%s:%s: %s\n", path, errObj.Pos, errObj.Message)
+	// The call of the test itself, made here, has no position.
+	for _, f := range errObj.Stack {
+		if !f.CallPos.IsValid() {
+			continue
+		}
+		fmt.Fprintf(&out, "\t\tin %s, called at %s:%s\n", f.Function, path,
+			f.CallPos)
+	}
+	return out.String()
+}
+
+// stopped describes err, which stopped the evaluation of the file at path, as
+// "path:line:column: message", or as "path: message" if the evaluation hadn't
+// reached a position.
+func stopped(path string, err error) string {
+	if lerr, ok := err.(*evaluator.LimitError); ok && lerr.Pos.IsValid() {
+		return fmt.Sprintf("%s:%s", path, err)
+	}
+	return fmt.Sprintf("%s: %s", path, err)
+}
+
+// withTimeout returns a context that is done after timeout, or never if it is
+// 0.
+func withTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// testFiles are the files of the directory the tests of runTest run in.
+var testFiles = map[string]string{
+	"math_test.mk": `let double = fn(x) { x * 2 };
+let test_double = fn() { assertEqual(double(2), 4) };
+let test_wrong = fn() { assertEqual(double(2), 5) };
+let test_data = [1, 2];
+`,
+	"sub/loop_test.mk": `let test_loop = fn() { test_loop() };
+`,
+	"sub/helper.mk": `let test_ignored = fn() { assert(false) };
+`,
+}
+
+func TestRunTest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkeytest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, src := range testFiles {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	math := filepath.Join(dir, "math_test.mk")
+	sub := filepath.Join(dir, "sub")
+
+	tests := []struct {
+		args     []string
+		status   int
+		expected []string // in the output
+		absent   []string // from the output
+	}{
+		{[]string{"-v", "-run", "double", math}, 0,
+			[]string{"--- PASS: test_double", "ok  \t" + math + "\t1 passed"},
+			[]string{"test_wrong"}},
+		{[]string{"-run", "double", math}, 0, nil,
+			[]string{"--- PASS"}},
+		{[]string{math}, 1,
+			[]string{"--- FAIL: test_wrong (" + math + ":3:5)",
+				"FAIL\t" + math + "\t1 passed, 1 failed"},
+			[]string{"test_data"}},
+		{[]string{"-timeout", "100ms", sub}, 1,
+			[]string{"--- FAIL: test_loop", "evaluation stopped",
+				"context deadline exceeded"},
+			[]string{"test_ignored", "helper.mk"}},
+		{[]string{"-timeout", "100ms", dir}, 1,
+			[]string{"math_test.mk", "loop_test.mk"},
+			[]string{"helper.mk"}},
+		{[]string{"-run", "none", math}, 0,
+			[]string{"ok  \t" + math + "\t0 passed"}, nil},
+		{[]string{"-run", "("}, 2, []string{"monkey test: -run:"}, nil},
+		{[]string{filepath.Join(dir, "missing")}, 1,
+			[]string{"monkey test:"}, nil},
+	}
+
+	for _, tt := range tests {
+		status, out := captureOutput(t, func() int { return runTest(tt.args) })
+		if status != tt.status {
+			t.Errorf("wrong exit status for %q. expected=%d, got=%d\n%s",
+				tt.args, tt.status, status, out)
+		}
+		for _, s := range tt.expected {
+			if !strings.Contains(out, s) {
+				t.Errorf("output for %q doesn't contain %q:\n%s", tt.args, s,
+					out)
+			}
+		}
+		for _, s := range tt.absent {
+			if strings.Contains(out, s) {
+				t.Errorf("output for %q contains %q:\n%s", tt.args, s, out)
+			}
+		}
+	}
+}
+
+func TestRunTestTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkeytest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "loop_test.mk")
+	src := testFiles["sub/loop_test.mk"]
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, out := captureOutput(t, func() int {
+		return runTest([]string{"-timeout", "100ms", path})
+	})
+	expected := regexp.MustCompile(`(?m)^\t` + regexp.QuoteMeta(path) +
+		`:1:\d+: evaluation stopped: context deadline exceeded$`)
+	if !expected.MatchString(out) {
+		t.Errorf("output doesn't match %q:\n%s", expected, out)
+	}
+}
+
+func TestStopped(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{&evaluator.LimitError{Kind: evaluator.ContextDone,
+			Pos: token.Position{Line: 1, Column: 27},
+			Err: context.DeadlineExceeded},
+			"a.mk:1:27: evaluation stopped: context deadline exceeded"},
+		{&evaluator.LimitError{Kind: evaluator.ContextDone,
+			Err: context.DeadlineExceeded},
+			"a.mk: evaluation stopped: context deadline exceeded"},
+		{&evaluator.LimitError{Kind: evaluator.StepLimit, Max: 10},
+			"a.mk: maximum number of steps (10) exceeded"},
+	}
+
+	for _, tt := range tests {
+		if got := stopped("a.mk", tt.err); got != tt.expected {
+			t.Errorf("wrong description. expected=%q, got=%q", tt.expected,
+				got)
+		}
+	}
+}
+
+// captureOutput calls f, returning its result and what it wrote to standard
+// output and standard error.
+func captureOutput(t *testing.T, f func() int) (int, string) {
+	t.Helper()
+	file, err := ioutil.TempFile("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = file, file
+	status := f()
+	os.Stdout, os.Stderr = stdout, stderr
+
+	out, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status, string(out)
+}
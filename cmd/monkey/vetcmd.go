@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/parser"
+	"github.com/adamvinueza/monkey/vet"
+)
+
+const vetUsage = "vet [-json] [-rule...] file..."
+
+var vetCommand = &command{name: "vet", usage: vetUsage, run: runVet}
+
+// vetDiagnostic is a diagnostic as written by "monkey vet -json".
+type vetDiagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// runVet checks the specified Monkey files, writing what it finds to standard
+// output, one diagnostic per line. Each rule has a flag: if any is set, only
+// those rules run, and if any is cleared, as in -unused=false, all but those
+// rules run. The exit status is 1 if anything was found.
+func runVet(args []string) int {
+	flags := flag.NewFlagSet("vet", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false,
+		"write each diagnostic as a JSON object instead of a line of text")
+	enabled := make(map[string]*bool)
+	for _, r := range vet.Rules {
+		enabled[r.Name] = flags.Bool(r.Name, false, r.Doc)
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: monkey %s\n", vetUsage)
+		return 2
+	}
+
+	set, cleared := false, false
+	flags.Visit(func(f *flag.Flag) {
+		if on, ok := enabled[f.Name]; ok {
+			set = set || *on
+			cleared = cleared || !*on
+		}
+	})
+	if set && cleared {
+		fmt.Fprintln(os.Stderr, "monkey vet: rules can't be both set and cleared")
+		return 2
+	}
+	var rules []*vet.Rule
+	for _, r := range vet.Rules {
+		if *enabled[r.Name] || !set && !isFlagSet(flags, r.Name) {
+			rules = append(rules, r)
+		}
+	}
+
+	status := 0
+	enc := json.NewEncoder(os.Stdout)
+	for _, path := range flags.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey vet: %s\n", err)
+			status = 1
+			continue
+		}
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			for _, msg := range p.Errors() {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+			}
+			status = 1
+			continue
+		}
+		for _, d := range vet.Check(program, rules) {
+			status = 1
+			if *asJSON {
+				enc.Encode(vetDiagnostic{File: path, Line: d.Pos.Line,
+					Column: d.Pos.Column, Rule: d.Rule, Message: d.Message})
+			} else {
+				fmt.Printf("%s:%s\n", path, d)
+			}
+		}
+	}
+	return status
+}
+
+// isFlagSet reports whether the flag called name was given on the command
+// line.
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	found := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
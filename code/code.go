@@ -0,0 +1,247 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions is a sequence of encoded instructions.
+type Instructions []byte
+
+// String returns a listing of the instructions, one per line, each prefixed by
+// its offset. For example:
+//  0000 OpConstant 1
+//  0003 OpPop
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d",
+			len(operands), len(def.OperandWidths))
+	}
+
+	var out bytes.Buffer
+	out.WriteString(def.Name)
+	for _, o := range operands {
+		fmt.Fprintf(&out, " %d", o)
+	}
+	return out.String()
+}
+
+// Opcode identifies the operation an instruction performs.
+type Opcode byte
+
+const (
+	// OpConstant pushes the constant whose index is its operand.
+	OpConstant Opcode = iota
+	// OpPop pops the top of the stack, discarding it.
+	OpPop
+
+	// OpAdd, OpSub, OpMul, OpDiv, OpEqual, OpNotEqual, OpGreaterThan and
+	// OpLessThan pop the right operand and then the left, and push the
+	// result of applying the operator to them.
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+
+	// OpMinus and OpBang pop their operand and push the result of applying
+	// the prefix operator to it.
+	OpMinus
+	OpBang
+
+	// OpTrue, OpFalse and OpNull push true, false and null.
+	OpTrue
+	OpFalse
+	OpNull
+
+	// OpJump jumps to the offset that is its operand. OpJumpNotTruthy pops
+	// the top of the stack and jumps only if it is not truthy.
+	OpJump
+	OpJumpNotTruthy
+
+	// OpGetGlobal pushes the global variable whose index is its operand, and
+	// OpSetGlobal pops a value and assigns it to that global.
+	OpGetGlobal
+	OpSetGlobal
+	// OpGetLocal and OpSetLocal are like OpGetGlobal and OpSetGlobal, but for
+	// the local variables of the current call.
+	OpGetLocal
+	OpSetLocal
+	// OpGetFree pushes a local variable of a call enclosing the definition of
+	// the current function. Its operands are the depth of the call, counting
+	// from 0 for the innermost, and the index of the variable.
+	OpGetFree
+	// OpGetBuiltin pushes the builtin whose name has the index that is its
+	// operand in the program's list of builtin names.
+	OpGetBuiltin
+
+	// OpArray pops the number of elements that is its operand and pushes an
+	// array of them. OpHash is similar, but the elements alternate between
+	// keys and values.
+	OpArray
+	OpHash
+	// OpIndex pops an index and then the value indexed, and pushes the
+	// element of the value at the index.
+	OpIndex
+
+	// OpClosure pushes a closure of the compiled function whose constant
+	// index is its operand.
+	OpClosure
+	// OpCall calls the function below the number of arguments that is its
+	// operand, popping the function and its arguments. OpTailCall does the
+	// same, but in place of the current call, which it ends.
+	OpCall
+	OpTailCall
+	// OpReturnValue pops a value and returns it from the current call, and
+	// OpReturn returns null.
+	OpReturnValue
+	OpReturn
+
+	// Opcodes added since compiled modules were introduced come last, so that
+	// the opcodes of existing modules keep their values.
+
+	// OpHashKey fails unless the value on top of the stack can be a hash key,
+	// leaving the value in place. It follows each key of a hash literal, so
+	// that an unusable key is reported before the value paired with it is
+	// evaluated, as in the evaluator.
+	OpHashKey
+	// OpDup pushes the value on top of the stack again.
+	OpDup
+)
+
+// Definition describes an opcode.
+type Definition struct {
+	Name          string // the name of the opcode, such as "OpConstant"
+	OperandWidths []int  // the width, in bytes, of each operand
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:      {"OpConstant", []int{2}},
+	OpPop:           {"OpPop", []int{}},
+	OpAdd:           {"OpAdd", []int{}},
+	OpSub:           {"OpSub", []int{}},
+	OpMul:           {"OpMul", []int{}},
+	OpDiv:           {"OpDiv", []int{}},
+	OpEqual:         {"OpEqual", []int{}},
+	OpNotEqual:      {"OpNotEqual", []int{}},
+	OpGreaterThan:   {"OpGreaterThan", []int{}},
+	OpLessThan:      {"OpLessThan", []int{}},
+	OpMinus:         {"OpMinus", []int{}},
+	OpBang:          {"OpBang", []int{}},
+	OpTrue:          {"OpTrue", []int{}},
+	OpFalse:         {"OpFalse", []int{}},
+	OpNull:          {"OpNull", []int{}},
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpGetGlobal:     {"OpGetGlobal", []int{2}},
+	OpSetGlobal:     {"OpSetGlobal", []int{2}},
+	OpGetLocal:      {"OpGetLocal", []int{1}},
+	OpSetLocal:      {"OpSetLocal", []int{1}},
+	OpGetFree:       {"OpGetFree", []int{1, 1}},
+	OpGetBuiltin:    {"OpGetBuiltin", []int{1}},
+	OpArray:         {"OpArray", []int{2}},
+	OpHash:          {"OpHash", []int{2}},
+	OpIndex:         {"OpIndex", []int{}},
+	OpClosure:       {"OpClosure", []int{2}},
+	OpCall:          {"OpCall", []int{1}},
+	OpTailCall:      {"OpTailCall", []int{1}},
+	OpReturnValue:   {"OpReturnValue", []int{}},
+	OpReturn:        {"OpReturn", []int{}},
+	OpHashKey:       {"OpHashKey", []int{}},
+	OpDup:           {"OpDup", []int{}},
+}
+
+// Lookup returns the definition of the opcode op, or an error if op is not
+// defined.
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make returns the instruction with opcode op and the specified operands, or
+// an empty instruction if op is not defined.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands described by def at the start of ins,
+// returning them along with the number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+// ReadUint16 decodes a two-byte operand at the start of ins.
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// ReadUint8 decodes a one-byte operand at the start of ins.
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
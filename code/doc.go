@@ -0,0 +1,11 @@
+// Package code defines the bytecode instructions executed by the Monkey
+// virtual machine.
+//
+// An instruction is an opcode, one byte long, followed by its operands, each
+// of which is an unsigned, big-endian integer whose width is given by the
+// opcode's Definition. Make encodes an instruction, and ReadOperands decodes
+// the operands that follow an opcode:
+//  ins := code.Make(code.OpConstant, 65534)
+//  def, _ := code.Lookup(ins[0])
+//  operands, _ := code.ReadOperands(def, ins[1:]) // []int{65534}
+package code
@@ -0,0 +1,29 @@
+package code
+
+import (
+	"sort"
+
+	"github.com/adamvinueza/monkey/token"
+)
+
+// LineTable maps the offsets of instructions to the positions in the source
+// text of the code they were compiled from. It holds an entry for each
+// instruction whose position differs from that of the instruction before it,
+// in order of offset.
+type LineTable []LineEntry
+
+// LineEntry gives the position of the instructions starting at Offset.
+type LineEntry struct {
+	Offset int
+	Pos    token.Position
+}
+
+// Pos returns the position of the instruction at offset, or an invalid
+// position if it is unknown.
+func (lt LineTable) Pos(offset int) token.Position {
+	i := sort.Search(len(lt), func(i int) bool { return lt[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+	return lt[i-1].Pos
+}
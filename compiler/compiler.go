@@ -0,0 +1,559 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/code"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// Limits on the sizes of programs imposed by the widths of operands.
+const (
+	maxLocals   = 1 << 8
+	maxBuiltins = 1 << 8
+	maxArgs     = 1<<8 - 1
+	maxDepth    = 1 << 8
+	maxOperand  = 1 << 16
+)
+
+// Bytecode is a compiled program.
+type Bytecode struct {
+	// Instructions are those of the top level of the program. They return
+	// the value of the last statement, if it is an expression statement.
+	Instructions code.Instructions
+	// Lines gives the positions in the source text of the instructions.
+	Lines code.LineTable
+	// Constants holds the values of the program's literals, including its
+	// functions, by index.
+	Constants []object.Object
+	// Globals holds the names of the program's global variables, by index.
+	Globals []string
+	// Builtins holds the names of the builtins the program uses, by index.
+	Builtins []string
+}
+
+// Compiler compiles a program to bytecode.
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []compilationScope
+	scopeIndex int
+
+	pos token.Position // the position of the node being compiled
+}
+
+// compilationScope holds the instructions of the function being compiled.
+type compilationScope struct {
+	instructions        code.Instructions
+	lines               code.LineTable
+	lastInstruction     emittedInstruction
+	previousInstruction emittedInstruction
+}
+
+type emittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// New returns a new Compiler.
+func New() *Compiler {
+	return &Compiler{
+		symbolTable: NewSymbolTable(),
+		scopes:      []compilationScope{{}},
+	}
+}
+
+// Bytecode returns the compiled program.
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Lines:        c.scopes[c.scopeIndex].lines,
+		Constants:    c.constants,
+		Globals:      c.symbolTable.Names(),
+		Builtins:     c.symbolTable.BuiltinNames(),
+	}
+}
+
+// Compile compiles node, which should be an *ast.Program, adding its
+// instructions to those compiled so far.
+func (c *Compiler) Compile(node ast.Node) error {
+	outerPos := c.pos
+	if pos := ast.Pos(node); pos.IsValid() {
+		c.pos = pos
+	}
+	err := c.compileNode(node)
+	c.pos = outerPos
+	return err
+}
+
+func (c *Compiler) compileNode(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		return c.compileBody(node.Statements)
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+		// The value of a block is that of its last statement, if it is an
+		// expression statement, and null otherwise.
+		if c.lastInstructionIs(code.OpPop) {
+			c.removeLastPop()
+		} else {
+			c.emit(code.OpNull)
+		}
+
+	case *ast.LetStatement:
+		var err error
+		if fl, ok := node.Value.(*ast.FunctionLiteral); ok {
+			err = c.compileFunction(fl, node.Name.Value)
+		} else {
+			err = c.Compile(node.Value)
+		}
+		if err != nil {
+			return err
+		}
+		symbol := c.symbolTable.Define(node.Name.Value)
+		if symbol.Scope == GlobalScope {
+			c.emit(code.OpSetGlobal, symbol.Index)
+		} else {
+			c.emit(code.OpSetLocal, symbol.Index)
+		}
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			symbol = c.symbolTable.DefineBuiltin(node.Value)
+		}
+		return c.loadSymbol(symbol)
+
+	case *ast.IntegerLiteral:
+		return c.emitConstant(&object.Integer{Value: node.Value})
+
+	case *ast.StringLiteral:
+		return c.emitConstant(&object.String{Value: node.Value})
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		if len(node.Elements) >= maxOperand {
+			return fmt.Errorf("too many elements in array literal: %d",
+				len(node.Elements))
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			c.emit(code.OpHashKey)
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		if len(node.Pairs)*2 >= maxOperand {
+			return fmt.Errorf("too many pairs in hash literal: %d",
+				len(node.Pairs))
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node, "")
+
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		if len(node.Arguments) > maxArgs {
+			return fmt.Errorf("too many arguments in call: %d",
+				len(node.Arguments))
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+}
+
+// compileBody compiles the statements of a program or a function body, which
+// returns the value of its last statement if that is an expression statement,
+// and otherwise returns nothing.
+func (c *Compiler) compileBody(statements []ast.Statement) error {
+	for _, s := range statements {
+		declare(c.symbolTable, s)
+	}
+	for _, s := range statements {
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	} else if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+	return nil
+}
+
+// declare declares the variables bound by let statements in node, other than
+// those in the bodies of function literals, which have scopes of their own.
+func declare(s *SymbolTable, node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		s.Declare(node.Name.Value)
+		declare(s, node.Value)
+	case *ast.ExpressionStatement:
+		declare(s, node.Expression)
+	case *ast.ReturnStatement:
+		declare(s, node.ReturnValue)
+	case *ast.BlockStatement:
+		for _, statement := range node.Statements {
+			declare(s, statement)
+		}
+	case *ast.IfExpression:
+		declare(s, node.Condition)
+		declare(s, node.Consequence)
+		if node.Alternative != nil {
+			declare(s, node.Alternative)
+		}
+	case *ast.PrefixExpression:
+		declare(s, node.Right)
+	case *ast.InfixExpression:
+		declare(s, node.Left)
+		declare(s, node.Right)
+	case *ast.CallExpression:
+		declare(s, node.Function)
+		for _, a := range node.Arguments {
+			declare(s, a)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			declare(s, el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			declare(s, pair.Key)
+			declare(s, pair.Value)
+		}
+	case *ast.IndexExpression:
+		declare(s, node.Left)
+		declare(s, node.Index)
+	}
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Emit jumps with bogus offsets, to be replaced once the offsets are
+	// known.
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.Compile(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	if len(c.currentInstructions()) >= maxOperand {
+		return fmt.Errorf("too many instructions: %d",
+			len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileFunction compiles the function literal fl, bound to the specified
+// name, if any.
+func (c *Compiler) compileFunction(fl *ast.FunctionLiteral, name string) error {
+	c.enterScope()
+
+	for _, p := range fl.Parameters {
+		c.symbolTable.DefineParameter(p.Value)
+	}
+	if err := c.compileBody(fl.Body.Statements); err != nil {
+		return err
+	}
+
+	locals := c.symbolTable.Names()
+	instructions, lines := c.leaveScope()
+	if len(locals) > maxLocals {
+		return fmt.Errorf("too many local variables in function: %d",
+			len(locals))
+	}
+	markTailCalls(instructions)
+
+	return c.emitClosure(&object.CompiledFunction{
+		Name:          name,
+		Instructions:  instructions,
+		NumParameters: len(fl.Parameters),
+		Locals:        locals,
+		Lines:         lines,
+	})
+}
+
+// markTailCalls replaces each OpCall in ins whose value is returned, directly
+// or after jumps, by an OpTailCall.
+func markTailCalls(ins code.Instructions) {
+	for i := 0; i < len(ins); {
+		op := code.Opcode(ins[i])
+		def, _ := code.Lookup(ins[i])
+		_, read := code.ReadOperands(def, ins[i+1:])
+		next := i + 1 + read
+		if op == code.OpCall && returns(ins, next) {
+			ins[i] = byte(code.OpTailCall)
+		}
+		i = next
+	}
+}
+
+// returns reports whether the instruction at offset pos in ins returns the
+// top of the stack, directly or after jumps.
+func returns(ins code.Instructions, pos int) bool {
+	for pos < len(ins) && code.Opcode(ins[pos]) == code.OpJump {
+		target := int(code.ReadUint16(ins[pos+1:]))
+		if target <= pos {
+			return false
+		}
+		pos = target
+	}
+	return pos < len(ins) && code.Opcode(ins[pos]) == code.OpReturnValue
+}
+
+func (c *Compiler) loadSymbol(s Symbol) error {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		if s.Depth >= maxDepth {
+			return fmt.Errorf("functions nested too deeply: %d", s.Depth)
+		}
+		c.emit(code.OpGetFree, s.Depth, s.Index)
+	case BuiltinScope:
+		if s.Index >= maxBuiltins {
+			return fmt.Errorf("too many builtins: %d", s.Index+1)
+		}
+		c.emit(code.OpGetBuiltin, s.Index)
+	}
+	return nil
+}
+
+func (c *Compiler) emitConstant(obj object.Object) error {
+	index, err := c.addConstant(obj)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpConstant, index)
+	return nil
+}
+
+func (c *Compiler) emitClosure(fn *object.CompiledFunction) error {
+	index, err := c.addConstant(fn)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpClosure, index)
+	return nil
+}
+
+func (c *Compiler) addConstant(obj object.Object) (int, error) {
+	if len(c.constants) >= maxOperand {
+		return 0, fmt.Errorf("too many constants: %d", len(c.constants)+1)
+	}
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1, nil
+}
+
+// emit adds an instruction to the current scope, returning its position.
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+	c.addLine(pos)
+
+	return pos
+}
+
+// addLine records that the instruction at offset pos was compiled from the
+// node being compiled.
+func (c *Compiler) addLine(pos int) {
+	lines := c.scopes[c.scopeIndex].lines
+	if len(lines) > 0 && lines[len(lines)-1].Pos == c.pos {
+		return
+	}
+	c.scopes[c.scopeIndex].lines = append(lines,
+		code.LineEntry{Offset: pos, Pos: c.pos})
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(),
+		ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := emittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+
+	lines := c.scopes[c.scopeIndex].lines
+	for len(lines) > 0 && lines[len(lines)-1].Offset >= last.Position {
+		lines = lines[:len(lines)-1]
+	}
+	c.scopes[c.scopeIndex].lines = lines
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+// changeOperand replaces the operand of the instruction at pos.
+func (c *Compiler) changeOperand(pos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[pos])
+	newInstruction := code.Make(op, operand)
+	c.replaceInstruction(pos, newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, compilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, code.LineTable) {
+	instructions := c.currentInstructions()
+	lines := c.scopes[c.scopeIndex].lines
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions, lines
+}
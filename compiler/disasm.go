@@ -0,0 +1,156 @@
+package compiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/adamvinueza/monkey/code"
+	"github.com/adamvinueza/monkey/object"
+)
+
+// Disassemble writes a listing of the instructions of b to w: first those of
+// the top level of the program, then those of each function, in the order the
+// functions are defined. Each instruction is shown with its offset, the
+// position in the source text it was compiled from, and, where an operand
+// refers to a constant or variable, the value or name it refers to. If src,
+// the source text of the program, is not empty, the line of source text an
+// instruction was compiled from is shown before the first instruction compiled
+// from it.
+func Disassemble(w io.Writer, b *Bytecode, src string) error {
+	d := &disassembler{
+		w:     bufio.NewWriter(w),
+		b:     b,
+		lines: strings.Split(src, "\n"),
+		seen:  map[*object.CompiledFunction]bool{},
+	}
+	if src == "" {
+		d.lines = nil
+	}
+	d.main = &object.CompiledFunction{
+		Instructions: b.Instructions,
+		Lines:        b.Lines,
+	}
+	fmt.Fprintf(d.w, "main:\n")
+	d.function(d.main, nil)
+	return d.w.Flush()
+}
+
+type disassembler struct {
+	w     *bufio.Writer
+	b     *Bytecode
+	lines []string
+	main  *object.CompiledFunction
+	seen  map[*object.CompiledFunction]bool
+}
+
+// function lists the instructions of fn, which is defined within the functions
+// in enclosing, innermost first, followed by those of the functions it
+// defines.
+func (d *disassembler) function(fn *object.CompiledFunction,
+	enclosing []*object.CompiledFunction) {
+	d.seen[fn] = true
+	var inner []*object.CompiledFunction
+	if fn != d.main {
+		inner = append([]*object.CompiledFunction{fn}, enclosing...)
+	}
+
+	var defined []*object.CompiledFunction
+	line := 0
+	ins := fn.Instructions
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(d.w, "%04d ERROR: %s\n", i, err)
+			i++
+			continue
+		}
+		operands, read := code.ReadOperands(def, ins[i+1:])
+
+		pos := fn.Lines.Pos(i)
+		if pos.IsValid() && pos.Line != line && pos.Line <= len(d.lines) {
+			line = pos.Line
+			fmt.Fprintf(d.w, "%6d | %s\n", line,
+				strings.TrimSpace(d.lines[line-1]))
+		}
+
+		text := def.Name
+		for _, o := range operands {
+			text += fmt.Sprintf(" %d", o)
+		}
+		note := d.operand(ins[i], operands, fn, enclosing)
+		if note == "" {
+			fmt.Fprintf(d.w, "%04d %-7s %s\n", i, pos, text)
+		} else {
+			fmt.Fprintf(d.w, "%04d %-7s %-20s ; %s\n", i, pos, text, note)
+		}
+
+		if code.Opcode(ins[i]) == code.OpClosure &&
+			operands[0] < len(d.b.Constants) {
+			f, ok := d.b.Constants[operands[0]].(*object.CompiledFunction)
+			if ok && !d.seen[f] {
+				d.seen[f] = true
+				defined = append(defined, f)
+			}
+		}
+		i += 1 + read
+	}
+
+	for _, f := range defined {
+		fmt.Fprintf(d.w, "\n%s:\n", functionHeading(f))
+		d.function(f, inner)
+	}
+}
+
+// operand describes what the operands of the instruction with opcode op in fn
+// refer to, or returns the empty string if they refer to nothing in
+// particular.
+func (d *disassembler) operand(op byte, operands []int,
+	fn *object.CompiledFunction, enclosing []*object.CompiledFunction) string {
+	name := func(names []string, i int) string {
+		if i < 0 || i >= len(names) {
+			return "?"
+		}
+		return names[i]
+	}
+	switch code.Opcode(op) {
+	case code.OpConstant, code.OpClosure:
+		if operands[0] >= len(d.b.Constants) {
+			return "?"
+		}
+		switch c := d.b.Constants[operands[0]].(type) {
+		case *object.CompiledFunction:
+			return functionHeading(c)
+		case *object.String:
+			return fmt.Sprintf("%q", c.Value)
+		default:
+			return c.Inspect()
+		}
+	case code.OpGetGlobal, code.OpSetGlobal:
+		return name(d.b.Globals, operands[0])
+	case code.OpGetLocal, code.OpSetLocal:
+		return name(fn.Locals, operands[0])
+	case code.OpGetFree:
+		if operands[0] >= len(enclosing) {
+			return "?"
+		}
+		return name(enclosing[operands[0]].Locals, operands[1])
+	case code.OpGetBuiltin:
+		return name(d.b.Builtins, operands[0])
+	}
+	return ""
+}
+
+// functionHeading describes fn by its name and parameters.
+func functionHeading(fn *object.CompiledFunction) string {
+	name := fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	params := fn.Locals
+	if fn.NumParameters <= len(params) {
+		params = params[:fn.NumParameters]
+	}
+	return fmt.Sprintf("fn %s(%s)", name, strings.Join(params, ", "))
+}
@@ -0,0 +1,87 @@
+package compiler
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	input := `let n = 2;
+let f = fn(x) {
+  fn() { x * n }
+};
+f(len("ab"))`
+
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{
+			input,
+			`main:
+     1 | let n = 2;
+0000 1:9     OpConstant 0         ; 2
+0003 1:1     OpSetGlobal 0        ; n
+     2 | let f = fn(x) {
+0006 2:1     OpClosure 2          ; fn f(x)
+0009 2:1     OpSetGlobal 1        ; f
+     5 | f(len("ab"))
+0012 5:1     OpGetGlobal 1        ; f
+0015 5:3     OpGetBuiltin 0       ; len
+0017 5:7     OpConstant 3         ; "ab"
+0020 5:3     OpCall 1
+0022 5:1     OpCall 1
+0024 5:1     OpReturnValue
+
+fn f(x):
+     3 | fn() { x * n }
+0000 3:3     OpClosure 1          ; fn <anonymous>()
+0003 3:3     OpReturnValue
+
+fn <anonymous>():
+     3 | fn() { x * n }
+0000 3:10    OpGetFree 0 0        ; x
+0003 3:14    OpGetGlobal 0        ; n
+0006 3:10    OpMul
+0007 3:10    OpReturnValue
+`,
+		},
+		{
+			"",
+			`main:
+0000 1:9     OpConstant 0         ; 2
+0003 1:1     OpSetGlobal 0        ; n
+0006 2:1     OpClosure 2          ; fn f(x)
+0009 2:1     OpSetGlobal 1        ; f
+0012 5:1     OpGetGlobal 1        ; f
+0015 5:3     OpGetBuiltin 0       ; len
+0017 5:7     OpConstant 3         ; "ab"
+0020 5:3     OpCall 1
+0022 5:1     OpCall 1
+0024 5:1     OpReturnValue
+
+fn f(x):
+0000 3:3     OpClosure 1          ; fn <anonymous>()
+0003 3:3     OpReturnValue
+
+fn <anonymous>():
+0000 3:10    OpGetFree 0 0        ; x
+0003 3:14    OpGetGlobal 0        ; n
+0006 3:10    OpMul
+0007 3:10    OpReturnValue
+`,
+		},
+	}
+
+	b := compileModule(t, input)
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := Disassemble(&out, b, tt.src); err != nil {
+			t.Fatalf("Disassemble failed: %s", err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("wrong listing.\nexpected:\n%s\nfound:\n%s", tt.expected,
+				out.String())
+		}
+	}
+}
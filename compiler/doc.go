@@ -0,0 +1,22 @@
+// Package compiler compiles Monkey programs to bytecode for the virtual
+// machine in package vm.
+//
+// To use, parse a program, compile it, and pass the resulting Bytecode to the
+// virtual machine:
+//  p := parser.New(lexer.New(`let add = fn(x, y) { x + y }; add(2, 3)`))
+//  c := compiler.New()
+//  if err := c.Compile(p.ParseProgram()); err != nil {
+//      log.Fatal(err)
+//  }
+//  machine := vm.New(c.Bytecode())
+//
+// Variables are resolved when the program is compiled, using a SymbolTable
+// for each function. A variable is visible in the function that binds it from
+// its let statement on, and in the functions defined within it throughout, so
+// that functions can call functions bound after them. A name that isn't bound
+// by the program refers to the builtin of that name, which is looked up when
+// the program runs.
+//
+// Peephole optimizes compiled bytecode, rewriting redundant sequences of
+// instructions.
+package compiler
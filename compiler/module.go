@@ -0,0 +1,421 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/adamvinueza/monkey/code"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// ModuleVersion is the version of the format written by Marshal. Unmarshal
+// reads only modules of this version, since the instructions of the virtual
+// machine may change from one version to the next.
+const ModuleVersion = 1
+
+// moduleMagic starts every compiled module.
+const moduleMagic = "\x00mky"
+
+// Tags identifying the types of constants.
+const (
+	integerTag  = 'i'
+	stringTag   = 's'
+	functionTag = 'f'
+)
+
+// ErrNotModule is the error returned by Unmarshal when its input is not a
+// compiled module.
+var ErrNotModule = errors.New("not a compiled Monkey module")
+
+// IsModule reports whether data starts like a compiled module, as opposed to,
+// say, Monkey source text.
+func IsModule(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(moduleMagic))
+}
+
+// Marshal encodes b as a compiled module, which can be written to a file and
+// decoded by Unmarshal. The format is:
+//
+//	module      = magic version names names constants function
+//	magic       = "\x00mky"
+//	version     = uvarint
+//	names       = count { string }             (globals, then builtins)
+//	constants   = count { constant }
+//	constant    = "i" varint | "s" string | "f" string function
+//	function    = uvarint names bytes lines     (parameters, locals, code)
+//	lines       = count { uvarint uvarint uvarint }  (offset, line, column)
+//	string      = bytes
+//	bytes       = count { byte }
+//	count       = uvarint
+//
+// The last function is the top level of the program; the name before each
+// function constant is its name. Integers are encoded as by
+// binary.PutVarint and binary.PutUvarint.
+func Marshal(b *Bytecode) ([]byte, error) {
+	e := &encoder{}
+	e.buf.WriteString(moduleMagic)
+	e.uvarint(ModuleVersion)
+	e.strings(b.Globals)
+	e.strings(b.Builtins)
+
+	e.uvarint(len(b.Constants))
+	for i, c := range b.Constants {
+		switch c := c.(type) {
+		case *object.Integer:
+			e.buf.WriteByte(integerTag)
+			e.varint(c.Value)
+		case *object.String:
+			e.buf.WriteByte(stringTag)
+			e.string(c.Value)
+		case *object.CompiledFunction:
+			e.buf.WriteByte(functionTag)
+			e.string(c.Name)
+			e.function(c)
+		default:
+			return nil, fmt.Errorf("constant %d: cannot marshal %s", i,
+				c.Type())
+		}
+	}
+
+	e.function(&object.CompiledFunction{
+		Instructions: b.Instructions,
+		Lines:        b.Lines,
+	})
+	return e.buf.Bytes(), nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) uvarint(n int) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutUvarint(b[:], uint64(n))])
+}
+
+func (e *encoder) varint(n int64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutVarint(b[:], n)])
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(len(b))
+	e.buf.Write(b)
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(len(s))
+	e.buf.WriteString(s)
+}
+
+func (e *encoder) strings(ss []string) {
+	e.uvarint(len(ss))
+	for _, s := range ss {
+		e.string(s)
+	}
+}
+
+func (e *encoder) function(fn *object.CompiledFunction) {
+	e.uvarint(fn.NumParameters)
+	e.strings(fn.Locals)
+	e.bytes(fn.Instructions)
+	e.uvarint(len(fn.Lines))
+	for _, l := range fn.Lines {
+		e.uvarint(l.Offset)
+		e.uvarint(l.Pos.Line)
+		e.uvarint(l.Pos.Column)
+	}
+}
+
+// Unmarshal decodes a compiled module written by Marshal. It returns
+// ErrNotModule if data is not a compiled module, and an error if the module
+// is of another version, or is malformed: if it is truncated, or if its
+// instructions are undefined or refer to constants, variables or offsets that
+// don't exist.
+func Unmarshal(data []byte) (*Bytecode, error) {
+	if !IsModule(data) {
+		return nil, ErrNotModule
+	}
+	d := &decoder{data: data[len(moduleMagic):]}
+	if version := d.uvarint(); d.err == nil && version != ModuleVersion {
+		return nil, fmt.Errorf("module version %d, expected %d", version,
+			ModuleVersion)
+	}
+
+	b := &Bytecode{}
+	b.Globals = d.strings()
+	b.Builtins = d.strings()
+
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		switch tag := d.byte(); tag {
+		case integerTag:
+			b.Constants = append(b.Constants, &object.Integer{Value: d.varint()})
+		case stringTag:
+			b.Constants = append(b.Constants, &object.String{Value: d.string()})
+		case functionTag:
+			name := d.string()
+			fn := d.function()
+			fn.Name = name
+			b.Constants = append(b.Constants, fn)
+		default:
+			d.fail("constant %d: unknown tag %q", i, tag)
+		}
+	}
+
+	main := d.function()
+	b.Instructions, b.Lines = main.Instructions, main.Lines
+	if d.err == nil && len(d.data) != 0 {
+		d.fail("%d bytes after end of module", len(d.data))
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("malformed module: %s", d.err)
+	}
+
+	v := &validator{
+		b:      b,
+		main:   main,
+		chains: map[*object.CompiledFunction][]*object.CompiledFunction{},
+	}
+	if err := v.validate(main, nil); err != nil {
+		return nil, fmt.Errorf("malformed module: %s", err)
+	}
+	return b, nil
+}
+
+// decoder reads the parts of a module from data, recording the first error.
+// Once there is an error, it reads only zero values.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, a...)
+	}
+}
+
+func (d *decoder) uvarint() int {
+	if d.err != nil {
+		return 0
+	}
+	n, read := binary.Uvarint(d.data)
+	if read <= 0 || n > math.MaxInt32 {
+		d.fail("truncated module or invalid number")
+		return 0
+	}
+	d.data = d.data[read:]
+	return int(n)
+}
+
+// count reads the number of the items that follow, each of which takes at
+// least one byte.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > len(d.data) {
+		d.fail("truncated module")
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	n, read := binary.Varint(d.data)
+	if read <= 0 {
+		d.fail("truncated module or invalid number")
+		return 0
+	}
+	d.data = d.data[read:]
+	return n
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) == 0 {
+		d.fail("truncated module")
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	if d.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, d.data)
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) strings() []string {
+	n := d.count()
+	var ss []string
+	for i := 0; i < n && d.err == nil; i++ {
+		ss = append(ss, d.string())
+	}
+	return ss
+}
+
+func (d *decoder) function() *object.CompiledFunction {
+	fn := &object.CompiledFunction{}
+	fn.NumParameters = d.uvarint()
+	fn.Locals = d.strings()
+	fn.Instructions = d.bytes()
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		offset := d.uvarint()
+		line := d.uvarint()
+		column := d.uvarint()
+		fn.Lines = append(fn.Lines, code.LineEntry{
+			Offset: offset,
+			Pos:    token.Position{Line: line, Column: column},
+		})
+	}
+	if fn.NumParameters > len(fn.Locals) {
+		d.fail("function has %d parameters but %d locals", fn.NumParameters,
+			len(fn.Locals))
+	}
+	return fn
+}
+
+// validator checks that the instructions of the functions in a module are
+// defined and refer only to constants, variables and offsets that exist.
+type validator struct {
+	b    *Bytecode
+	main *object.CompiledFunction // the top level of the program
+	// chains holds, for each function validated, the functions enclosing
+	// it, innermost first, not counting the top level of the program.
+	chains map[*object.CompiledFunction][]*object.CompiledFunction
+}
+
+// validate checks fn, which is defined in the functions enclosing, and then
+// the functions fn defines.
+func (v *validator) validate(fn *object.CompiledFunction,
+	enclosing []*object.CompiledFunction) error {
+	name := fn.Name
+	if name == "" {
+		name = "fn"
+	}
+	if chain, ok := v.chains[fn]; ok {
+		if !sameFunctions(chain, enclosing) {
+			return fmt.Errorf("function %s defined in more than one place",
+				name)
+		}
+		return nil
+	}
+	v.chains[fn] = enclosing
+
+	ins := fn.Instructions
+	starts := map[int]bool{}
+	var jumps []int
+	var closures []*object.CompiledFunction
+	var lastOp code.Opcode
+
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return fmt.Errorf("%s: offset %d: %s", name, i, err)
+		}
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if i+1+width > len(ins) {
+			return fmt.Errorf("%s: offset %d: truncated %s", name, i, def.Name)
+		}
+		operands, _ := code.ReadOperands(def, ins[i+1:])
+
+		limit := -1
+		switch lastOp = code.Opcode(ins[i]); lastOp {
+		case code.OpConstant:
+			limit = len(v.b.Constants)
+		case code.OpClosure:
+			limit = len(v.b.Constants)
+			if operands[0] < limit {
+				c := v.b.Constants[operands[0]]
+				f, ok := c.(*object.CompiledFunction)
+				if !ok {
+					return fmt.Errorf("%s: offset %d: closure of %s", name, i,
+						c.Type())
+				}
+				closures = append(closures, f)
+			}
+		case code.OpGetGlobal, code.OpSetGlobal:
+			limit = len(v.b.Globals)
+		case code.OpGetLocal, code.OpSetLocal:
+			limit = len(fn.Locals)
+		case code.OpGetFree:
+			depth := operands[0]
+			if depth >= len(enclosing) ||
+				operands[1] >= len(enclosing[depth].Locals) {
+				return fmt.Errorf("%s: offset %d: no variable %d at depth %d",
+					name, i, operands[1], depth)
+			}
+		case code.OpGetBuiltin:
+			limit = len(v.b.Builtins)
+		case code.OpJump, code.OpJumpNotTruthy:
+			jumps = append(jumps, operands[0])
+		}
+		if limit >= 0 && operands[0] >= limit {
+			return fmt.Errorf("%s: offset %d: %s operand %d out of range", name,
+				i, def.Name, operands[0])
+		}
+
+		starts[i] = true
+		i += 1 + width
+	}
+
+	for _, target := range jumps {
+		if !starts[target] {
+			return fmt.Errorf("%s: jump to offset %d, which is not an "+
+				"instruction", name, target)
+		}
+	}
+	// Make sure the virtual machine can't run past the end of the function.
+	if lastOp != code.OpReturn && lastOp != code.OpReturnValue {
+		return fmt.Errorf("%s: instructions don't end with a return", name)
+	}
+
+	// The top level of the program has no locals, so the functions defined
+	// there have no enclosing functions.
+	var inner []*object.CompiledFunction
+	if fn != v.main {
+		inner = append([]*object.CompiledFunction{fn}, enclosing...)
+	}
+	for _, c := range closures {
+		if err := v.validate(c, inner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameFunctions(a, b []*object.CompiledFunction) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+package compiler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adamvinueza/monkey/code"
+	"github.com/adamvinueza/monkey/object"
+)
+
+const moduleInput = `let greeting = "hello";
+let make = fn(n) {
+  let add = fn(x) { x + n };
+  add
+};
+puts(greeting, make(-2)(1));
+if (len(greeting) > 3) { make } else { 0 }`
+
+func compileModule(t *testing.T, input string) *Bytecode {
+	t.Helper()
+	c := New()
+	if err := c.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return c.Bytecode()
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	b := compileModule(t, moduleInput)
+
+	data, err := Marshal(b)
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err)
+	}
+	if !IsModule(data) {
+		t.Fatalf("IsModule is false for marshaled module")
+	}
+
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %s", err)
+	}
+	if !reflect.DeepEqual(b, decoded) {
+		t.Errorf("decoded module differs.\nexpected=%+v\nfound=%+v", b, decoded)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	data, err := Marshal(compileModule(t, moduleInput))
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		expectedErr string
+	}{
+		{"source text", []byte("let x = 1;"), ErrNotModule.Error()},
+		{"empty", nil, ErrNotModule.Error()},
+		{"wrong version", append([]byte(moduleMagic), 2),
+			"module version 2, expected 1"},
+		{"truncated", data[:len(data)-1], "malformed module: truncated module"},
+		{"trailing bytes", append(append([]byte{}, data...), 0),
+			"malformed module: 1 bytes after end of module"},
+	}
+
+	for _, tt := range tests {
+		_, err := Unmarshal(tt.data)
+		if err == nil {
+			t.Errorf("%s: expected error %q, found none", tt.name,
+				tt.expectedErr)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.expectedErr) {
+			t.Errorf("%s: wrong error. expected=%q, found=%q", tt.name,
+				tt.expectedErr, err.Error())
+		}
+	}
+
+	// No prefix of a module is a module, and decoding one must not panic.
+	for i := len(moduleMagic); i < len(data); i++ {
+		if _, err := Unmarshal(data[:i]); err == nil {
+			t.Errorf("Unmarshal of %d of %d bytes succeeded", i, len(data))
+		}
+	}
+}
+
+func TestUnmarshalValidates(t *testing.T) {
+	fn := func(ins ...[]byte) *object.CompiledFunction {
+		return &object.CompiledFunction{
+			Instructions: concatInstructions(toInstructions(ins)),
+			Locals:       []string{"a"},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		bytecode    *Bytecode
+		expectedErr string
+	}{
+		{"undefined opcode", &Bytecode{
+			Instructions: code.Instructions{255},
+		}, "malformed module: fn: offset 0: opcode 255 undefined"},
+		{"truncated instruction", &Bytecode{
+			Instructions: code.Make(code.OpConstant, 0)[:2],
+		}, "malformed module: fn: offset 0: truncated OpConstant"},
+		{"missing constant", &Bytecode{
+			Instructions: concatInstructions([]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpReturnValue),
+			}),
+		}, "malformed module: fn: offset 0: OpConstant operand 0 out of range"},
+		{"missing global", &Bytecode{
+			Instructions: concatInstructions([]code.Instructions{
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpReturnValue),
+			}),
+			Globals: []string{"a"},
+		}, "malformed module: fn: offset 0: OpGetGlobal operand 1 out of range"},
+		{"bad jump", &Bytecode{
+			Instructions: concatInstructions([]code.Instructions{
+				code.Make(code.OpJump, 1),
+				code.Make(code.OpReturn),
+			}),
+		}, "malformed module: fn: jump to offset 1, which is not an instruction"},
+		{"no return", &Bytecode{
+			Instructions: code.Make(code.OpNull),
+		}, "malformed module: fn: instructions don't end with a return"},
+		{"closure of integer", &Bytecode{
+			Instructions: concatInstructions([]code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpReturnValue),
+			}),
+			Constants: []object.Object{&object.Integer{Value: 1}},
+		}, "malformed module: fn: offset 0: closure of INTEGER"},
+		{"free variable at top level", &Bytecode{
+			Instructions: concatInstructions([]code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpReturnValue),
+			}),
+			Constants: []object.Object{fn(
+				code.Make(code.OpGetFree, 0, 0),
+				code.Make(code.OpReturnValue),
+			)},
+		}, "malformed module: fn: offset 0: no variable 0 at depth 0"},
+		{"missing local", &Bytecode{
+			Instructions: concatInstructions([]code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpReturnValue),
+			}),
+			Constants: []object.Object{fn(
+				code.Make(code.OpGetLocal, 1),
+				code.Make(code.OpReturnValue),
+			)},
+		}, "malformed module: fn: offset 0: OpGetLocal operand 1 out of range"},
+	}
+
+	for _, tt := range tests {
+		data, err := Marshal(tt.bytecode)
+		if err != nil {
+			t.Fatalf("%s: Marshal failed: %s", tt.name, err)
+		}
+		_, err = Unmarshal(data)
+		if err == nil {
+			t.Errorf("%s: expected error %q, found none", tt.name,
+				tt.expectedErr)
+			continue
+		}
+		if err.Error() != tt.expectedErr {
+			t.Errorf("%s: wrong error. expected=%q, found=%q", tt.name,
+				tt.expectedErr, err.Error())
+		}
+	}
+}
+
+func toInstructions(bs [][]byte) []code.Instructions {
+	ins := make([]code.Instructions, len(bs))
+	for i, b := range bs {
+		ins[i] = b
+	}
+	return ins
+}
@@ -0,0 +1,372 @@
+package compiler
+
+import (
+	"github.com/adamvinueza/monkey/code"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// Peephole returns a version of b whose instructions do the same with less
+// work. It rewrites short sequences of redundant instructions the compiler
+// emits:
+//   - A jump to a jump is made to the second jump's target, and a jump to a
+//     return is replaced by the return.
+//   - A jump to the next instruction is removed.
+//   - Instructions that can't be reached are removed.
+//   - A value pushed and immediately popped is not pushed, if pushing it
+//     can't fail.
+//   - A constant loaded again right after it is loaded is duplicated instead.
+//
+// It also gives equal integer and string constants a single index, dropping
+// the constants no longer used. Jump targets and line tables are adjusted to
+// match. Peephole doesn't modify b, which must be valid, as bytecode produced
+// by the compiler or by Unmarshal is.
+func Peephole(b *Bytecode) *Bytecode {
+	p := &peephole{
+		b:         b,
+		functions: map[*object.CompiledFunction]*listing{},
+	}
+	p.dedupConstants()
+
+	main := p.optimize(b.Instructions, b.Lines)
+	p.markUsed(main)
+	p.renumberConstants()
+
+	result := *b
+	result.Instructions, result.Lines = p.encode(main)
+	result.Constants = p.constants
+	return &result
+}
+
+// instruction is an instruction being optimized.
+type instruction struct {
+	op       code.Opcode
+	operands []int
+	pos      token.Position
+	// target is the index of the instruction a jump jumps to, which is the
+	// length of the listing for a jump to the end.
+	target int
+}
+
+// listing holds the instructions of a function.
+type listing []instruction
+
+type peephole struct {
+	b *Bytecode
+	// same maps the index of each constant of b to the index of the first
+	// constant equal to it.
+	same []int
+	// functions holds the optimized instructions of the functions of b.
+	functions map[*object.CompiledFunction]*listing
+	// used records which constants of b are used by optimized instructions,
+	// and index maps them to their indexes in constants.
+	used      []bool
+	index     []int
+	constants []object.Object
+}
+
+// dedupConstants finds the first of the constants equal to each constant.
+func (p *peephole) dedupConstants() {
+	type key struct {
+		t     object.ObjectType
+		value interface{}
+	}
+	first := map[key]int{}
+	p.same = make([]int, len(p.b.Constants))
+	for i, c := range p.b.Constants {
+		p.same[i] = i
+		var k key
+		switch c := c.(type) {
+		case *object.Integer:
+			k = key{c.Type(), c.Value}
+		case *object.String:
+			k = key{c.Type(), c.Value}
+		default:
+			continue
+		}
+		if j, ok := first[k]; ok {
+			p.same[i] = j
+		} else {
+			first[k] = i
+		}
+	}
+}
+
+// optimize returns the optimized instructions of a function.
+func (p *peephole) optimize(ins code.Instructions,
+	lines code.LineTable) listing {
+	l := decode(ins, lines)
+	for i := range l {
+		if l[i].op == code.OpConstant {
+			l[i].operands = []int{p.same[l[i].operands[0]]}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, pass := range []func(listing) (listing, bool){
+			threadJumps, removeJumpsToNext, removeUnreachable, removePushPop,
+			dupConstants,
+		} {
+			var c bool
+			l, c = pass(l)
+			changed = changed || c
+		}
+	}
+	return l
+}
+
+// decode returns the listing of ins, whose positions are given by lines.
+func decode(ins code.Instructions, lines code.LineTable) listing {
+	var l listing
+	index := map[int]int{} // the index of the instruction at each offset
+	for offset := 0; offset < len(ins); {
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			panic(err) // the compiler emitted an undefined opcode
+		}
+		operands, read := code.ReadOperands(def, ins[offset+1:])
+		index[offset] = len(l)
+		l = append(l, instruction{
+			op:       code.Opcode(ins[offset]),
+			operands: operands,
+			pos:      lines.Pos(offset),
+		})
+		offset += 1 + read
+	}
+	index[len(ins)] = len(l)
+	for i := range l {
+		if isJump(l[i].op) {
+			l[i].target = index[l[i].operands[0]]
+		}
+	}
+	return l
+}
+
+func isJump(op code.Opcode) bool {
+	return op == code.OpJump || op == code.OpJumpNotTruthy
+}
+
+// isPure reports whether op pushes a value without popping any or failing.
+func isPure(op code.Opcode) bool {
+	switch op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull,
+		code.OpClosure:
+		return true
+	}
+	return false
+}
+
+// targets returns the set of indexes of the instructions jumped to in l.
+func (l listing) targets() map[int]bool {
+	targets := map[int]bool{}
+	for _, ins := range l {
+		if isJump(ins.op) {
+			targets[ins.target] = true
+		}
+	}
+	return targets
+}
+
+// remove returns l without the instructions whose indexes are in removed.
+// Jumps to a removed instruction are made to the next instruction that isn't
+// removed.
+func (l listing) remove(removed map[int]bool) listing {
+	if len(removed) == 0 {
+		return l
+	}
+	index := make([]int, len(l)+1)
+	result := listing{}
+	for i := len(l); i >= 0; i-- {
+		if i == len(l) || !removed[i] {
+			index[i] = i
+		} else {
+			index[i] = index[i+1]
+		}
+	}
+	newIndex := make([]int, len(l)+1)
+	for i := range l {
+		newIndex[i] = len(result)
+		if !removed[i] {
+			result = append(result, l[i])
+		}
+	}
+	newIndex[len(l)] = len(result)
+	for i := range result {
+		if isJump(result[i].op) {
+			result[i].target = newIndex[index[result[i].target]]
+		}
+	}
+	return result
+}
+
+// threadJumps makes jumps to unconditional jumps jump to the final target, and
+// replaces unconditional jumps to returns with the returns.
+func threadJumps(l listing) (listing, bool) {
+	changed := false
+	for i := range l {
+		if !isJump(l[i].op) {
+			continue
+		}
+		target := l[i].target
+		for n := 0; n < len(l) && target < len(l) &&
+			l[target].op == code.OpJump && l[target].target != target; n++ {
+			target = l[target].target
+		}
+		if target != l[i].target {
+			l[i].target = target
+			changed = true
+		}
+		if l[i].op == code.OpJump && target < len(l) {
+			switch op := l[target].op; op {
+			case code.OpReturnValue, code.OpReturn:
+				l[i] = instruction{op: op, pos: l[i].pos}
+				changed = true
+			}
+		}
+	}
+	return l, changed
+}
+
+// removeJumpsToNext removes unconditional jumps to the next instruction, and
+// replaces conditional ones with pops of the condition.
+func removeJumpsToNext(l listing) (listing, bool) {
+	removed := map[int]bool{}
+	changed := false
+	for i := range l {
+		if !isJump(l[i].op) || l[i].target != i+1 {
+			continue
+		}
+		if l[i].op == code.OpJump {
+			removed[i] = true
+		} else {
+			l[i] = instruction{op: code.OpPop, pos: l[i].pos}
+		}
+		changed = true
+	}
+	return l.remove(removed), changed
+}
+
+// removeUnreachable removes the instructions following a jump or return that
+// aren't jumped to.
+func removeUnreachable(l listing) (listing, bool) {
+	targets := l.targets()
+	removed := map[int]bool{}
+	reachable := true
+	for i := range l {
+		if targets[i] {
+			reachable = true
+		}
+		if !reachable {
+			removed[i] = true
+			continue
+		}
+		switch l[i].op {
+		case code.OpJump, code.OpReturnValue, code.OpReturn:
+			reachable = false
+		}
+	}
+	return l.remove(removed), len(removed) > 0
+}
+
+// removePushPop removes instructions pushing values that are immediately
+// popped, along with the pops.
+func removePushPop(l listing) (listing, bool) {
+	targets := l.targets()
+	removed := map[int]bool{}
+	for i := 0; i+1 < len(l); i++ {
+		if isPure(l[i].op) && l[i+1].op == code.OpPop && !targets[i+1] {
+			removed[i], removed[i+1] = true, true
+			i++
+		}
+	}
+	return l.remove(removed), len(removed) > 0
+}
+
+// dupConstants replaces loads of the constant just loaded with duplications.
+func dupConstants(l listing) (listing, bool) {
+	targets := l.targets()
+	changed := false
+	for i := 1; i < len(l); i++ {
+		if l[i].op == code.OpConstant && l[i-1].op == code.OpConstant &&
+			l[i].operands[0] == l[i-1].operands[0] && !targets[i] {
+			l[i] = instruction{op: code.OpDup, pos: l[i].pos}
+			changed = true
+		}
+	}
+	return l, changed
+}
+
+// markUsed records the constants used by l and by the functions it creates,
+// optimizing those functions.
+func (p *peephole) markUsed(l listing) {
+	if p.used == nil {
+		p.used = make([]bool, len(p.b.Constants))
+	}
+	for _, ins := range l {
+		switch ins.op {
+		case code.OpConstant:
+			p.used[ins.operands[0]] = true
+		case code.OpClosure:
+			index := ins.operands[0]
+			p.used[index] = true
+			fn, ok := p.b.Constants[index].(*object.CompiledFunction)
+			if !ok || p.functions[fn] != nil {
+				continue
+			}
+			optimized := p.optimize(fn.Instructions, fn.Lines)
+			p.functions[fn] = &optimized
+			p.markUsed(optimized)
+		}
+	}
+}
+
+// renumberConstants gives the used constants new indexes, and makes the
+// optimized versions of the functions among them.
+func (p *peephole) renumberConstants() {
+	p.index = make([]int, len(p.b.Constants))
+	for i, c := range p.b.Constants {
+		if !p.used[i] {
+			continue
+		}
+		p.index[i] = len(p.constants)
+		p.constants = append(p.constants, c)
+	}
+	for i, c := range p.constants {
+		fn, ok := c.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		optimized := *fn
+		optimized.Instructions, optimized.Lines = p.encode(*p.functions[fn])
+		p.constants[i] = &optimized
+	}
+}
+
+// encode returns the instructions of l and their line table.
+func (p *peephole) encode(l listing) (code.Instructions, code.LineTable) {
+	offsets := make([]int, len(l)+1)
+	offset := 0
+	for i, ins := range l {
+		offsets[i] = offset
+		offset += len(code.Make(ins.op, ins.operands...))
+	}
+	offsets[len(l)] = offset
+
+	ins := code.Instructions{}
+	lines := code.LineTable{}
+	for i, in := range l {
+		operands := in.operands
+		switch {
+		case isJump(in.op):
+			operands = []int{offsets[in.target]}
+		case in.op == code.OpConstant || in.op == code.OpClosure:
+			operands = []int{p.index[operands[0]]}
+		}
+		if len(lines) == 0 || lines[len(lines)-1].Pos != in.pos {
+			lines = append(lines, code.LineEntry{Offset: offsets[i],
+				Pos: in.pos})
+		}
+		ins = append(ins, code.Make(in.op, operands...)...)
+	}
+	return ins, lines
+}
@@ -0,0 +1,169 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/adamvinueza/monkey/code"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/token"
+)
+
+func TestPeephole(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1; 2",
+			expectedConstants: []interface{}{2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "let x = 3; x * 3",
+			expectedConstants: []interface{}{3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMul),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             `"a" + "a"`,
+			expectedConstants: []interface{}{"a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpDup),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "x; true; y",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetBuiltin, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "if (x) { if (y) { 1 } else { 2 } } else { 3 }",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpGetBuiltin, 0),
+				// 0002
+				code.Make(code.OpJumpNotTruthy, 18),
+				// 0005
+				code.Make(code.OpGetBuiltin, 1),
+				// 0007
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0010
+				code.Make(code.OpConstant, 0),
+				// 0013
+				code.Make(code.OpReturnValue),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpReturnValue),
+				// 0018
+				code.Make(code.OpConstant, 2),
+				// 0021
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn(n) { 1; if (n) { return n; } 2 }",
+			expectedConstants: []interface{}{
+				2,
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpGetLocal, 0),
+					// 0002
+					code.Make(code.OpJumpNotTruthy, 8),
+					// 0005
+					code.Make(code.OpGetLocal, 0),
+					// 0007
+					code.Make(code.OpReturnValue),
+					// 0008
+					code.Make(code.OpConstant, 0),
+					// 0011
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		if err := compiler.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := Peephole(compiler.Bytecode())
+
+		err := testInstructions(tt.expectedInstructions, bytecode.Instructions)
+		if err != nil {
+			t.Fatalf("testInstructions failed for %q: %s", tt.input, err)
+		}
+		err = testConstants(tt.expectedConstants, bytecode.Constants)
+		if err != nil {
+			t.Fatalf("testConstants failed for %q: %s", tt.input, err)
+		}
+	}
+}
+
+func TestPeepholeKeepsPositions(t *testing.T) {
+	input := "let f = fn(x) {\n  1;\n  if (true) { x / 0 } else { 2 }\n};\nf(1)"
+	original := compileModule(t, input)
+	before := original.Instructions.String()
+	optimized := Peephole(original)
+
+	if after := original.Instructions.String(); after != before {
+		t.Errorf("Peephole modified its argument.\nbefore=%s\nafter=%s",
+			before, after)
+	}
+
+	data, err := Marshal(optimized)
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err)
+	}
+	if _, err := Unmarshal(data); err != nil {
+		t.Fatalf("optimized bytecode is invalid: %s", err)
+	}
+
+	var fn *object.CompiledFunction
+	for _, c := range optimized.Constants {
+		if f, ok := c.(*object.CompiledFunction); ok {
+			fn = f
+		}
+	}
+	if fn == nil {
+		t.Fatalf("no function in constants")
+	}
+	ins := fn.Instructions
+	for offset := 0; offset < len(ins); {
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code.Opcode(ins[offset]) == code.OpDiv {
+			expected := token.Position{Line: 3, Column: 15}
+			if pos := fn.Lines.Pos(offset); pos != expected {
+				t.Errorf("wrong position of OpDiv. expected=%s, found=%s",
+					expected, pos)
+			}
+			return
+		}
+		_, read := code.ReadOperands(def, ins[offset+1:])
+		offset += 1 + read
+	}
+	t.Errorf("no OpDiv in optimized function:\n%s", ins)
+}
@@ -0,0 +1,141 @@
+package compiler
+
+// SymbolScope identifies where the value of a variable is stored.
+type SymbolScope string
+
+const (
+	GlobalScope  SymbolScope = "GLOBAL"  // bound at the top level
+	LocalScope   SymbolScope = "LOCAL"   // bound by the current function
+	FreeScope    SymbolScope = "FREE"    // bound by an enclosing function
+	BuiltinScope SymbolScope = "BUILTIN" // not bound by the program
+)
+
+// Symbol describes a variable.
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// Depth is, for a variable in FreeScope, the number of functions between
+	// the current function and the one that binds the variable.
+	Depth int
+}
+
+// SymbolTable holds the variables of a function, or, if it has no Outer
+// table, those of the top level of a program along with the builtins it uses.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store   map[string]Symbol
+	visible map[string]bool
+	names   []string
+
+	builtins     map[string]Symbol
+	builtinNames []string
+}
+
+// NewSymbolTable returns a symbol table for the top level of a program.
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		store:    make(map[string]Symbol),
+		visible:  make(map[string]bool),
+		builtins: make(map[string]Symbol),
+	}
+}
+
+// NewEnclosedSymbolTable returns a symbol table for a function defined in the
+// scope of outer.
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	s.builtins = nil
+	return s
+}
+
+// Declare reserves a variable for name, if there isn't one already, without
+// making it visible to Resolve in s, only in the tables s encloses. Compiling
+// a function declares all the variables it binds before compiling its body.
+func (s *SymbolTable) Declare(name string) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+	return s.add(name)
+}
+
+// Define declares name and makes it visible to Resolve in s.
+func (s *SymbolTable) Define(name string) Symbol {
+	s.visible[name] = true
+	return s.Declare(name)
+}
+
+// DefineParameter is like Define, but always reserves a new variable, so that
+// each parameter of a function has its own, even if they share a name.
+func (s *SymbolTable) DefineParameter(name string) Symbol {
+	s.visible[name] = true
+	return s.add(name)
+}
+
+func (s *SymbolTable) add(name string) Symbol {
+	symbol := Symbol{Name: name, Index: len(s.names)}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+	s.store[name] = symbol
+	s.names = append(s.names, name)
+	return symbol
+}
+
+// DefineBuiltin returns the symbol for the builtin called name, adding it to
+// the builtins of the program if necessary.
+func (s *SymbolTable) DefineBuiltin(name string) Symbol {
+	root := s
+	for root.Outer != nil {
+		root = root.Outer
+	}
+	if symbol, ok := root.builtins[name]; ok {
+		return symbol
+	}
+	symbol := Symbol{Name: name, Scope: BuiltinScope,
+		Index: len(root.builtinNames)}
+	root.builtins[name] = symbol
+	root.builtinNames = append(root.builtinNames, name)
+	return symbol
+}
+
+// Resolve returns the symbol for the variable called name: the one defined in
+// s, if it is visible, or else the one declared in the innermost table
+// enclosing s, or else the builtin of that name, if it has been defined. The
+// result reports whether a symbol was found.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	if s.visible[name] {
+		return s.store[name], true
+	}
+
+	depth := 0
+	root := s
+	for t := s.Outer; t != nil; t = t.Outer {
+		if symbol, ok := t.store[name]; ok {
+			if symbol.Scope == LocalScope {
+				symbol.Scope = FreeScope
+				symbol.Depth = depth
+			}
+			return symbol, true
+		}
+		depth++
+		root = t
+	}
+
+	symbol, ok := root.builtins[name]
+	return symbol, ok
+}
+
+// Names returns the names of the variables in s, by index.
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
+
+// BuiltinNames returns the names of the builtins defined in s, by index.
+func (s *SymbolTable) BuiltinNames() []string {
+	return s.builtinNames
+}
@@ -0,0 +1,135 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+		"e": {Name: "e", Scope: LocalScope, Index: 0},
+		"f": {Name: "f", Scope: LocalScope, Index: 1},
+	}
+
+	global := NewSymbolTable()
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("expected a=%+v, found=%+v", expected["a"], a)
+	}
+	if b := global.Define("b"); b != expected["b"] {
+		t.Errorf("expected b=%+v, found=%+v", expected["b"], b)
+	}
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("redefining a: expected a=%+v, found=%+v", expected["a"], a)
+	}
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	if c := firstLocal.Define("c"); c != expected["c"] {
+		t.Errorf("expected c=%+v, found=%+v", expected["c"], c)
+	}
+	if d := firstLocal.Define("d"); d != expected["d"] {
+		t.Errorf("expected d=%+v, found=%+v", expected["d"], d)
+	}
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	if e := secondLocal.Define("e"); e != expected["e"] {
+		t.Errorf("expected e=%+v, found=%+v", expected["e"], e)
+	}
+	if f := secondLocal.Define("f"); f != expected["f"] {
+		t.Errorf("expected f=%+v, found=%+v", expected["f"], f)
+	}
+}
+
+func TestDefineParameter(t *testing.T) {
+	local := NewEnclosedSymbolTable(NewSymbolTable())
+	local.DefineParameter("x")
+	second := local.DefineParameter("x")
+
+	expected := Symbol{Name: "x", Scope: LocalScope, Index: 1}
+	if second != expected {
+		t.Errorf("expected x=%+v, found=%+v", expected, second)
+	}
+	if resolved, ok := local.Resolve("x"); !ok || resolved != expected {
+		t.Errorf("x resolved wrong. expected=%+v, found=%+v", expected,
+			resolved)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.Declare("b")
+	global.DefineBuiltin("len")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("c")
+	firstLocal.Declare("d")
+	firstLocal.Define("a")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("e")
+
+	tests := []struct {
+		table    *SymbolTable
+		name     string
+		expected Symbol
+		found    bool
+	}{
+		{global, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}, true},
+		// A declared variable isn't visible in its own scope until defined.
+		{global, "b", Symbol{}, false},
+		{global, "len", Symbol{Name: "len", Scope: BuiltinScope, Index: 0},
+			true},
+		{global, "x", Symbol{}, false},
+		{firstLocal, "a", Symbol{Name: "a", Scope: LocalScope, Index: 2}, true},
+		{firstLocal, "b", Symbol{Name: "b", Scope: GlobalScope, Index: 1},
+			true},
+		{firstLocal, "c", Symbol{Name: "c", Scope: LocalScope, Index: 0}, true},
+		{firstLocal, "d", Symbol{}, false},
+		{firstLocal, "len", Symbol{Name: "len", Scope: BuiltinScope, Index: 0},
+			true},
+		{secondLocal, "a", Symbol{Name: "a", Scope: FreeScope, Index: 2}, true},
+		{secondLocal, "c", Symbol{Name: "c", Scope: FreeScope, Index: 0}, true},
+		{secondLocal, "d", Symbol{Name: "d", Scope: FreeScope, Index: 1}, true},
+		{secondLocal, "e", Symbol{Name: "e", Scope: LocalScope, Index: 0}, true},
+	}
+
+	for _, tt := range tests {
+		result, ok := tt.table.Resolve(tt.name)
+		if ok != tt.found {
+			t.Errorf("name %s: expected found=%t, found=%t", tt.name, tt.found,
+				ok)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, found=%+v", tt.name,
+				tt.expected, result)
+		}
+	}
+
+	third := NewEnclosedSymbolTable(NewEnclosedSymbolTable(secondLocal))
+	expected := Symbol{Name: "c", Scope: FreeScope, Index: 0, Depth: 2}
+	if result, _ := third.Resolve("c"); result != expected {
+		t.Errorf("expected c to resolve to %+v, found=%+v", expected, result)
+	}
+}
+
+func TestDefineBuiltin(t *testing.T) {
+	global := NewSymbolTable()
+	local := NewEnclosedSymbolTable(global)
+
+	first := local.DefineBuiltin("len")
+	second := global.DefineBuiltin("puts")
+	again := local.DefineBuiltin("len")
+
+	if first != again {
+		t.Errorf("len defined twice: %+v, %+v", first, again)
+	}
+	if second.Index != 1 {
+		t.Errorf("wrong index for puts. expected=1, found=%d", second.Index)
+	}
+	names := global.BuiltinNames()
+	if len(names) != 2 || names[0] != "len" || names[1] != "puts" {
+		t.Errorf("wrong builtin names: %v", names)
+	}
+}
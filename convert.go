@@ -0,0 +1,250 @@
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/object"
+)
+
+// Func is the type of Go functions that can be called from Monkey, and of
+// Monkey functions converted for calling from Go.
+type Func func(args ...interface{}) (interface{}, error)
+
+// ToObject converts a Go value to a Monkey value:
+//  - nil becomes null
+//  - booleans become booleans
+//  - integers of every size become integers (an unsigned integer too large
+//    for an int64 is an error)
+//  - strings become strings
+//  - slices and arrays become arrays
+//  - maps with integer, boolean or string keys become hashes
+//  - a Func becomes a builtin, which converts its arguments as by FromObject
+//    and its result as by ToObject, and turns a non-nil error into a Monkey
+//    error
+//  - an object.Object is returned as is
+// Pointers and interfaces are converted by converting what they point to.
+// Other values, such as floats and structs, cannot be converted.
+func ToObject(v interface{}) (object.Object, error) {
+	switch v := v.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case object.Object:
+		return v, nil
+	case Func:
+		return goBuiltin(v), nil
+	case func(args ...interface{}) (interface{}, error):
+		return goBuiltin(v), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: rv.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows a Monkey integer", rv.Uint())
+		}
+		return &object.Integer{Value: int64(rv.Uint())}, nil
+	case reflect.String:
+		return &object.String{Value: rv.String()}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, rv.Len())
+		for i := range elements {
+			elem, err := ToObject(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		pairs := make(map[object.HashKey]object.HashPair, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := ToObject(iter.Key().Interface())
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			value, err := ToObject(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return ToObject(rv.Elem().Interface())
+	}
+	return nil, fmt.Errorf("cannot convert %T to a Monkey value", v)
+}
+
+// FromObject converts a Monkey value to a Go value:
+//  - null becomes nil
+//  - booleans become bools
+//  - integers become int64s
+//  - strings become strings
+//  - arrays become []interface{}s
+//  - hashes whose keys are all strings become map[string]interface{}s, and
+//    other hashes become map[interface{}]interface{}s
+//  - functions and builtins become Funcs, which convert their arguments as by
+//    ToObject and their result as by FromObject, and return a Monkey error as
+//    a *RuntimeError
+// Other values, such as errors, cannot be converted.
+func FromObject(obj object.Object) (interface{}, error) {
+	return fromObject(obj, applyUnder(evaluator.Limits{}))
+}
+
+// fromObject converts obj as described for FromObject. Monkey functions in obj
+// are called with apply.
+func fromObject(obj object.Object, apply object.Applier) (interface{}, error) {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, e := range obj.Elements {
+			elem, err := fromObject(e, apply)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return elements, nil
+	case *object.Hash:
+		return hashFromObject(obj, apply)
+	case *object.Function, *object.Builtin:
+		return monkeyFunc(obj, apply), nil
+	}
+	return nil, fmt.Errorf("cannot convert %s to a Go value", obj.Type())
+}
+
+func hashFromObject(hash *object.Hash,
+	apply object.Applier) (interface{}, error) {
+	allStrings := true
+	for _, pair := range hash.Pairs {
+		if pair.Key.Type() != object.STRING_OBJ {
+			allStrings = false
+		}
+	}
+
+	stringMap := make(map[string]interface{}, len(hash.Pairs))
+	anyMap := make(map[interface{}]interface{}, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		key, err := fromObject(pair.Key, apply)
+		if err != nil {
+			return nil, err
+		}
+		value, err := fromObject(pair.Value, apply)
+		if err != nil {
+			return nil, err
+		}
+		if allStrings {
+			stringMap[key.(string)] = value
+		} else {
+			anyMap[key] = value
+		}
+	}
+	if allStrings {
+		return stringMap, nil
+	}
+	return anyMap, nil
+}
+
+// goBuiltin returns a builtin that calls fn.
+func goBuiltin(fn Func) *object.Builtin {
+	call := func(apply object.Applier, args ...object.Object) object.Object {
+		goArgs := make([]interface{}, len(args))
+		for i, arg := range args {
+			goArg, err := fromObject(arg, apply)
+			if err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+			goArgs[i] = goArg
+		}
+		result, err := fn(goArgs...)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		obj, err := ToObject(result)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		return obj
+	}
+	return &object.Builtin{
+		Name: "go function",
+		Fn: func(args ...object.Object) object.Object {
+			return call(applyBuiltin, args...)
+		},
+		Apply: call,
+	}
+}
+
+// monkeyFunc returns a Func that calls fn, which is a function or builtin,
+// with apply.
+func monkeyFunc(fn object.Object, apply object.Applier) Func {
+	return func(args ...interface{}) (interface{}, error) {
+		objArgs := make([]object.Object, len(args))
+		for i, arg := range args {
+			obj, err := ToObject(arg)
+			if err != nil {
+				return nil, err
+			}
+			objArgs[i] = obj
+		}
+		result, err := apply(fn, objArgs)
+		if err != nil {
+			return nil, err
+		}
+		if errObj, ok := result.(*object.Error); ok {
+			return nil, &RuntimeError{Err: errObj}
+		}
+		return fromObject(result, apply)
+	}
+}
+
+// applyUnder returns an Applier that calls functions in new evaluations under
+// limits, for functions called after the evaluation that made them.
+func applyUnder(limits evaluator.Limits) object.Applier {
+	return func(fn object.Object, args []object.Object) (object.Object, error) {
+		return evaluator.ApplyContext(context.Background(), fn, args, limits)
+	}
+}
+
+// applyBuiltin is the Applier of builtins called by engines other than the
+// evaluator. Their functions can't be converted to Funcs, so it only calls
+// builtins.
+func applyBuiltin(fn object.Object,
+	args []object.Object) (object.Object, error) {
+	b, ok := fn.(*object.Builtin)
+	if !ok {
+		return nil, fmt.Errorf("cannot call %s from Go", fn.Type())
+	}
+	if b.Apply != nil {
+		return b.Apply(applyBuiltin, args...), nil
+	}
+	return b.Fn(args...), nil
+}
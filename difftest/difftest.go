@@ -0,0 +1,182 @@
+package difftest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/compiler"
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/regvm"
+	"github.com/adamvinueza/monkey/vm"
+)
+
+// Result is the outcome of running a program in one engine.
+type Result struct {
+	// Value describes the value of the program, or is empty if it failed.
+	Value string
+	// Error is the trace of the error the program failed with, if any.
+	Error string
+	// Output holds what the program wrote with puts.
+	Output string
+}
+
+// Divergence describes a program the engines disagree on.
+type Divergence struct {
+	Evaluator Result
+	VM        Result
+}
+
+// String describes each part of the results that differs.
+func (d *Divergence) String() string {
+	var out bytes.Buffer
+	diff := func(what, e, v string) {
+		if e != v {
+			fmt.Fprintf(&out, "%s differs:\nevaluator: %q\nvm:        %q\n",
+				what, e, v)
+		}
+	}
+	diff("value", d.Evaluator.Value, d.VM.Value)
+	diff("error", d.Evaluator.Error, d.VM.Error)
+	diff("output", d.Evaluator.Output, d.VM.Output)
+	return out.String()
+}
+
+// Compare runs program with the evaluator and in the virtual machine, and
+// returns how their results differ, or nil if they agree. It returns an error
+// if program can't be compiled.
+//
+// While Compare runs, puts writes to a buffer rather than standard output, so
+// Compare must not be called while other programs are running. The same goes
+// for Eval and Run.
+func Compare(program *ast.Program) (*Divergence, error) {
+	vmResult, err := Run(program)
+	if err != nil {
+		return nil, err
+	}
+	d := Divergence{Evaluator: Eval(program), VM: vmResult}
+	if d.Evaluator == d.VM {
+		return nil, nil
+	}
+	return &d, nil
+}
+
+// Eval returns the result of evaluating program with the evaluator.
+func Eval(program *ast.Program) Result {
+	var r Result
+	r.Output = capture(func() {
+		r.setResult(evaluator.Eval(program, object.NewEnvironment()))
+	})
+	return r
+}
+
+// Run returns the result of compiling program and running it in the virtual
+// machine. It returns an error if program can't be compiled.
+func Run(program *ast.Program) (Result, error) {
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		return Result{}, err
+	}
+	return runBytecode(c.Bytecode()), nil
+}
+
+// RunRegister returns the result of compiling program for the register
+// machine in package regvm and running it there. It returns an error if
+// program can't be compiled.
+func RunRegister(program *ast.Program) (Result, error) {
+	compiled, err := regvm.Compile(program)
+	if err != nil {
+		return Result{}, err
+	}
+	var r Result
+	r.Output = capture(func() {
+		machine := regvm.New(compiled)
+		if err := machine.Run(); err != nil {
+			rerr, ok := err.(*vm.RuntimeError)
+			if !ok {
+				r.Error = err.Error()
+				return
+			}
+			r.setResult(rerr.Err)
+			return
+		}
+		r.setResult(machine.Result())
+	})
+	return r, nil
+}
+
+// runBytecode returns the result of running bytecode in the virtual machine.
+func runBytecode(bytecode *compiler.Bytecode) Result {
+	var r Result
+	r.Output = capture(func() {
+		machine := vm.New(bytecode)
+		if err := machine.Run(); err != nil {
+			rerr, ok := err.(*vm.RuntimeError)
+			if !ok {
+				r.Error = err.Error()
+				return
+			}
+			r.setResult(rerr.Err)
+			return
+		}
+		r.setResult(machine.Result())
+	})
+	return r
+}
+
+func (r *Result) setResult(obj object.Object) {
+	if err, ok := obj.(*object.Error); ok {
+		r.Error = err.Trace("")
+		return
+	}
+	r.Value = inspect(obj)
+}
+
+// capture calls f, returning what puts writes meanwhile.
+func capture(f func()) string {
+	var out bytes.Buffer
+	puts, _ := evaluator.LookupBuiltin("puts")
+	evaluator.RegisterBuiltin(&object.Builtin{
+		Name:   puts.Name,
+		Module: puts.Module,
+		Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprintln(&out, inspect(arg))
+			}
+			return evaluator.NULL
+		},
+	})
+	defer evaluator.RegisterBuiltin(puts)
+	f()
+	return out.String()
+}
+
+// inspect is like obj.Inspect, except that functions are shown as "fn".
+func inspect(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "nil"
+	case *object.Function, *object.Closure, *regvm.Closure:
+		return "fn"
+	case *object.Array:
+		elements := make([]string, len(obj.Elements))
+		for i, e := range obj.Elements {
+			elements[i] = inspect(e)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *object.Hash:
+		pairs := []string{}
+		for _, pair := range obj.SortedPairs() {
+			key := pair.Key.Inspect()
+			if s, ok := pair.Key.(*object.String); ok {
+				key = fmt.Sprintf("%q", s.Value)
+			}
+			pairs = append(pairs, key+": "+inspect(pair.Value))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	default:
+		return obj.Inspect()
+	}
+}
@@ -0,0 +1,124 @@
+package difftest
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/compiler"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+var (
+	seed     = flag.Int64("seed", 1, "seed for random programs")
+	programs = flag.Int("programs", 2000, "number of random programs to run")
+)
+
+func TestCorpus(t *testing.T) {
+	for path, program := range corpus(t) {
+		checkProgram(t, path, program)
+	}
+}
+
+func TestRandomPrograms(t *testing.T) {
+	g := NewGenerator(*seed)
+	for n := 0; n < *programs; n++ {
+		program := g.Program()
+		checkProgram(t, ast.SExpr(program), program)
+	}
+}
+
+// TestPeephole checks that bytecode optimized by compiler.Peephole behaves
+// like the program it was compiled from.
+func TestPeephole(t *testing.T) {
+	check := func(name string, program *ast.Program) {
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			t.Errorf("%s: compiler error: %s", name, err)
+			return
+		}
+		expected := Eval(program)
+		found := runBytecode(compiler.Peephole(c.Bytecode()))
+		if found != expected {
+			t.Errorf("%s: optimized bytecode disagrees:\n%s", name,
+				&Divergence{Evaluator: expected, VM: found})
+		}
+	}
+
+	for path, program := range corpus(t) {
+		check(path, program)
+	}
+	g := NewGenerator(*seed)
+	for n := 0; n < *programs; n++ {
+		program := g.Program()
+		check(ast.SExpr(program), program)
+	}
+}
+
+// TestRegister checks that programs run in the register machine behave as
+// they do in the evaluator.
+func TestRegister(t *testing.T) {
+	check := func(name string, program *ast.Program) {
+		expected := Eval(program)
+		found, err := RunRegister(program)
+		if err != nil {
+			t.Errorf("%s: compiler error: %s", name, err)
+			return
+		}
+		if found != expected {
+			t.Errorf("%s: register machine disagrees:\n%s", name,
+				&Divergence{Evaluator: expected, VM: found})
+		}
+	}
+
+	for path, program := range corpus(t) {
+		check(path, program)
+	}
+	g := NewGenerator(*seed)
+	for n := 0; n < *programs; n++ {
+		program := g.Program()
+		check(ast.SExpr(program), program)
+	}
+}
+
+// corpus returns the programs in testdata, by path.
+func corpus(t *testing.T) map[string]*ast.Program {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.mky"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no programs in testdata")
+	}
+	programs := map[string]*ast.Program{}
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := parser.New(lexer.New(string(src)))
+		programs[path] = p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("%s: parser errors: %v", path, p.Errors())
+		}
+	}
+	return programs
+}
+
+// checkProgram reports an error if the engines disagree on program, which is
+// described by name.
+func checkProgram(t *testing.T, name string, program *ast.Program) {
+	t.Helper()
+	d, err := Compare(program)
+	if err != nil {
+		t.Errorf("%s: compiler error: %s", name, err)
+		return
+	}
+	if d != nil {
+		t.Errorf("%s: engines disagree:\n%s", name, d)
+	}
+}
@@ -0,0 +1,23 @@
+// Package difftest checks that the two ways of running Monkey programs, the
+// tree-walking evaluator in package evaluator and the virtual machine in
+// package vm, agree. Compare runs a program in both and reports any
+// difference in the value it produces, the error it fails with, or what it
+// writes with puts. Eval and Run give the results of each engine alone.
+//
+// Programs can come from Monkey source or be generated at random by a
+// Generator, which builds abstract syntax trees that always terminate:
+//  g := difftest.NewGenerator(1)
+//  for n := 0; n < 1000; n++ {
+//      program := g.Program()
+//      if d, err := difftest.Compare(program); err != nil || d != nil {
+//          fmt.Println(ast.SExpr(program), err, d)
+//      }
+//  }
+//
+// RunRegister gives the results of the register machine in package regvm,
+// which is checked against the evaluator the same way.
+//
+// Functions are shown differently by the two engines (the evaluator shows
+// their source, the virtual machine their name), so Compare describes every
+// function value as "fn".
+package difftest
@@ -0,0 +1,309 @@
+package difftest
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/ast/build"
+)
+
+// maxDepth is the maximum depth of the expressions a Generator generates.
+const maxDepth = 4
+
+// operators are the infix operators of generated expressions.
+var operators = []string{"+", "-", "*", "/", "<", ">", "==", "!="}
+
+// strs are the values of generated string literals.
+var strs = []string{"", "a", "bc", "one"}
+
+// builtins are the builtins generated programs call, which are those that
+// don't read the clock or files, with the number of arguments they take.
+var builtins = []struct {
+	name  string
+	arity int
+}{
+	{"len", 1}, {"first", 1}, {"last", 1}, {"rest", 1}, {"push", 2},
+	{"puts", 1},
+}
+
+// A Generator generates random Monkey programs. The programs use most of the
+// language, and often fail, but they always terminate. Let statements, some
+// within the branches of if expressions, may reuse the names of visible
+// variables, shadowing or rebinding them, but only to bind values other than
+// functions. Functions are otherwise called through variables bound to
+// function literals before the call's function was defined, so the only
+// function that can call itself is a recursive one the Generator makes on
+// purpose, which halves an integer argument with each call until it is less
+// than 1.
+type Generator struct {
+	rand  *rand.Rand
+	names int        // the number of names generated so far
+	vars  []variable // the variables visible at the point of generation
+}
+
+// variable is a variable visible to generated code.
+type variable struct {
+	name  string
+	arity int // the number of parameters, if bound to a function literal
+	fn    bool
+}
+
+// NewGenerator returns a Generator whose programs are determined by seed.
+func NewGenerator(seed int64) *Generator {
+	return &Generator{rand: rand.New(rand.NewSource(seed))}
+}
+
+// Program returns a new random program, of a few statements ending with an
+// expression.
+func (g *Generator) Program() *ast.Program {
+	g.vars = nil
+	stmts := []ast.Statement{}
+	for n := g.rand.Intn(6); n > 0; n-- {
+		stmts = append(stmts, g.statement(maxDepth, false))
+	}
+	stmts = append(stmts, build.ExprStmt(g.expression(maxDepth)))
+	return build.Program(stmts...)
+}
+
+// statement returns a random statement. Return statements, within if
+// expressions, are only generated within functions.
+func (g *Generator) statement(depth int, inFunction bool) ast.Statement {
+	switch n := g.rand.Intn(12); {
+	case n < 6:
+		return g.let(depth)
+	case n < 7:
+		return g.recursive(depth)
+	case n < 8:
+		// The variable the let statement binds is visible after the if
+		// expression, whether or not the branch ran.
+		return build.ExprStmt(build.If(g.expression(depth-1),
+			build.Block(g.let(depth-1)), nil))
+	case n < 10 || !inFunction:
+		return build.ExprStmt(g.expression(depth))
+	default:
+		ret := build.Return(g.expression(depth - 1))
+		return build.ExprStmt(build.If(g.expression(depth-1),
+			build.Block(ret), nil))
+	}
+}
+
+// let returns a let statement binding a variable, and makes the variable
+// visible. The variable usually has a new name, but may have that of a
+// visible variable, in which case it isn't bound to a function.
+func (g *Generator) let(depth int) ast.Statement {
+	if len(g.vars) > 0 && g.rand.Intn(4) == 0 {
+		v := variable{name: g.vars[g.rand.Intn(len(g.vars))].name}
+		value := g.expression(depth)
+		g.vars = append(g.vars, v)
+		return build.Let(v.name, value)
+	}
+	v := variable{name: g.name()}
+	var value ast.Expression
+	if g.rand.Intn(3) == 0 {
+		fl := g.function(depth)
+		v.fn, v.arity = true, len(fl.Parameters)
+		value = fl
+	} else {
+		value = g.expression(depth)
+	}
+	g.vars = append(g.vars, v)
+	return build.Let(v.name, value)
+}
+
+// recursive returns a let statement binding a new variable to a function
+// that calls itself, and makes the variable visible. The first parameter of
+// the function is an integer, which each call halves, so that the calls end,
+// at the latest, when it reaches 0:
+//  let f = fn(n, x) { if (n < 1) { x } else { x + f(n / 2, x) } }
+// The call is sometimes the value of the function, and so a tail call.
+func (g *Generator) recursive(depth int) ast.Statement {
+	v := variable{name: g.name(), fn: true}
+	visible := len(g.vars)
+	params := []string{}
+	for n := 1 + g.rand.Intn(2); n > 0; n-- {
+		p := g.name()
+		params = append(params, p)
+		g.vars = append(g.vars, variable{name: p})
+	}
+	args := []ast.Expression{build.Infix(build.Ident(params[0]), "/",
+		build.Int(2))}
+	for _, p := range params[1:] {
+		args = append(args, build.Ident(p))
+	}
+	var recur ast.Expression = build.Call(build.Ident(v.name), args...)
+	if g.rand.Intn(2) == 0 {
+		op := operators[g.rand.Intn(len(operators))]
+		recur = build.Infix(g.operand(depth-2), op, recur)
+	}
+	body := build.If(build.Infix(build.Ident(params[0]), "<", build.Int(1)),
+		build.Block(build.ExprStmt(g.expression(depth-2))),
+		build.Block(build.ExprStmt(recur)))
+	g.vars = g.vars[:visible]
+	v.arity = len(params)
+	g.vars = append(g.vars, v)
+	return build.Let(v.name, build.Fn(params,
+		build.Block(build.ExprStmt(body))))
+}
+
+// expression returns a random expression nested no deeper than depth.
+func (g *Generator) expression(depth int) ast.Expression {
+	if depth <= 0 {
+		return g.leaf()
+	}
+	d := depth - 1
+	switch g.rand.Intn(12) {
+	case 0:
+		if g.rand.Intn(2) == 0 {
+			return build.Prefix("!", g.expression(d))
+		}
+		return build.Prefix("-", g.expression(d))
+	case 1, 2:
+		op := operators[g.rand.Intn(len(operators))]
+		return build.Infix(g.operand(d), op, g.operand(d))
+	case 3:
+		var alternative *ast.BlockStatement
+		if g.rand.Intn(2) == 0 {
+			alternative = build.Block(build.ExprStmt(g.expression(d)))
+		}
+		return build.If(g.expression(d),
+			build.Block(build.ExprStmt(g.expression(d))), alternative)
+	case 4:
+		return build.Array(g.expressions(d, g.rand.Intn(4))...)
+	case 5:
+		pairs := []*ast.HashPair{}
+		for n := g.rand.Intn(4); n > 0; n-- {
+			key := g.leaf()
+			if g.rand.Intn(4) == 0 {
+				key = g.expression(d)
+			}
+			pairs = append(pairs, build.Pair(key, g.expression(d)))
+		}
+		return build.Hash(pairs...)
+	case 6:
+		if g.rand.Intn(2) == 0 {
+			return build.Index(build.Array(g.expressions(d, 1+g.rand.Intn(3))...),
+				g.operand(d))
+		}
+		return build.Index(g.expression(d), g.expression(d))
+	case 7:
+		b := builtins[g.rand.Intn(len(builtins))]
+		return build.Call(build.Ident(b.name),
+			g.expressions(d, g.arity(b.arity))...)
+	case 8, 9:
+		return g.call(d)
+	case 10:
+		return g.function(d)
+	default:
+		return g.leaf()
+	}
+}
+
+// operand returns an operand for an arithmetic operator, which is usually an
+// integer, so that not every generated program fails.
+func (g *Generator) operand(depth int) ast.Expression {
+	if g.rand.Intn(2) == 0 {
+		return build.Int(int64(g.rand.Intn(11)))
+	}
+	return g.expression(depth)
+}
+
+// expressions returns n random expressions.
+func (g *Generator) expressions(depth, n int) []ast.Expression {
+	exprs := []ast.Expression{}
+	for ; n > 0; n-- {
+		exprs = append(exprs, g.expression(depth))
+	}
+	return exprs
+}
+
+// arity returns the number of arguments to pass to a function with n
+// parameters: usually n, but occasionally one more or one less.
+func (g *Generator) arity(n int) int {
+	switch g.rand.Intn(10) {
+	case 0:
+		return n + 1
+	case 1:
+		if n > 0 {
+			return n - 1
+		}
+	}
+	return n
+}
+
+// call returns a call of a variable bound to a function literal, or, if there
+// is none, of a function literal.
+func (g *Generator) call(depth int) ast.Expression {
+	fns := []variable{}
+	for i, v := range g.vars {
+		if v.fn && !g.shadowed(i) {
+			fns = append(fns, v)
+		}
+	}
+	if len(fns) == 0 {
+		fl := g.function(depth)
+		return build.Call(fl, g.expressions(depth,
+			g.arity(len(fl.Parameters)))...)
+	}
+	v := fns[g.rand.Intn(len(fns))]
+	return build.Call(build.Ident(v.name),
+		g.expressions(depth, g.arity(v.arity))...)
+}
+
+// function returns a function literal whose body is nested no deeper than
+// depth.
+func (g *Generator) function(depth int) *ast.FunctionLiteral {
+	visible := len(g.vars)
+	params := []string{}
+	for n := g.rand.Intn(3); n > 0; n-- {
+		p := g.name()
+		params = append(params, p)
+		g.vars = append(g.vars, variable{name: p})
+	}
+	stmts := []ast.Statement{}
+	for n := g.rand.Intn(3); n > 0; n-- {
+		stmts = append(stmts, g.statement(depth-1, true))
+	}
+	stmts = append(stmts, build.ExprStmt(g.expression(depth-1)))
+	g.vars = g.vars[:visible]
+	return build.Fn(params, build.Block(stmts...))
+}
+
+// shadowed reports whether the visible variable at index i of g.vars has
+// been shadowed or rebound by a later one of the same name.
+func (g *Generator) shadowed(i int) bool {
+	for _, v := range g.vars[i+1:] {
+		if v.name == g.vars[i].name {
+			return true
+		}
+	}
+	return false
+}
+
+// leaf returns a random literal or variable.
+func (g *Generator) leaf() ast.Expression {
+	switch g.rand.Intn(8) {
+	case 0, 1:
+		return build.Int(int64(g.rand.Intn(11)))
+	case 2:
+		return build.Bool(g.rand.Intn(2) == 0)
+	case 3:
+		return build.Str(strs[g.rand.Intn(len(strs))])
+	case 4:
+		if g.rand.Intn(20) == 0 {
+			return build.Ident("missing")
+		}
+		fallthrough
+	default:
+		if len(g.vars) == 0 {
+			return build.Int(int64(g.rand.Intn(11)))
+		}
+		return build.Ident(g.vars[g.rand.Intn(len(g.vars))].name)
+	}
+}
+
+// name returns a name no other variable has.
+func (g *Generator) name() string {
+	g.names++
+	return fmt.Sprintf("v%d", g.names)
+}
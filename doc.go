@@ -0,0 +1,14 @@
+// Package monkey runs Monkey programs from Go code. It lexes, parses and
+// evaluates program text in one call, converting between Go values and
+// Monkey values, so that Monkey can serve as a configuration or rules language
+// for Go programs.
+//
+// For example:
+//  result, err := monkey.Eval(ctx, `limit * 2`, monkey.Options{
+//      Globals: map[string]interface{}{"limit": 50},
+//  })
+// sets result to int64(100).
+//
+// The command-line tool, which starts a REPL and runs Monkey files, is in
+// cmd/monkey.
+package monkey
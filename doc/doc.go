@@ -0,0 +1,10 @@
+// Package doc extracts documentation from Monkey modules and renders it as
+// Markdown or HTML. A module is a single file of Monkey program text, and its
+// documentation consists of the functions bound by its top-level let
+// statements, along with their parameters and doc comments.
+//
+// To use, parse a module and pass the resulting Program to New:
+//  p := parser.New(lexer.New(src))
+//  m := doc.New("math", p.ParseProgram())
+//  err := doc.WriteMarkdown(os.Stdout, []*doc.Module{m})
+package doc
@@ -0,0 +1,116 @@
+package doc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+const input = `// add returns the sum of x and y.
+let add = fn(x, y) { x + y };
+
+// answer is not a function.
+let answer = 42;
+
+let noop = fn() {};
+
+if (true) { let hidden = fn(z) { z }; }
+
+// lt reports whether a < b.
+let lt = fn(a, b) { a < b };
+`
+
+func parseModule(t *testing.T, name, src string) *Module {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return New(name, program)
+}
+
+func TestNew(t *testing.T) {
+	m := parseModule(t, "math", input)
+
+	tests := []struct {
+		expectedSignature string
+		expectedDoc       string
+		expectedLine      int
+	}{
+		{"add(x, y)", "add returns the sum of x and y.\n", 2},
+		{"noop()", "", 7},
+		{"lt(a, b)", "lt reports whether a < b.\n", 12},
+	}
+
+	if m.Name != "math" {
+		t.Errorf("m.Name wrong. expected=%q, got=%q", "math", m.Name)
+	}
+	if len(m.Funcs) != len(tests) {
+		t.Fatalf("m.Funcs has wrong length. expected=%d, got=%d", len(tests),
+			len(m.Funcs))
+	}
+	for i, tt := range tests {
+		f := m.Funcs[i]
+		if f.Signature() != tt.expectedSignature {
+			t.Errorf("tests[%d] - signature wrong. expected=%q, got=%q", i,
+				tt.expectedSignature, f.Signature())
+		}
+		if f.Doc != tt.expectedDoc {
+			t.Errorf("tests[%d] - doc wrong. expected=%q, got=%q", i,
+				tt.expectedDoc, f.Doc)
+		}
+		if f.Pos.Line != tt.expectedLine {
+			t.Errorf("tests[%d] - line wrong. expected=%d, got=%d", i,
+				tt.expectedLine, f.Pos.Line)
+		}
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	modules := []*Module{
+		parseModule(t, "math", input),
+		parseModule(t, "empty", "let x = 1;"),
+	}
+	expected := "# math\n" +
+		"\n## add\n\n```\nadd(x, y)\n```\n\nadd returns the sum of x and y.\n" +
+		"\n## noop\n\n```\nnoop()\n```\n" +
+		"\n## lt\n\n```\nlt(a, b)\n```\n\nlt reports whether a < b.\n" +
+		"\n# empty\n"
+
+	var out bytes.Buffer
+	if err := WriteMarkdown(&out, modules); err != nil {
+		t.Fatalf("WriteMarkdown failed: %s", err)
+	}
+	if out.String() != expected {
+		t.Errorf("WriteMarkdown wrong. expected=%q, got=%q", expected,
+			out.String())
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	modules := []*Module{parseModule(t, "math", input)}
+
+	var out bytes.Buffer
+	if err := WriteHTML(&out, "Math <lib>", modules); err != nil {
+		t.Fatalf("WriteHTML failed: %s", err)
+	}
+	html := out.String()
+
+	expected := []string{
+		"<!DOCTYPE html>",
+		"<title>Math &lt;lib&gt;</title>",
+		`<a href="#math">math</a>`,
+		`<h3 id="math.add">add</h3>`,
+		"<pre>add(x, y)</pre>",
+		`<p class="doc">lt reports whether a &lt; b.`,
+		"</html>",
+	}
+	for _, e := range expected {
+		if !strings.Contains(html, e) {
+			t.Errorf("WriteHTML output does not contain %q:\n%s", e, html)
+		}
+	}
+}
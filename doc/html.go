@@ -0,0 +1,47 @@
+package doc
+
+import (
+	"html/template"
+	"io"
+)
+
+var htmlPage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; }
+pre { background: #f4f4f4; padding: 0.5em; }
+.doc { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+{{- range .Modules}}
+<li><a href="#{{.Name}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+{{- range $m := .Modules}}
+<h2 id="{{$m.Name}}">{{$m.Name}}</h2>
+{{- range .Funcs}}
+<h3 id="{{$m.Name}}.{{.Name}}">{{.Name}}</h3>
+<pre>{{.Signature}}</pre>
+{{- if .Doc}}
+<p class="doc">{{.Doc}}</p>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the documentation for the specified modules to w as a
+// standalone HTML page with the specified title.
+func WriteHTML(w io.Writer, title string, modules []*Module) error {
+	return htmlPage.Execute(w, struct {
+		Title   string
+		Modules []*Module
+	}{title, modules})
+}
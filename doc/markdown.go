@@ -0,0 +1,28 @@
+package doc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteMarkdown writes the documentation for the specified modules to w as
+// Markdown, with a section for each module and a subsection for each of its
+// functions.
+func WriteMarkdown(w io.Writer, modules []*Module) error {
+	bw := bufio.NewWriter(w)
+	for i, m := range modules {
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "# %s\n", m.Name)
+		for _, f := range m.Funcs {
+			fmt.Fprintf(bw, "\n## %s\n\n", f.Name)
+			fmt.Fprintf(bw, "```\n%s\n```\n", f.Signature())
+			if f.Doc != "" {
+				fmt.Fprintf(bw, "\n%s", f.Doc)
+			}
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,56 @@
+package doc
+
+import (
+	"strings"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// Module holds the documentation for a Monkey module.
+type Module struct {
+	Name  string
+	Funcs []*Func // in the order they appear in the module
+}
+
+// Func holds the documentation for a function bound by a top-level let
+// statement.
+type Func struct {
+	Name   string
+	Params []string
+	Doc    string // the text of the let statement's doc comment
+	Pos    token.Position
+}
+
+// Signature returns the function's name followed by its parameter list, e.g.
+// "add(x, y)".
+func (f *Func) Signature() string {
+	return f.Name + "(" + strings.Join(f.Params, ", ") + ")"
+}
+
+// New returns the documentation for the module with the specified name and
+// parsed program text.
+func New(name string, program *ast.Program) *Module {
+	m := &Module{Name: name}
+	for _, s := range program.Statements {
+		let, ok := s.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+		fn, ok := let.Value.(*ast.FunctionLiteral)
+		if !ok {
+			continue
+		}
+		f := &Func{
+			Name:   let.Name.Value,
+			Params: []string{},
+			Doc:    let.Doc.Text(),
+			Pos:    let.Token.Pos,
+		}
+		for _, p := range fn.Parameters {
+			f.Params = append(f.Params, p.Value)
+		}
+		m.Funcs = append(m.Funcs, f)
+	}
+	return m
+}
@@ -0,0 +1,283 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/adamvinueza/monkey/object"
+)
+
+// stdout is where puts writes. (It is a variable so that tests can capture
+// what is written.)
+var stdout io.Writer = os.Stdout
+
+// now returns the current time. (It is a variable so that tests can fix the
+// time.)
+var now = time.Now
+
+// builtins holds the built-in functions, which are found by name when an
+// identifier is not bound in the environment. Builtins are grouped into
+// modules, so that a Sandbox can allow groups of related builtins: "core"
+// holds those without side effects, "io" those that read or write files and
+// standard output, "time" those that read the clock, and "test" those that
+// make assertions in tests.
+var builtins = map[string]*object.Builtin{}
+
+func init() {
+	for _, b := range []*object.Builtin{
+		{Name: "len", Module: "core", Fn: builtinLen},
+		{Name: "first", Module: "core", Fn: builtinFirst},
+		{Name: "last", Module: "core", Fn: builtinLast},
+		{Name: "rest", Module: "core", Fn: builtinRest},
+		{Name: "push", Module: "core", Fn: builtinPush},
+		{Name: "puts", Module: "io", Fn: builtinPuts},
+		{Name: "readFile", Module: "io", Fn: builtinReadFile},
+		{Name: "now", Module: "time", Fn: builtinNow},
+		{Name: "assert", Module: "test", Fn: builtinAssert},
+		{Name: "assertEqual", Module: "test", Fn: builtinAssertEqual},
+		{Name: "assertError", Module: "test", Fn: builtinAssertError,
+			Apply: applyAssertError},
+	} {
+		builtins[b.Name] = b
+	}
+}
+
+// RegisterBuiltin makes b available to all evaluations under b.Name, replacing
+// any builtin with that name. If b.Module is set, a Sandbox can allow b by its
+// module. RegisterBuiltin is meant to be called during initialization, since
+// it is not safe to call while programs are being evaluated.
+func RegisterBuiltin(b *object.Builtin) {
+	builtins[b.Name] = b
+}
+
+// LookupBuiltin returns the builtin called name, if there is one.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	b, ok := builtins[name]
+	return b, ok
+}
+
+// len(value) returns the number of elements in an array, or the number of
+// bytes in a string.
+func builtinLen(args ...object.Object) object.Object {
+	if err := checkArgs("len", args, ""); err != nil {
+		return err
+	}
+	switch arg := args[0].(type) {
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.String:
+		return &object.Integer{Value: int64(len(arg.Value))}
+	default:
+		return newError("argument 1 to `len` must be ARRAY or STRING, found %s",
+			args[0].Type())
+	}
+}
+
+// first(array) returns the first element of array, or null if it is empty.
+func builtinFirst(args ...object.Object) object.Object {
+	if err := checkArgs("first", args, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+	elements := args[0].(*object.Array).Elements
+	if len(elements) > 0 {
+		return elements[0]
+	}
+	return NULL
+}
+
+// last(array) returns the last element of array, or null if it is empty.
+func builtinLast(args ...object.Object) object.Object {
+	if err := checkArgs("last", args, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+	elements := args[0].(*object.Array).Elements
+	if len(elements) > 0 {
+		return elements[len(elements)-1]
+	}
+	return NULL
+}
+
+// rest(array) returns a new array holding all but the first element of array,
+// or null if it is empty.
+func builtinRest(args ...object.Object) object.Object {
+	if err := checkArgs("rest", args, object.ARRAY_OBJ); err != nil {
+		return err
+	}
+	elements := args[0].(*object.Array).Elements
+	if len(elements) > 0 {
+		rest := make([]object.Object, len(elements)-1)
+		copy(rest, elements[1:])
+		return &object.Array{Elements: rest}
+	}
+	return NULL
+}
+
+// push(array, value) returns a new array holding the elements of array
+// followed by value.
+func builtinPush(args ...object.Object) object.Object {
+	if err := checkArgs("push", args, object.ARRAY_OBJ, ""); err != nil {
+		return err
+	}
+	elements := args[0].(*object.Array).Elements
+	pushed := make([]object.Object, len(elements)+1)
+	copy(pushed, elements)
+	pushed[len(elements)] = args[1]
+	return &object.Array{Elements: pushed}
+}
+
+// puts(values...) writes each of its arguments on a line of its own, and
+// returns null.
+func builtinPuts(args ...object.Object) object.Object {
+	for _, arg := range args {
+		fmt.Fprintln(stdout, arg.Inspect())
+	}
+	return NULL
+}
+
+// readFile(path) returns the contents of the file at path as a string.
+func builtinReadFile(args ...object.Object) object.Object {
+	if err := checkArgs("readFile", args, object.STRING_OBJ); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(args[0].(*object.String).Value)
+	if err != nil {
+		return newError("readFile: %s", err)
+	}
+	return &object.String{Value: string(data)}
+}
+
+// now() returns the current Unix time in milliseconds.
+func builtinNow(args ...object.Object) object.Object {
+	if err := checkArgs("now", args); err != nil {
+		return err
+	}
+	return &object.Integer{Value: now().UnixNano() / int64(time.Millisecond)}
+}
+
+// assert(condition) fails unless condition is truthy, and otherwise returns
+// null. assert(condition, message) fails with message.
+func builtinAssert(args ...object.Object) object.Object {
+	if len(args) == 2 {
+		if err := checkArgs("assert", args, "", object.STRING_OBJ); err != nil {
+			return err
+		}
+		if !isTruthy(args[0]) {
+			return newError("assertion failed: %s",
+				args[1].(*object.String).Value)
+		}
+		return NULL
+	}
+	if err := checkArgs("assert", args, ""); err != nil {
+		return err
+	}
+	if !isTruthy(args[0]) {
+		return newError("assertion failed")
+	}
+	return NULL
+}
+
+// assertEqual(actual, expected) fails unless actual equals expected, and
+// otherwise returns null. Arrays and hashes are equal if their elements are,
+// while functions are equal only to themselves.
+func builtinAssertEqual(args ...object.Object) object.Object {
+	if err := checkArgs("assertEqual", args, "", ""); err != nil {
+		return err
+	}
+	if !equal(args[0], args[1]) {
+		return newError("assertEqual: expected %s, found %s", describe(args[1]),
+			describe(args[0]))
+	}
+	return NULL
+}
+
+// builtinAssertError is called by the engines other than the evaluator, which
+// can't call the function assertError is passed, and fails.
+func builtinAssertError(args ...object.Object) object.Object {
+	return newError("`assertError` is only supported by the evaluator")
+}
+
+// assertError(function) calls function, which takes no arguments, and returns
+// the message of the error it fails with, failing itself if function doesn't
+// fail.
+func applyAssertError(apply object.Applier,
+	args ...object.Object) object.Object {
+	if err := checkArgs("assertError", args, object.FUNCTION_OBJ); err != nil {
+		return err
+	}
+	result, err := apply(args[0], nil)
+	if err != nil {
+		return newError("%s", err)
+	}
+	if err, ok := result.(*object.Error); ok {
+		return &object.String{Value: err.Message}
+	}
+	return newError("assertError: expected an error, found %s", describe(result))
+}
+
+// equal reports whether a and b are equal values.
+func equal(a, b object.Object) bool {
+	switch a := a.(type) {
+	case *object.Integer:
+		b, ok := b.(*object.Integer)
+		return ok && a.Value == b.Value
+	case *object.String:
+		b, ok := b.(*object.String)
+		return ok && a.Value == b.Value
+	case *object.Array:
+		b, ok := b.(*object.Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		b, ok := b.(*object.Hash)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		for key, pair := range a.Pairs {
+			other, ok := b.Pairs[key]
+			if !ok || !equal(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// describe returns a description of obj for an assertion's error message: its
+// value, with a string quoted so that it can't be mistaken for another type.
+func describe(obj object.Object) string {
+	if s, ok := obj.(*object.String); ok {
+		return strconv.Quote(s.Value)
+	}
+	return obj.Inspect()
+}
+
+// checkArgs returns an error if args does not hold exactly one argument of
+// each of the specified types, or nil if it does. An empty type matches any
+// argument.
+func checkArgs(name string, args []object.Object,
+	types ...object.ObjectType) *object.Error {
+	if len(args) != len(types) {
+		return newError("wrong number of arguments to `%s`: expected %d, found %d",
+			name, len(types), len(args))
+	}
+	for i, t := range types {
+		if t != "" && args[i].Type() != t {
+			return newError("argument %d to `%s` must be %s, found %s",
+				i+1, name, t, args[i].Type())
+		}
+	}
+	return nil
+}
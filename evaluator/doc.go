@@ -0,0 +1,20 @@
+// Package evaluator evaluates Monkey programs by walking their abstract syntax
+// trees.
+//
+// To use, parse a program and pass it to Eval along with an Environment, which
+// holds the values bound by the program's let statements:
+//  p := parser.New(lexer.New(`let add = fn(x, y) { x + y }; add(2, 3)`))
+//  result := evaluator.Eval(p.ParseProgram(), object.NewEnvironment())
+//  fmt.Println(result.Inspect())
+//
+// Calls in tail position, as the last expression of a function's body or the
+// value of a return statement, are made without growing the Go stack, so
+// functions can loop by recursing any number of times that way. Such loops
+// may run forever under Eval, which limits only the depth of calls.
+//
+// To run untrusted programs, use EvalContext, which stops evaluation when a
+// context is done or when evaluation exceeds limits on the number of steps,
+// the depth of function calls, or the number of objects allocated. Its limits
+// can also include a Sandbox, which restricts the builtins a program may use,
+// such as those in the "io" module that read files and write output.
+package evaluator
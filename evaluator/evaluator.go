@@ -0,0 +1,572 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// There is only ever one null, true and false value, so they can be compared
+// by identity.
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+)
+
+// Eval evaluates node in the environment env and returns its value. If
+// evaluation fails, the value is an *object.Error, positioned at the innermost
+// node that failed.
+//
+// Eval imposes no limits on evaluation except for the default maximum depth of
+// function calls; exceeding it also produces an *object.Error, with the stack
+// of calls that were active.
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	result, err := EvalContext(context.Background(), node, env, Limits{})
+	if err != nil {
+		le := err.(*LimitError)
+		return &object.Error{Message: le.message(), Pos: le.Pos,
+			Stack: le.Stack}
+	}
+	return result
+}
+
+// EvalContext is like Eval, but stops evaluation when ctx is done or when
+// evaluation exceeds limits, returning a *LimitError. Other runtime errors are
+// returned as *object.Error values, as with Eval.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment,
+	limits Limits) (object.Object, error) {
+	return run(ctx, limits, ast.Pos(node), func(i *interpreter) object.Object {
+		return i.eval(node, env)
+	})
+}
+
+// ApplyContext calls fn, which must be a function or builtin, with args, under
+// the same conditions as EvalContext. It is for calling Monkey functions from
+// Go code.
+func ApplyContext(ctx context.Context, fn object.Object, args []object.Object,
+	limits Limits) (object.Object, error) {
+	return run(ctx, limits, token.Position{}, func(i *interpreter) object.Object {
+		return i.applyFunction(fn, args, nil)
+	})
+}
+
+// run calls f with a new interpreter, returning a *LimitError if evaluation is
+// stopped. The position is that of the node f starts evaluating.
+func run(ctx context.Context, limits Limits, pos token.Position,
+	f func(*interpreter) object.Object) (result object.Object, err error) {
+	i := &interpreter{ctx: ctx, limits: limits}
+	if i.limits.MaxDepth == 0 {
+		i.limits.MaxDepth = DefaultMaxDepth
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			le, ok := r.(*LimitError)
+			if !ok {
+				panic(r)
+			}
+			result, err = nil, le
+		}
+	}()
+	if err := ctx.Err(); err != nil {
+		return nil, &LimitError{Kind: ContextDone, Pos: pos, Err: err}
+	}
+	return f(i), nil
+}
+
+// interpreter holds the state of a single evaluation.
+type interpreter struct {
+	ctx    context.Context
+	limits Limits
+
+	steps   int            // nodes evaluated
+	calls   []object.Frame // active function calls, outermost first
+	objects int            // objects allocated
+}
+
+// eval evaluates node in env, giving any error that results the position of
+// node, unless the error has a position already.
+func (i *interpreter) eval(node ast.Node, env *object.Environment) object.Object {
+	i.step(node)
+	result := i.evalNode(node, env)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = ast.Pos(node)
+	}
+	return result
+}
+
+func (i *interpreter) evalNode(node ast.Node,
+	env *object.Environment) object.Object {
+	switch node := node.(type) {
+
+	// Statements
+	case *ast.Program:
+		return i.evalProgram(node, env)
+	case *ast.BlockStatement:
+		return i.evalBlockStatement(node, env)
+	case *ast.ExpressionStatement:
+		return i.eval(node.Expression, env)
+	case *ast.ReturnStatement:
+		val := i.eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.LetStatement:
+		val := i.eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
+		env.Set(node.Name.Value, val)
+
+	// Expressions
+	case *ast.IntegerLiteral:
+		return i.alloc(node, &object.Integer{Value: node.Value})
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.PrefixExpression:
+		right := i.eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return i.alloc(node, evalPrefixExpression(node.Operator, right))
+	case *ast.InfixExpression:
+		left := i.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := i.eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return i.alloc(node, evalInfixExpression(node.Operator, left, right))
+	case *ast.IfExpression:
+		return i.evalIfExpression(node, env)
+	case *ast.Identifier:
+		return i.evalIdentifier(node, env)
+	case *ast.FunctionLiteral:
+		return i.alloc(node, &object.Function{
+			Parameters: node.Parameters,
+			Body:       node.Body,
+			Env:        env,
+		})
+	case *ast.CallExpression:
+		function := i.eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := i.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return i.applyFunction(function, args, node)
+	case *ast.ArrayLiteral:
+		elements := i.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return i.alloc(node, &object.Array{Elements: elements})
+	case *ast.StringLiteral:
+		return i.alloc(node, &object.String{Value: node.Value})
+	case *ast.HashLiteral:
+		return i.evalHashLiteral(node, env)
+	case *ast.IndexExpression:
+		left := i.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := i.eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	}
+
+	return nil
+}
+
+func (i *interpreter) evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range program.Statements {
+		result = i.eval(statement, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+
+	return result
+}
+
+// evalBlockStatement differs from evalProgram in that it does not unwrap
+// return values, so that a return statement in a nested block stops evaluation
+// of the blocks enclosing it as well.
+func (i *interpreter) evalBlockStatement(block *ast.BlockStatement,
+	env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range block.Statements {
+		result = i.eval(statement, env)
+
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	default:
+		return newError("unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	if isTruthy(right) {
+		return FALSE
+	}
+	return TRUE
+}
+
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	if right.Type() != object.INTEGER_OBJ {
+		return newError("unknown operator: -%s", right.Type())
+	}
+
+	value := right.(*object.Integer).Value
+	return &object.Integer{Value: -value}
+}
+
+func evalInfixExpression(operator string,
+	left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s",
+			left.Type(), operator, right.Type())
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+func evalIntegerInfixExpression(operator string,
+	left, right object.Object) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+
+	switch operator {
+	case "+":
+		return &object.Integer{Value: leftVal + rightVal}
+	case "-":
+		return &object.Integer{Value: leftVal - rightVal}
+	case "*":
+		return &object.Integer{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+func evalStringInfixExpression(operator string,
+	left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+func (i *interpreter) evalIfExpression(ie *ast.IfExpression,
+	env *object.Environment) object.Object {
+	condition := i.eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return i.eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return i.eval(ie.Alternative, env)
+	} else {
+		return NULL
+	}
+}
+
+func (i *interpreter) evalIdentifier(node *ast.Identifier,
+	env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	if builtin, ok := builtins[node.Value]; ok {
+		if err := i.limits.Sandbox.Check(builtin); err != nil {
+			return err
+		}
+		return builtin
+	}
+	return newError("identifier not found: %s", node.Value)
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s[%s]", left.Type(),
+			index.Type())
+	}
+}
+
+// evalArrayIndexExpression returns the element of array at index, or null if
+// index is out of range.
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 || idx >= int64(len(elements)) {
+		return NULL
+	}
+
+	return elements[idx]
+}
+
+// evalHashIndexExpression returns the value stored in hash under index, or
+// null if there is none.
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hash.(*object.Hash).Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+func (i *interpreter) evalHashLiteral(node *ast.HashLiteral,
+	env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for _, pair := range node.Pairs {
+		key := i.eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := i.eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return i.alloc(node, &object.Hash{Pairs: pairs})
+}
+
+// evalExpressions evaluates exps from left to right. If evaluating one fails,
+// the result holds only the error.
+func (i *interpreter) evalExpressions(exps []ast.Expression,
+	env *object.Environment) []object.Object {
+	result := []object.Object{}
+
+	for _, e := range exps {
+		evaluated := i.eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+// applyFunction calls fn with args. The position of call is recorded in the
+// stack of any error the call returns. Calls in tail position in the body of
+// fn are made iteratively, so that they don't grow the Go stack.
+func (i *interpreter) applyFunction(fn object.Object, args []object.Object,
+	call ast.Node) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		if builtin.Apply != nil {
+			return i.alloc(call, i.applyBuiltin(builtin, args, call))
+		}
+		return i.alloc(call, builtin.Fn(args...))
+	}
+
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError("not a function: %s", fn.Type())
+	}
+	if len(args) != len(function.Parameters) {
+		return newError("wrong number of arguments: expected %d, found %d",
+			len(function.Parameters), len(args))
+	}
+
+	// Each tail call the body makes replaces the call being made, so it
+	// counts toward neither the depth of calls nor the stack of an error.
+	i.enter(function, call)
+	for {
+		extendedEnv := extendFunctionEnv(function, args)
+		i.allocN(1, call)
+		evaluated := unwrapReturnValue(i.evalTail(function.Body, extendedEnv,
+			true))
+		if tc, ok := evaluated.(*tailCall); ok {
+			function, args, call = tc.fn, tc.args, tc.call
+			i.calls[len(i.calls)-1] = newFrame(function, ast.Pos(call))
+			continue
+		}
+		i.leave()
+		if err, ok := evaluated.(*object.Error); ok {
+			err.Stack = append(err.Stack, newFrame(function, ast.Pos(call)))
+		}
+		return evaluated
+	}
+}
+
+// applyBuiltin calls builtin.Apply with args, letting it call functions as if
+// they were called by call. If evaluation is stopped during such a call, the
+// builtin is told, and evaluation stops once it returns.
+func (i *interpreter) applyBuiltin(builtin *object.Builtin,
+	args []object.Object, call ast.Node) object.Object {
+	var stopped *LimitError
+	apply := func(fn object.Object,
+		args []object.Object) (result object.Object, err error) {
+		if stopped != nil {
+			return nil, stopped
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				le, ok := r.(*LimitError)
+				if !ok {
+					panic(r)
+				}
+				stopped = le
+				result, err = nil, le
+			}
+		}()
+		return i.applyFunction(fn, args, call), nil
+	}
+	result := builtin.Apply(apply, args...)
+	if stopped != nil {
+		panic(stopped)
+	}
+	return result
+}
+
+func newFrame(fn *object.Function, callPos token.Position) object.Frame {
+	name := fn.Name
+	if name == "" {
+		name = "fn"
+	}
+	return object.Frame{Function: name, CallPos: callPos}
+}
+
+// extendFunctionEnv returns the environment for a call of fn: a new
+// environment, enclosed by the one fn was defined in, binding its parameters
+// to args.
+func extendFunctionEnv(fn *object.Function,
+	args []object.Object) *object.Environment {
+	env := object.NewEnclosedEnvironment(fn.Env)
+
+	for i, param := range fn.Parameters {
+		env.Set(param.Value, args[i])
+	}
+
+	return env
+}
+
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	return obj
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+	return false
+}
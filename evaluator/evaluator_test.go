@@ -0,0 +1,822 @@
+package evaluator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/parser"
+	"github.com/adamvinueza/monkey/token"
+)
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{`
+if (10 > 1) {
+  if (10 > 1) {
+    return 10;
+  }
+
+  return 1;
+}
+`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{`
+if (10 > 1) {
+  if (10 > 1) {
+    return true + false;
+  }
+
+  return 1;
+}
+`, "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{"1 / 0", "division by zero: 1 / 0"},
+		{"5(1)", "not a function: INTEGER"},
+		{"fn(x) { x }(1, 2)", "wrong number of arguments: expected 1, found 2"},
+		{"let f = fn(x) { y }; f(1)", "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q, found %T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, found=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2; };"
+
+	evaluated := testEval(t, input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function, found %T (%+v)", evaluated, evaluated)
+	}
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters, found %+v", fn.Parameters)
+	}
+
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x', found %q", fn.Parameters[0])
+	}
+
+	expectedBody := "(x + 2)"
+
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q, found %q", expectedBody, fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let f = fn() { return 1; 2 }; f() + 1", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int64
+	}{
+		{"adder", `
+let newAdder = fn(x) { fn(y) { x + y }; };
+let addTwo = newAdder(2);
+addTwo(3);
+`, 5},
+		{"adders are independent", `
+let newAdder = fn(x) { fn(y) { x + y }; };
+let addTwo = newAdder(2);
+let addTen = newAdder(10);
+addTwo(1) * addTen(1);
+`, 33},
+		{"counter", `
+let newCounter = fn(start) {
+  fn(times) {
+    let loop = fn(n, i) { if (i == times) { n } else { loop(n + 1, i + 1) } };
+    loop(start, 0);
+  };
+};
+let fromTen = newCounter(10);
+fromTen(5) + fromTen(0);
+`, 25},
+		{"currying", `
+let curry = fn(f) { fn(a) { fn(b) { f(a, b) } } };
+let sub = fn(a, b) { a - b };
+curry(sub)(10)(3);
+`, 7},
+		{"shadowing", `
+let x = 1;
+let f = fn(x) { let g = fn() { x }; g() };
+f(2) * 10 + x;
+`, 21},
+		{"call environment does not leak", `
+let x = 1;
+let f = fn() { let x = 100; x };
+f() + x;
+`, 101},
+		{"late binding of globals", `
+let f = fn() { later };
+let later = 42;
+f();
+`, 42},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if !testIntegerObject(t, evaluated, tt.expected) {
+			t.Errorf("%s failed", tt.name)
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval(t, "[1, 2 * 2, 3 + 3]")
+
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array, found %T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong number of elements, found %d",
+			len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len([])`, 0},
+		{`len([1, 2, 3])`, 3},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`rest([1, 2, 3])`, []int64{2, 3}},
+		{`rest([1])`, []int64{}},
+		{`rest([])`, nil},
+		{`push([], 1)`, []int64{1}},
+		{`let a = [1]; push(a, 2); a`, []int64{1}},
+		{`let a = [1, 2]; rest(a); a`, []int64{1, 2}},
+		{`let len = fn(x) { 42 }; len([])`, 42},
+		{`let map = fn(arr, f) {
+  let iter = fn(arr, acc) {
+    if (len(arr) == 0) { acc } else { iter(rest(arr), push(acc, f(first(arr)))) }
+  };
+  iter(arr, []);
+};
+map([1, 2, 3], fn(x) { x * 2 })`, []int64{2, 4, 6}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array, found %T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong number of elements, expected=%d, found=%d",
+					len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], expectedElem)
+			}
+		}
+	}
+}
+
+func TestBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+		expectedPos     token.Position
+	}{
+		{"len(1)", "argument 1 to `len` must be ARRAY or STRING, found INTEGER",
+			token.Position{Line: 1, Column: 1}},
+		{"len([1], [2])", "wrong number of arguments to `len`: expected 1, found 2",
+			token.Position{Line: 1, Column: 1}},
+		{"let x = 1;\n  first(x)", "argument 1 to `first` must be ARRAY, found INTEGER",
+			token.Position{Line: 2, Column: 3}},
+		{"last()", "wrong number of arguments to `last`: expected 1, found 0",
+			token.Position{Line: 1, Column: 1}},
+		{"1 + rest(true)", "argument 1 to `rest` must be ARRAY, found BOOLEAN",
+			token.Position{Line: 1, Column: 5}},
+		{"push(1, 1)", "argument 1 to `push` must be ARRAY, found INTEGER",
+			token.Position{Line: 1, Column: 1}},
+		{"push([])", "wrong number of arguments to `push`: expected 2, found 1",
+			token.Position{Line: 1, Column: 1}},
+		{"1[0]", "index operator not supported: INTEGER[INTEGER]",
+			token.Position{Line: 1, Column: 1}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q, found %T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, found=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+		if errObj.Pos != tt.expectedPos {
+			t.Errorf("wrong error position for %q. expected=%s, found=%s",
+				tt.input, tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos token.Position
+	}{
+		{"5 + true;", token.Position{Line: 1, Column: 1}},
+		{"let x = 1;\nlet y = x * -true;", token.Position{Line: 2, Column: 13}},
+		{"if (true) {\n  foobar\n}", token.Position{Line: 2, Column: 3}},
+		{"let f = fn(x) { x };\n f(1, 2)", token.Position{Line: 2, Column: 2}},
+		{"[1, 2 / 0]", token.Position{Line: 1, Column: 5}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q, found %T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Pos != tt.expectedPos {
+			t.Errorf("wrong error position for %q. expected=%s, found=%s",
+				tt.input, tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	input := `let divide = fn(a, b) {
+  a / b
+};
+let compute = fn(x) {
+  let half = fn(y) { divide(y, 0) };
+  1 + half(x)
+};
+let alias = compute;
+alias(10);
+`
+	evaluated := testEval(t, input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned, found %T(%+v)", evaluated, evaluated)
+	}
+
+	expectedPos := token.Position{Line: 2, Column: 3}
+	if errObj.Pos != expectedPos {
+		t.Errorf("wrong error position. expected=%s, found=%s", expectedPos,
+			errObj.Pos)
+	}
+
+	// half calls divide in tail position, so divide replaces it in the stack.
+	expectedStack := []object.Frame{
+		{Function: "divide", CallPos: token.Position{Line: 5, Column: 22}},
+		{Function: "compute", CallPos: token.Position{Line: 9, Column: 1}},
+	}
+	if len(errObj.Stack) != len(expectedStack) {
+		t.Fatalf("wrong stack. expected=%v, found=%v", expectedStack,
+			errObj.Stack)
+	}
+	for i, f := range expectedStack {
+		if errObj.Stack[i] != f {
+			t.Errorf("wrong frame %d. expected=%v, found=%v", i, f,
+				errObj.Stack[i])
+		}
+	}
+}
+
+func TestAnonymousFunctionFrame(t *testing.T) {
+	evaluated := testEval(t, "fn() { -true }()")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned, found %T(%+v)", evaluated, evaluated)
+	}
+	if len(errObj.Stack) != 1 || errObj.Stack[0].Function != "fn" {
+		t.Errorf("wrong stack. expected a single frame for fn, found %v",
+			errObj.Stack)
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	evaluated := testEval(t, `"Hello World!"`)
+	testStringObject(t, evaluated, "Hello World!")
+}
+
+func TestStringConcatenation(t *testing.T) {
+	evaluated := testEval(t, `"Hello" + " " + "World!"`)
+	testStringObject(t, evaluated, "Hello World!")
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`let s = "ab"; s == "a" + "b"`, true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`"a" + 1`, "type mismatch: STRING + INTEGER"},
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{`"abc"[0]`, "index operator not supported: STRING[INTEGER]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q, found %T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, found=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(t, input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash, found %T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs, found %d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`len("four")`, 4},
+		{`len("")`, 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestPuts(t *testing.T) {
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	evaluated := testEval(t, `puts(1, [true, 2]); puts()`)
+	testNullObject(t, evaluated)
+
+	expected := "1\n[true, 2]\n"
+	if out.String() != expected {
+		t.Errorf("puts wrote wrong output. expected=%q, found=%q", expected,
+			out.String())
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("hello\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	evaluated := testEval(t, fmt.Sprintf("readFile(%q)", f.Name()))
+	testStringObject(t, evaluated, "hello\n")
+
+	missing := f.Name() + ".missing"
+	evaluated = testEval(t, fmt.Sprintf("readFile(%q)", missing))
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. found=%T(%+v)", evaluated, evaluated)
+	}
+	expected := "readFile: open " + missing + ": no such file or directory"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, found=%q", expected,
+			errObj.Message)
+	}
+}
+
+func TestNow(t *testing.T) {
+	now = func() time.Time { return time.Unix(1500000000, 250000000) }
+	defer func() { now = time.Now }()
+
+	testIntegerObject(t, testEval(t, "now()"), 1500000000250)
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string // empty if the assertions hold
+		expectedPos     token.Position
+	}{
+		{`assert(1 < 2)`, "", token.Position{}},
+		{`assert(1 > 2)`, "assertion failed", token.Position{Line: 1, Column: 1}},
+		{`assert(len([]) > 0, "empty")`, "assertion failed: empty",
+			token.Position{Line: 1, Column: 1}},
+		{`assert(true, 1)`, "argument 2 to `assert` must be STRING, found INTEGER",
+			token.Position{Line: 1, Column: 1}},
+		{`assertEqual([1, {"a": [true]}], [1, {"a": [true]}])`, "",
+			token.Position{}},
+		{`let f = fn() { 1 }; assertEqual(f, f)`, "", token.Position{}},
+		{"let f = fn() {\n  assertEqual(1 + 2, 4) };\nf()",
+			"assertEqual: expected 4, found 3", token.Position{Line: 2, Column: 3}},
+		{`assertEqual("1", 1)`, `assertEqual: expected 1, found "1"`,
+			token.Position{Line: 1, Column: 1}},
+		{`assertEqual({1: 2}, {1: 3})`, "assertEqual: expected {1: 3}, found {1: 2}",
+			token.Position{Line: 1, Column: 1}},
+		{`assertEqual(assertError(fn() { 1 / 0 }), "division by zero: 1 / 0")`, "",
+			token.Position{}},
+		{`let f = fn(x) { if (x > 0) { f(x - 1) } else { -true } };
+assertEqual(assertError(fn() { f(10) }), "unknown operator: -BOOLEAN")`, "",
+			token.Position{}},
+		{`1 + assertError(fn() { 1 })`, "assertError: expected an error, found 1",
+			token.Position{Line: 1, Column: 5}},
+		{`assertError(len)`, "argument 1 to `assertError` must be FUNCTION, found BUILTIN",
+			token.Position{Line: 1, Column: 1}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if tt.expectedMessage == "" {
+			if ok {
+				t.Errorf("unexpected error for %q: %s", tt.input, errObj.Inspect())
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("no error object returned for %q, found %T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, found=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+		if errObj.Pos != tt.expectedPos {
+			t.Errorf("wrong error position for %q. expected=%s, found=%s",
+				tt.input, tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestAssertErrorLimits(t *testing.T) {
+	p := parser.New(lexer.New(`assertError(fn() { let f = fn() { 1 + f() }; f() })`))
+	_, err := EvalContext(context.Background(), p.ParseProgram(),
+		object.NewEnvironment(), Limits{MaxDepth: 10})
+	if le, ok := err.(*LimitError); !ok || le.Kind != DepthLimit {
+		t.Errorf("expected depth limit error, found %v", err)
+	}
+}
+
+func testEval(t *testing.T, input string) object.Object {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	env := object.NewEnvironment()
+
+	return Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer, found %T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value, expected=%d, found=%d",
+			expected, result.Value)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean, found %T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value, expected=%t, found=%t",
+			expected, result.Value)
+		return false
+	}
+	return true
+}
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String, found %T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value, expected=%q, found=%q",
+			expected, result.Value)
+		return false
+	}
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not NULL, found %T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// DefaultMaxDepth is the maximum depth of function calls when Limits doesn't
+// specify one. It keeps runaway recursion from exhausting the Go stack, which
+// would crash the host process. Calls in tail position don't grow the stack,
+// and don't count toward the depth, so a function that calls itself forever
+// in tail position, as in "let f = fn() { f() }; f()", runs until MaxSteps or
+// the context stops it.
+const DefaultMaxDepth = 10000
+
+// contextCheckInterval is the number of steps between checks of whether the
+// context of an evaluation is done.
+const contextCheckInterval = 256
+
+// Limits bounds the resources an evaluation may use, and the builtins it may
+// call. A zero field means there is no limit, except for MaxDepth, which
+// defaults to DefaultMaxDepth.
+type Limits struct {
+	// MaxSteps is the maximum number of nodes evaluated.
+	MaxSteps int
+	// MaxDepth is the maximum depth of nested function calls. Calls in tail
+	// position replace the calls making them, so MaxDepth doesn't bound
+	// loops written as tail recursion; only MaxSteps and the context do.
+	MaxDepth int
+	// MaxObjects is the maximum number of objects allocated. Each integer,
+	// string, function, and function call's environment counts as one
+	// object, and each array or hash as one object plus one for each of its
+	// elements or pairs. The value of a builtin counts as newly allocated.
+	MaxObjects int
+	// Sandbox restricts the builtins the program may use.
+	Sandbox *Sandbox
+}
+
+// LimitKind identifies the reason evaluation was stopped.
+type LimitKind int
+
+const (
+	StepLimit   LimitKind = iota + 1 // Limits.MaxSteps was exceeded
+	DepthLimit                       // Limits.MaxDepth was exceeded
+	ObjectLimit                      // Limits.MaxObjects was exceeded
+	ContextDone                      // the context was canceled or timed out
+)
+
+// LimitError is the error returned when evaluation is stopped, either because
+// it exceeded one of its limits or because its context is done.
+type LimitError struct {
+	Kind LimitKind
+	Max  int            // the limit exceeded, unless Kind is ContextDone
+	Pos  token.Position // the position evaluation had reached
+	Err  error          // the context's error, if Kind is ContextDone
+	// Stack holds the function calls active when evaluation was stopped,
+	// innermost first, as in an *object.Error.
+	Stack []object.Frame
+}
+
+func (e *LimitError) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.message()
+	}
+	return e.message()
+}
+
+// Unwrap returns the context's error, if evaluation stopped because the
+// context is done, so that errors.Is(err, context.Canceled) and the like work.
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *LimitError) message() string {
+	switch e.Kind {
+	case StepLimit:
+		return fmt.Sprintf("maximum number of steps (%d) exceeded", e.Max)
+	case DepthLimit:
+		return fmt.Sprintf("maximum call depth (%d) exceeded", e.Max)
+	case ObjectLimit:
+		return fmt.Sprintf("maximum number of objects (%d) exceeded", e.Max)
+	default:
+		return fmt.Sprintf("evaluation stopped: %s", e.Err)
+	}
+}
+
+// step counts the evaluation of node, stopping evaluation if there have been
+// too many steps or the context is done.
+func (i *interpreter) step(node ast.Node) {
+	i.steps++
+	if i.limits.MaxSteps > 0 && i.steps > i.limits.MaxSteps {
+		panic(&LimitError{Kind: StepLimit, Max: i.limits.MaxSteps,
+			Pos: ast.Pos(node), Stack: i.stack()})
+	}
+	if i.steps%contextCheckInterval == 0 {
+		if err := i.ctx.Err(); err != nil {
+			panic(&LimitError{Kind: ContextDone, Pos: ast.Pos(node), Err: err,
+				Stack: i.stack()})
+		}
+	}
+}
+
+// enter records the call of fn made by the call node, stopping evaluation if
+// calls are nested too deeply. The caller must call leave when the call
+// returns.
+func (i *interpreter) enter(fn *object.Function, call ast.Node) {
+	if len(i.calls) >= i.limits.MaxDepth {
+		panic(&LimitError{Kind: DepthLimit, Max: i.limits.MaxDepth,
+			Pos: ast.Pos(call), Stack: i.stack()})
+	}
+	i.calls = append(i.calls, newFrame(fn, ast.Pos(call)))
+}
+
+// leave records the return of the innermost call.
+func (i *interpreter) leave() {
+	i.calls = i.calls[:len(i.calls)-1]
+}
+
+// stack returns the active function calls, innermost first.
+func (i *interpreter) stack() []object.Frame {
+	stack := make([]object.Frame, len(i.calls))
+	for n, f := range i.calls {
+		stack[len(stack)-1-n] = f
+	}
+	return stack
+}
+
+// alloc counts obj, the value of node, as allocated, and returns it.
+func (i *interpreter) alloc(node ast.Node, obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Integer, *object.String, *object.Function:
+		i.allocN(1, node)
+	case *object.Array:
+		i.allocN(1+len(obj.Elements), node)
+	case *object.Hash:
+		i.allocN(1+len(obj.Pairs), node)
+	}
+	return obj
+}
+
+// allocN counts n objects allocated in evaluating node, stopping evaluation if
+// too many objects have been allocated.
+func (i *interpreter) allocN(n int, node ast.Node) {
+	i.objects += n
+	if i.limits.MaxObjects > 0 && i.objects > i.limits.MaxObjects {
+		panic(&LimitError{Kind: ObjectLimit, Max: i.limits.MaxObjects,
+			Pos: ast.Pos(node), Stack: i.stack()})
+	}
+}
@@ -0,0 +1,141 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/parser"
+	"github.com/adamvinueza/monkey/token"
+)
+
+const fib = `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+`
+
+func TestEvalContextLimits(t *testing.T) {
+	tests := []struct {
+		input       string
+		limits      Limits
+		expectedErr string // empty if evaluation should succeed
+	}{
+		{fib + "fib(10)", Limits{}, ""},
+		{fib + "fib(10)", Limits{MaxSteps: 100},
+			"2:43: maximum number of steps (100) exceeded"},
+		{fib + "fib(15)", Limits{MaxSteps: 100000}, ""},
+		{"let f = fn() { 1 + f() };\nf()", Limits{MaxDepth: 50},
+			"1:20: maximum call depth (50) exceeded"},
+		{"let f = fn() { f() };\nf()", Limits{MaxSteps: 1000},
+			"1:16: maximum number of steps (1000) exceeded"},
+		{"let f = fn() { f() };\nf()", Limits{MaxSteps: 1000, MaxDepth: 50},
+			"1:16: maximum number of steps (1000) exceeded"},
+		{fib + "fib(10)", Limits{MaxDepth: 10}, ""},
+		{fib + "fib(10)", Limits{MaxDepth: 9},
+			"2:43: maximum call depth (9) exceeded"},
+		{"[1, 2, 3]", Limits{MaxObjects: 7}, ""},
+		{"[1, 2, 3]", Limits{MaxObjects: 6},
+			"1:1: maximum number of objects (6) exceeded"},
+		{`let grow = fn(a, n) { if (n == 0) { a } else { grow(push(a, n), n - 1) } };
+grow([], 1000)`, Limits{MaxObjects: 10000},
+			"1:32: maximum number of objects (10000) exceeded"},
+	}
+
+	for _, tt := range tests {
+		_, err := testEvalContext(t, context.Background(), tt.input, tt.limits)
+		if tt.expectedErr == "" {
+			if err != nil {
+				t.Errorf("unexpected error for %q: %s", tt.input, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("expected error %q for %q, found none", tt.expectedErr,
+				tt.input)
+			continue
+		}
+		if _, ok := err.(*LimitError); !ok {
+			t.Errorf("error is not *LimitError, found %T", err)
+		}
+		if err.Error() != tt.expectedErr {
+			t.Errorf("wrong error for %q. expected=%q, found=%q", tt.input,
+				tt.expectedErr, err.Error())
+		}
+	}
+}
+
+func TestEvalContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := testEvalContext(t, ctx, fib+"fib(50)", Limits{})
+	le, ok := err.(*LimitError)
+	if !ok {
+		t.Fatalf("error is not *LimitError, found %T (%v)", err, err)
+	}
+	if le.Kind != ContextDone {
+		t.Errorf("wrong kind. expected=%d, found=%d", ContextDone, le.Kind)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error is not context.DeadlineExceeded: %v", err)
+	}
+
+	// MaxDepth doesn't stop a loop written as tail recursion, but the
+	// context does.
+	loop, cancelLoop := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancelLoop()
+	_, err = testEvalContext(t, loop, "let f = fn() { f() };\nf()",
+		Limits{MaxDepth: 50})
+	if le, ok := err.(*LimitError); !ok || le.Kind != ContextDone {
+		t.Errorf("expected ContextDone error, found %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = testEvalContext(t, canceled, "1", Limits{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error is not context.Canceled: %v", err)
+	}
+}
+
+func TestEvalDefaultMaxDepth(t *testing.T) {
+	evaluated := testEval(t, "let f = fn() { 1 + f() };\nf()")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned, found %T(%+v)", evaluated, evaluated)
+	}
+	expected := "maximum call depth (10000) exceeded"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, found=%q", expected,
+			errObj.Message)
+	}
+	expectedPos := token.Position{Line: 1, Column: 20}
+	if errObj.Pos != expectedPos {
+		t.Errorf("wrong error position. expected=%s, found=%s", expectedPos,
+			errObj.Pos)
+	}
+	if len(errObj.Stack) != DefaultMaxDepth {
+		t.Fatalf("wrong stack depth. expected=%d, found=%d", DefaultMaxDepth,
+			len(errObj.Stack))
+	}
+	expectedFrame := object.Frame{Function: "f",
+		CallPos: token.Position{Line: 2, Column: 1}}
+	if last := errObj.Stack[len(errObj.Stack)-1]; last != expectedFrame {
+		t.Errorf("wrong outermost frame. expected=%v, found=%v", expectedFrame,
+			last)
+	}
+}
+
+func testEvalContext(t *testing.T, ctx context.Context, input string,
+	limits Limits) (object.Object, error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return EvalContext(ctx, program, object.NewEnvironment(), limits)
+}
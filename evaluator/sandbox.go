@@ -0,0 +1,51 @@
+package evaluator
+
+import (
+	"github.com/adamvinueza/monkey/object"
+)
+
+// Sandbox restricts the builtins a program may use to those it allows, so that
+// programs can be denied capabilities such as reading files or the clock.
+// Using a builtin that isn't allowed is an error: "permission denied". A nil
+// *Sandbox allows all builtins.
+//
+// Sandboxes only restrict the builtins found by name; functions the host binds
+// in the environment are always available.
+type Sandbox struct {
+	// Allow lists the builtins a program may use, either a module at a time,
+	// as in "io", or one at a time, by their qualified names, as in
+	// "io.readFile". A builtin without a module is allowed by its name alone.
+	Allow []string
+}
+
+// allows reports whether b may be used under s.
+func (s *Sandbox) allows(b *object.Builtin) bool {
+	if s == nil {
+		return true
+	}
+	name := qualifiedName(b)
+	for _, allowed := range s.Allow {
+		if allowed == name || b.Module != "" && allowed == b.Module {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns the error of using b under s, or nil if s allows it. The
+// virtual machines use it to apply the evaluator's sandbox to the builtins of
+// compiled programs.
+func (s *Sandbox) Check(b *object.Builtin) *object.Error {
+	if s.allows(b) {
+		return nil
+	}
+	return newError("permission denied: %s is not allowed", qualifiedName(b))
+}
+
+// qualifiedName returns the name of b qualified by its module, if it has one.
+func qualifiedName(b *object.Builtin) string {
+	if b.Module == "" {
+		return b.Name
+	}
+	return b.Module + "." + b.Name
+}
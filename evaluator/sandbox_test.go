@@ -0,0 +1,105 @@
+package evaluator
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/adamvinueza/monkey/object"
+)
+
+func TestSandbox(t *testing.T) {
+	tests := []struct {
+		input           string
+		sandbox         *Sandbox
+		expectedMessage string // empty if evaluation should succeed
+	}{
+		{`len([1])`, nil, ""},
+		{`let p = puts; 1`, nil, ""},
+		{`len([1])`, &Sandbox{Allow: []string{"core"}}, ""},
+		{`len([1])`, &Sandbox{Allow: []string{"core.len"}}, ""},
+		{`len([1])`, &Sandbox{},
+			"permission denied: core.len is not allowed"},
+		{`first([1])`, &Sandbox{Allow: []string{"core.len"}},
+			"permission denied: core.first is not allowed"},
+		{`puts(1)`, &Sandbox{Allow: []string{"core"}},
+			"permission denied: io.puts is not allowed"},
+		{`readFile("/etc/passwd")`, &Sandbox{Allow: []string{"core", "time"}},
+			"permission denied: io.readFile is not allowed"},
+		{`now()`, &Sandbox{Allow: []string{"core", "io"}},
+			"permission denied: time.now is not allowed"},
+		{`now() > 0`, &Sandbox{Allow: []string{"time.now"}}, ""},
+		// Denied builtins can't be reached indirectly either.
+		{`let r = readFile; 1`, &Sandbox{Allow: []string{"core"}},
+			"permission denied: io.readFile is not allowed"},
+		{`let call = fn(f) { f("/etc/passwd") }; call(readFile)`,
+			&Sandbox{Allow: []string{"core"}},
+			"permission denied: io.readFile is not allowed"},
+		// Programs may still bind the names of denied builtins themselves.
+		{`let puts = fn(x) { x }; puts(1)`, &Sandbox{}, ""},
+		{`let io = 1; io`, &Sandbox{Allow: []string{"io.readFile.x"}}, ""},
+	}
+
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	for _, tt := range tests {
+		evaluated, err := testEvalContext(t, context.Background(), tt.input,
+			Limits{Sandbox: tt.sandbox})
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err)
+			continue
+		}
+		errObj, isErr := evaluated.(*object.Error)
+		if tt.expectedMessage == "" {
+			if isErr {
+				t.Errorf("unexpected error for %q: %s", tt.input,
+					errObj.Message)
+			}
+			continue
+		}
+		if !isErr {
+			t.Errorf("expected error %q for %q, found %s", tt.expectedMessage,
+				tt.input, evaluated.Inspect())
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error for %q. expected=%q, found=%q", tt.input,
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("sandboxed programs wrote output %q", out.String())
+	}
+}
+
+func TestSandboxRegisteredBuiltin(t *testing.T) {
+	RegisterBuiltin(&object.Builtin{Name: "testUnqualified",
+		Fn: func(args ...object.Object) object.Object { return NULL }})
+	defer delete(builtins, "testUnqualified")
+
+	tests := []struct {
+		sandbox *Sandbox
+		allowed bool
+	}{
+		{nil, true},
+		{&Sandbox{}, false},
+		{&Sandbox{Allow: []string{""}}, false},
+		{&Sandbox{Allow: []string{"testUnqualified"}}, true},
+	}
+
+	for _, tt := range tests {
+		evaluated, err := testEvalContext(t, context.Background(),
+			"testUnqualified()", Limits{Sandbox: tt.sandbox})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, isErr := evaluated.(*object.Error); isErr == tt.allowed {
+			t.Errorf("sandbox %+v: expected allowed=%t, found %s", tt.sandbox,
+				tt.allowed, evaluated.Inspect())
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package evaluator
+
+import (
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/object"
+)
+
+// tailCall is the value of a call in tail position, one whose value becomes
+// the value of the function making it: the last expression of the function's
+// body, or the value of a return statement. Rather than making the call, which
+// would grow the Go stack, the function evaluates to a tailCall, and
+// applyFunction makes the call in its place. This way, recursion in tail
+// position runs in constant space, like a loop.
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+	call *ast.CallExpression
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// evalTail is like eval, but evaluates node as part of the body of a function,
+// so that calls in tail position evaluate to a *tailCall. If last is true, the
+// value of node is the value of the function body.
+func (i *interpreter) evalTail(node ast.Node, env *object.Environment,
+	last bool) object.Object {
+	i.step(node)
+	result := i.evalTailNode(node, env, last)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = ast.Pos(node)
+	}
+	return result
+}
+
+func (i *interpreter) evalTailNode(node ast.Node, env *object.Environment,
+	last bool) object.Object {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		var result object.Object
+		for n, statement := range node.Statements {
+			result = i.evalTail(statement, env,
+				last && n == len(node.Statements)-1)
+			if result != nil {
+				rt := result.Type()
+				if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+					return result
+				}
+			}
+		}
+		return result
+	case *ast.ExpressionStatement:
+		return i.evalTail(node.Expression, env, last)
+	case *ast.ReturnStatement:
+		// A return statement ends the function wherever it is, so its value
+		// is always in tail position.
+		val := i.evalTail(node.ReturnValue, env, true)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.IfExpression:
+		// Even if the if expression isn't last, its blocks may hold return
+		// statements.
+		condition := i.eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return i.evalTail(node.Consequence, env, last)
+		} else if node.Alternative != nil {
+			return i.evalTail(node.Alternative, env, last)
+		}
+		return NULL
+	case *ast.CallExpression:
+		if !last {
+			return i.evalNode(node, env)
+		}
+		function := i.eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := i.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		// Calls that fail immediately, and calls of builtins, which don't
+		// evaluate Monkey code, are made directly.
+		fn, ok := function.(*object.Function)
+		if !ok || len(args) != len(fn.Parameters) {
+			return i.applyFunction(function, args, node)
+		}
+		return &tailCall{fn: fn, args: args, call: node}
+	default:
+		return i.evalNode(node, env)
+	}
+}
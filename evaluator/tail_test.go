@@ -0,0 +1,117 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/token"
+)
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } };\n" +
+			"loop(1000000)", 0},
+		{"let sum = fn(n, acc) { if (n == 0) { return acc; } sum(n - 1, acc + n) };\n" +
+			"sum(100000, 0)", 5000050000},
+		{"let count = fn(n) { if (n > 0) { return count(n - 1); } 42 };\n" +
+			"count(100000)", 42},
+		{`let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+isEven(100001)`, false},
+		{"let f = fn(xs) { if (len(xs) == 0) { 0 } else { f(rest(xs)) } };\n" +
+			"f([1, 2, 3])", 0},
+		// A call that isn't last isn't in tail position.
+		{"let f = fn(n) { if (n == 0) { 1 } else { f(n - 1); 2 } }; f(3)", 2},
+		{"let f = fn(n) { if (n == 0) { 1 } else { 2 * f(n - 1) } }; f(10)",
+			1024},
+		// Neither is a call in another function's body.
+		{"let f = fn() { let g = fn() { 1 }; g() + 1 }; f()", 2},
+		{"let f = fn(g) { g(1) }; f(fn(x) { x + 1 })", 2},
+		{"let f = fn() { len([1, 2]) }; f()", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestTailCallErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+		expectedPos     token.Position
+		expectedStack   []string
+	}{
+		{"let f = fn(x) { x };\nlet g = fn() { f(1, 2) };\ng()",
+			"wrong number of arguments: expected 1, found 2",
+			token.Position{Line: 2, Column: 16}, []string{"g"}},
+		{"let g = fn() { 1() };\ng()", "not a function: INTEGER",
+			token.Position{Line: 1, Column: 16}, []string{"g"}},
+		{"let f = fn(n) { if (n == 0) { -true } else { f(n - 1) } };\nf(10)",
+			"unknown operator: -BOOLEAN",
+			token.Position{Line: 1, Column: 31}, []string{"f"}},
+		{"let f = fn() { -true };\nlet g = fn() { return f(); };\n1 + g()",
+			"unknown operator: -BOOLEAN",
+			token.Position{Line: 1, Column: 16}, []string{"f"}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q, found %T(%+v)",
+				tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message for %q. expected=%q, found=%q",
+				tt.input, tt.expectedMessage, errObj.Message)
+		}
+		if errObj.Pos != tt.expectedPos {
+			t.Errorf("wrong error position for %q. expected=%s, found=%s",
+				tt.input, tt.expectedPos, errObj.Pos)
+		}
+		var stack []string
+		for _, f := range errObj.Stack {
+			stack = append(stack, f.Function)
+		}
+		if len(stack) != len(tt.expectedStack) {
+			t.Errorf("wrong stack for %q. expected=%v, found=%v", tt.input,
+				tt.expectedStack, stack)
+			continue
+		}
+		for i := range stack {
+			if stack[i] != tt.expectedStack[i] {
+				t.Errorf("wrong stack for %q. expected=%v, found=%v", tt.input,
+					tt.expectedStack, stack)
+				break
+			}
+		}
+	}
+}
+
+func TestTailCallLimits(t *testing.T) {
+	input := "let f = fn() { f() };\nf()"
+	_, err := testEvalContext(t, context.Background(), input,
+		Limits{MaxSteps: 10000})
+	le, ok := err.(*LimitError)
+	if !ok || le.Kind != StepLimit {
+		t.Errorf("error is not a step limit error: %v", err)
+	}
+
+	input = "let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } };\nf(1000)"
+	if _, err := testEvalContext(t, context.Background(), input,
+		Limits{MaxDepth: 1}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
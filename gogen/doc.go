@@ -0,0 +1,20 @@
+// Package gogen translates Monkey programs into Go programs, which can be
+// compiled to native code by the Go toolchain. A translated program writes the
+// same output as the evaluator, and fails with the same errors and stack
+// traces:
+//  src, err := gogen.Program(program, "fib.mk")
+//  if err != nil {
+//      log.Fatal(err)
+//  }
+//  err = ioutil.WriteFile("fib.go", src, 0644)
+//
+// The translation relies on package rt, the runtime providing Monkey's values
+// and operations, so it must be built in a module requiring this one.
+//
+// Each Monkey function becomes a Go closure, and each Monkey variable a Go
+// variable of the function binding it. A variable is nil until its let
+// statement runs; until then, reading it reads the variable of the same name
+// in an enclosing function, or the builtin, as in the evaluator. Expressions
+// are evaluated into temporary variables, one operation at a time, so that
+// they are evaluated in the same order as by the evaluator.
+package gogen
@@ -0,0 +1,477 @@
+package gogen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/object"
+)
+
+// Program returns the source text of a Go program that runs program, which was
+// read from filename. The program's errors are reported with filename, as by
+// "monkey run". Program returns an error if program holds a node that can't be
+// translated, as a program produced by a parser without errors doesn't.
+func Program(program *ast.Program, filename string) ([]byte, error) {
+	t := newTranslator()
+	body := t.program(program)
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by monkey build from %s. DO NOT EDIT.\n\n",
+		filename)
+	out.WriteString("package main\n\n")
+	out.WriteString(imports)
+	fmt.Fprintf(&out, "func main() {\n\trt.Main(%q, program)\n}\n\n", filename)
+	out.WriteString(t.constants())
+	fmt.Fprintf(&out, "func program() object.Object {\n%s}\n", body)
+	return format.Source(out.Bytes())
+}
+
+// imports is the import declaration of a translated program.
+const imports = `import (
+	"github.com/adamvinueza/monkey/gogen/rt"
+	"github.com/adamvinueza/monkey/object"
+)
+
+`
+
+// translator holds the state of a translation.
+type translator struct {
+	// decls holds the declarations of the constants of the program, which
+	// names holds by value.
+	decls []string
+	names map[interface{}]string
+	err   error // the first error, which stops translation
+}
+
+// source is the representation of a function, as distinct from a string
+// constant.
+type source string
+
+func newTranslator() *translator {
+	return &translator{names: map[interface{}]string{}}
+}
+
+// program returns the body of the Go function the top level of program is
+// translated into.
+func (t *translator) program(program *ast.Program) string {
+	s := newScope(0)
+	declare(s, &ast.BlockStatement{Statements: program.Statements})
+	f := &function{t: t, scopes: []*scope{s}}
+	// The top level of the program makes no calls in tail position.
+	f.statements(program.Statements, dest{ret: true}, false)
+	return f.body()
+}
+
+// constant returns the name of the constant holding value, which is an int64,
+// a string, or a source.
+func (t *translator) constant(value interface{}) string {
+	if name, ok := t.names[value]; ok {
+		return name
+	}
+	name := fmt.Sprintf("k%d", len(t.decls))
+	var decl string
+	switch value := value.(type) {
+	case int64:
+		decl = fmt.Sprintf("%s = rt.Int(%d)", name, value)
+	case string:
+		decl = fmt.Sprintf("%s = rt.Str(%q)", name, value)
+	case source:
+		decl = fmt.Sprintf("%s = %q", name, string(value))
+	}
+	t.decls = append(t.decls, decl)
+	t.names[value] = name
+	return name
+}
+
+// constants returns the declaration of the constants of the program.
+func (t *translator) constants() string {
+	if len(t.decls) == 0 {
+		return ""
+	}
+	return "var (\n\t" + strings.Join(t.decls, "\n\t") + "\n)\n\n"
+}
+
+// fail records that node can't be translated.
+func (t *translator) fail(node ast.Node) {
+	if t.err == nil {
+		t.err = fmt.Errorf("%s: can't translate %T", ast.Pos(node), node)
+	}
+}
+
+// scope holds the variables of a Monkey function: its parameters and those
+// bound by let statements in its body.
+type scope struct {
+	depth  int // the number of functions enclosing the function
+	names  []string
+	params map[string]bool
+	vars   map[string]bool
+	read   map[string]bool // the variables that are read
+}
+
+func newScope(depth int) *scope {
+	return &scope{
+		depth:  depth,
+		params: map[string]bool{},
+		vars:   map[string]bool{},
+		read:   map[string]bool{},
+	}
+}
+
+func (s *scope) declare(name string) {
+	if !s.vars[name] {
+		s.vars[name] = true
+		s.names = append(s.names, name)
+	}
+}
+
+// variable returns the name of the Go variable holding the variable name.
+func (s *scope) variable(name string) string {
+	return fmt.Sprintf("v%d_%s", s.depth, name)
+}
+
+// declare declares the variables bound by let statements in node, other than
+// those in the bodies of function literals, which have scopes of their own.
+func declare(s *scope, node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		s.declare(node.Name.Value)
+		declare(s, node.Value)
+	case *ast.ExpressionStatement:
+		declare(s, node.Expression)
+	case *ast.ReturnStatement:
+		declare(s, node.ReturnValue)
+	case *ast.BlockStatement:
+		for _, statement := range node.Statements {
+			declare(s, statement)
+		}
+	case *ast.IfExpression:
+		declare(s, node.Condition)
+		declare(s, node.Consequence)
+		if node.Alternative != nil {
+			declare(s, node.Alternative)
+		}
+	case *ast.PrefixExpression:
+		declare(s, node.Right)
+	case *ast.InfixExpression:
+		declare(s, node.Left)
+		declare(s, node.Right)
+	case *ast.CallExpression:
+		declare(s, node.Function)
+		for _, a := range node.Arguments {
+			declare(s, a)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			declare(s, el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			declare(s, pair.Key)
+			declare(s, pair.Value)
+		}
+	case *ast.IndexExpression:
+		declare(s, node.Left)
+		declare(s, node.Index)
+	}
+}
+
+// function holds the translation of a Monkey function, or of the top level of
+// the program, into a Go function.
+type function struct {
+	t      *translator
+	scopes []*scope // the scopes of the enclosing functions, innermost last
+	out    bytes.Buffer
+	temps  int
+}
+
+// dest is what is done with the value of an expression: it is returned from
+// the function, assigned to the variable temp, or, if temp is empty,
+// discarded.
+type dest struct {
+	ret  bool
+	temp string
+}
+
+// body returns the body of the Go function: the declarations of the
+// variables, followed by the statements translated.
+func (f *function) body() string {
+	var out bytes.Buffer
+	s := f.scopes[len(f.scopes)-1]
+	for _, name := range s.names {
+		if !s.params[name] {
+			fmt.Fprintf(&out, "var %s object.Object\n", s.variable(name))
+		}
+		if !s.read[name] {
+			fmt.Fprintf(&out, "_ = %s\n", s.variable(name))
+		}
+	}
+	out.Write(f.out.Bytes())
+	return out.String()
+}
+
+func (f *function) emit(format string, a ...interface{}) {
+	fmt.Fprintf(&f.out, format+"\n", a...)
+}
+
+// temp assigns the value of code to a new temporary variable, and returns its
+// name.
+func (f *function) temp(code string) string {
+	f.temps++
+	name := fmt.Sprintf("t%d", f.temps)
+	f.emit("%s := %s", name, code)
+	return name
+}
+
+// statements translates a sequence of statements, whose value, that of the
+// last, goes to dest. If tail is true, the statements are in the body of a
+// function, where return statements make calls in tail position.
+func (f *function) statements(stmts []ast.Statement, to dest, tail bool) {
+	for n, stmt := range stmts {
+		last := n == len(stmts)-1
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			s := f.scopes[len(f.scopes)-1]
+			f.emit("%s = rt.Name(%s, %q)", s.variable(stmt.Name.Value),
+				f.expression(stmt.Value), stmt.Name.Value)
+			if last {
+				f.deliver("rt.Null", to)
+			}
+		case *ast.ReturnStatement:
+			// The statements after a return statement can't be reached.
+			f.value(stmt.ReturnValue, dest{ret: true}, tail)
+			return
+		case *ast.ExpressionStatement:
+			if last {
+				f.value(stmt.Expression, to, tail)
+			} else {
+				f.value(stmt.Expression, dest{}, tail)
+			}
+		default:
+			f.t.fail(stmt)
+		}
+	}
+	if len(stmts) == 0 {
+		f.deliver("rt.Null", to)
+	}
+}
+
+// value translates expr, sending its value to dest. If tail is true, as for
+// statements, a call whose value is returned is made in tail position.
+func (f *function) value(expr ast.Expression, to dest, tail bool) {
+	switch expr := expr.(type) {
+	case *ast.IfExpression:
+		f.ifStatement(expr, to, tail)
+		return
+	case *ast.CallExpression:
+		if to.ret && tail {
+			f.emit("return %s", f.call(expr, "rt.TailCall"))
+			return
+		}
+	}
+	f.deliver(f.expression(expr), to)
+}
+
+// deliver sends the value of code to dest.
+func (f *function) deliver(code string, to dest) {
+	switch {
+	case to.ret:
+		f.emit("return %s", code)
+	case to.temp != "":
+		f.emit("%s = %s", to.temp, code)
+	case strings.HasSuffix(code, ")"):
+		// The value is discarded, but the call computing it may fail or
+		// have effects.
+		f.emit("%s", code)
+	case strings.HasPrefix(code, "v"):
+		// The variable is used, as far as Go is concerned.
+		f.emit("_ = %s", code)
+	}
+}
+
+// ifStatement translates an if expression into a Go if statement, sending
+// the value of the branch taken to dest.
+func (f *function) ifStatement(expr *ast.IfExpression, to dest, tail bool) {
+	f.emit("if rt.Truthy(%s) {", f.expression(expr.Condition))
+	f.statements(expr.Consequence.Statements, to, tail)
+	if expr.Alternative != nil {
+		f.emit("} else {")
+		f.statements(expr.Alternative.Statements, to, tail)
+	} else if to.ret || to.temp != "" {
+		f.emit("} else {")
+		f.deliver("rt.Null", to)
+	}
+	f.emit("}")
+}
+
+// operand translates expr, returning a constant or temporary variable holding
+// its value. Other variables are copied, since they may be assigned before the
+// operand is used.
+func (f *function) operand(expr ast.Expression) string {
+	code := f.expression(expr)
+	switch {
+	case code == "rt.True", code == "rt.False", strings.HasPrefix(code, "k"),
+		strings.HasPrefix(code, "t"):
+		return code
+	}
+	return f.temp(code)
+}
+
+func (f *function) operands(exprs []ast.Expression) []string {
+	codes := []string{}
+	for _, e := range exprs {
+		codes = append(codes, f.operand(e))
+	}
+	return codes
+}
+
+// expression translates expr, returning Go code computing its value once the
+// statements emitted meanwhile have run. The code is a constant, a variable,
+// or a call.
+func (f *function) expression(expr ast.Expression) string {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return f.t.constant(expr.Value)
+	case *ast.StringLiteral:
+		return f.t.constant(expr.Value)
+	case *ast.Boolean:
+		if expr.Value {
+			return "rt.True"
+		}
+		return "rt.False"
+	case *ast.Identifier:
+		return f.identifier(expr)
+	case *ast.PrefixExpression:
+		right := f.operand(expr.Right)
+		switch expr.Operator {
+		case "!":
+			return fmt.Sprintf("rt.Not(%s)", right)
+		case "-":
+			return fmt.Sprintf("rt.Neg(%s, %s)", pos(expr), right)
+		}
+	case *ast.InfixExpression:
+		left := f.operand(expr.Left)
+		right := f.operand(expr.Right)
+		if op, ok := infixOperators[expr.Operator]; ok {
+			return fmt.Sprintf("rt.%s(%s, %s, %s)", op, pos(expr), left, right)
+		}
+	case *ast.IfExpression:
+		f.temps++
+		temp := fmt.Sprintf("t%d", f.temps)
+		f.emit("var %s object.Object", temp)
+		f.ifStatement(expr, dest{temp: temp}, false)
+		return temp
+	case *ast.FunctionLiteral:
+		return f.functionLiteral(expr)
+	case *ast.CallExpression:
+		return f.call(expr, "rt.Call")
+	case *ast.ArrayLiteral:
+		return fmt.Sprintf("rt.Array(%s)",
+			strings.Join(f.operands(expr.Elements), ", "))
+	case *ast.HashLiteral:
+		pairs := []string{}
+		for _, pair := range expr.Pairs {
+			key := f.operand(pair.Key)
+			f.emit("rt.HashKey(%s, %s)", pos(expr), key)
+			pairs = append(pairs, key, f.operand(pair.Value))
+		}
+		return fmt.Sprintf("rt.Hash(%s)", strings.Join(pairs, ", "))
+	case *ast.IndexExpression:
+		left := f.operand(expr.Left)
+		index := f.operand(expr.Index)
+		return fmt.Sprintf("rt.Index(%s, %s, %s)", pos(expr), left, index)
+	}
+	f.t.fail(expr)
+	return "rt.Null"
+}
+
+// infixOperators maps each infix operator to the function of package rt
+// applying it.
+var infixOperators = map[string]string{
+	"+":  "Add",
+	"-":  "Sub",
+	"*":  "Mul",
+	"/":  "Div",
+	"<":  "Lt",
+	">":  "Gt",
+	"==": "Eq",
+	"!=": "NotEq",
+}
+
+// identifier returns code reading the variable ident refers to: the innermost
+// of the variables of the enclosing functions called ident that has been
+// assigned, or else the builtin called ident.
+func (f *function) identifier(ident *ast.Identifier) string {
+	name := ident.Value
+	values := []string{}
+	for i := len(f.scopes) - 1; i >= 0; i-- {
+		s := f.scopes[i]
+		if !s.vars[name] {
+			continue
+		}
+		s.read[name] = true
+		values = append(values, s.variable(name))
+		if s.params[name] {
+			// A parameter is always assigned, so it is read directly if
+			// it's the only candidate.
+			if len(values) == 1 {
+				return values[0]
+			}
+			break
+		}
+	}
+	args := append([]string{pos(ident), fmt.Sprintf("%q", name)}, values...)
+	return fmt.Sprintf("rt.Get(%s)", strings.Join(args, ", "))
+}
+
+// call returns code calling the function of expr with its arguments, using the
+// function of package rt called by name.
+func (f *function) call(expr *ast.CallExpression, name string) string {
+	args := append([]string{pos(expr), f.operand(expr.Function)},
+		f.operands(expr.Arguments)...)
+	return fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+}
+
+// functionLiteral returns code creating the function of expr, whose body is
+// translated into a Go closure.
+func (f *function) functionLiteral(expr *ast.FunctionLiteral) string {
+	s := newScope(len(f.scopes))
+	for _, p := range expr.Parameters {
+		s.declare(p.Value)
+		s.params[p.Value] = true
+	}
+	declare(s, expr.Body)
+	scopes := append(f.scopes[:len(f.scopes):len(f.scopes)], s)
+	inner := &function{t: f.t, scopes: scopes}
+	inner.statements(expr.Body.Statements, dest{ret: true}, true)
+
+	var out bytes.Buffer
+	src := (&object.Function{Parameters: expr.Parameters, Body: expr.Body}).Inspect()
+	fmt.Fprintf(&out, "rt.NewFunction(%s, %d, func(args []object.Object) object.Object {\n",
+		f.t.constant(source(src)), len(expr.Parameters))
+	// Parameters are declared in the order they are assigned, so that the
+	// last of those with the same name wins.
+	assigned := map[string]bool{}
+	for n, p := range expr.Parameters {
+		op := ":="
+		if assigned[p.Value] {
+			op = "="
+		}
+		assigned[p.Value] = true
+		fmt.Fprintf(&out, "%s %s args[%d]\n", s.variable(p.Value), op, n)
+	}
+	out.WriteString(inner.body())
+	out.WriteString("})")
+	return out.String()
+}
+
+// pos returns code for the position of node.
+func pos(node ast.Node) string {
+	p := ast.Pos(node)
+	return fmt.Sprintf("rt.Pos(%d, %d)", p.Line, p.Column)
+}
@@ -0,0 +1,246 @@
+package gogen
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/ast/build"
+	"github.com/adamvinueza/monkey/difftest"
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+var (
+	seed     = flag.Int64("seed", 1, "seed for random programs")
+	programs = flag.Int("programs", 500, "number of random programs to run")
+)
+
+func TestProgram(t *testing.T) {
+	src, err := Program(parse(t, "let x = 1; puts(x + 2);"), "x.mk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"// Code generated by monkey build from x.mk. DO NOT EDIT.\n",
+		"\trt.Main(\"x.mk\", program)\n",
+		"\tv0_x = rt.Name(k0, \"x\")\n",
+		"rt.Add(rt.Pos(1, 17), t2, k1)",
+	} {
+		if !strings.Contains(string(src), expected) {
+			t.Errorf("translation doesn't contain %q:\n%s", expected, src)
+		}
+	}
+}
+
+func TestProgramErrors(t *testing.T) {
+	tests := []struct {
+		program  *ast.Program
+		expected string
+	}{
+		{
+			build.Program(build.ExprStmt(&ast.PrefixExpression{
+				Operator: "~",
+				Right:    build.Int(1),
+			})),
+			"-: can't translate *ast.PrefixExpression",
+		},
+		{
+			build.Program(build.Block(build.ExprStmt(build.Int(1)))),
+			"-: can't translate *ast.BlockStatement",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := Program(tt.program, "x.mk")
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. expected=%q, got=%v",
+				ast.SExpr(tt.program), tt.expected, err)
+		}
+	}
+}
+
+// TestCorpus checks that translated programs write the same output as the
+// evaluator, and have the same values or fail with the same errors. The
+// programs are those of the corpus of package difftest and of testdata, along
+// with random programs.
+func TestCorpus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping building translated programs in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+
+	names := []string{}
+	byName := map[string]*ast.Program{}
+	for _, pattern := range []string{"../difftest/testdata/*.mky",
+		"testdata/*.mky"} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range paths {
+			src, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, path)
+			byName[path] = parse(t, string(src))
+		}
+	}
+	sort.Strings(names)
+	g := difftest.NewGenerator(*seed)
+	for n := 0; n < *programs; n++ {
+		program := g.Program()
+		name := ast.SExpr(program)
+		names = append(names, name)
+		byName[name] = program
+	}
+
+	ordered := make([]*ast.Program, len(names))
+	for n, name := range names {
+		ordered[n] = byName[name]
+	}
+	outputs := runTranslated(t, ordered)
+	for n, name := range names {
+		expected := evaluate(byName[name])
+		if outputs[n] != expected {
+			t.Errorf("%s: translation disagrees with the evaluator.\n"+
+				"expected=%q\ngot=%q", name, expected, outputs[n])
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+// evaluate returns what program writes when the evaluator evaluates it,
+// followed by its value or error trace.
+func evaluate(program *ast.Program) string {
+	var out bytes.Buffer
+	puts, _ := evaluator.LookupBuiltin("puts")
+	evaluator.RegisterBuiltin(&object.Builtin{
+		Name:   puts.Name,
+		Module: puts.Module,
+		Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprintln(&out, arg.Inspect())
+			}
+			return evaluator.NULL
+		},
+	})
+	defer evaluator.RegisterBuiltin(puts)
+
+	switch result := evaluator.Eval(program, object.NewEnvironment()).(type) {
+	case *object.Error:
+		out.WriteString(result.Trace(""))
+	case nil:
+		// A program ending with a let statement has no value.
+		out.WriteString("null\n")
+	default:
+		out.WriteString(result.Inspect() + "\n")
+	}
+	return out.String()
+}
+
+// separator precedes the output of each program run by runTranslated.
+const separator = "\n=== program ===\n"
+
+// runTranslated translates programs into a single Go program, which runs each
+// in turn, and runs it, returning the output of each program as evaluate does.
+func runTranslated(t *testing.T, programs []*ast.Program) []string {
+	t.Helper()
+	tr := newTranslator()
+	var funcs bytes.Buffer
+	for n, program := range programs {
+		fmt.Fprintf(&funcs, "func program%d() object.Object {\n%s}\n\n", n,
+			tr.program(program))
+	}
+	if tr.err != nil {
+		t.Fatal(tr.err)
+	}
+
+	var src bytes.Buffer
+	src.WriteString("package main\n\n")
+	src.WriteString(`import (
+	"fmt"
+
+	"github.com/adamvinueza/monkey/gogen/rt"
+	"github.com/adamvinueza/monkey/object"
+)
+
+`)
+	src.WriteString("func main() {\n\tfor _, program := range []func() object.Object{\n")
+	for n := range programs {
+		fmt.Fprintf(&src, "\t\tprogram%d,\n", n)
+	}
+	fmt.Fprintf(&src, `	} {
+		fmt.Print(%q)
+		result, err := rt.Run(program)
+		if err != nil {
+			fmt.Print(err.Trace(""))
+		} else {
+			fmt.Println(result.Inspect())
+		}
+	}
+}
+
+`, separator)
+	src.WriteString(tr.constants())
+	src.Write(funcs.Bytes())
+
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "gogen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gomod := fmt.Sprintf("module translated\n\ngo 1.13\n\n"+
+		"require github.com/adamvinueza/monkey v0.0.0\n\n"+
+		"replace github.com/adamvinueza/monkey => %s\n", root)
+	for name, data := range map[string][]byte{
+		"go.mod":  []byte(gomod),
+		"main.go": src.Bytes(),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data,
+			0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("running translated programs: %s\n%s", err, stderr.Bytes())
+	}
+	outputs := strings.Split(string(stdout), separator)[1:]
+	if len(outputs) != len(programs) {
+		t.Fatalf("translated programs wrote %d outputs, expected %d",
+			len(outputs), len(programs))
+	}
+	return outputs
+}
@@ -0,0 +1,160 @@
+package rt
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// MaxDepth is the maximum depth of function calls, the same as the
+// evaluator's default.
+const MaxDepth = evaluator.DefaultMaxDepth
+
+// Function is a Monkey function: the translation of a function literal,
+// closed over the variables of the functions enclosing it.
+//
+// Name is the name the function was first bound to by a let statement, or
+// empty if the function is anonymous.
+type Function struct {
+	Name  string
+	Arity int
+	// Source is the function's representation, which is that of the function
+	// literal it was translated from, as the evaluator shows it.
+	Source string
+	// Fn runs the body of the function with args, which hold a value for
+	// each parameter. It returns the function's value, or a call to be made
+	// in its place.
+	Fn func(args []object.Object) object.Object
+}
+
+// NewFunction returns a function taking arity arguments, whose body is fn.
+func NewFunction(source string, arity int,
+	fn func(args []object.Object) object.Object) *Function {
+	return &Function{Arity: arity, Source: source, Fn: fn}
+}
+
+func (f *Function) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (f *Function) Inspect() string         { return f.Source }
+
+// frame is an active function call.
+type frame struct {
+	fn   *Function
+	call token.Position // where the function was called
+}
+
+// frames holds the active function calls, outermost first.
+var frames []frame
+
+// tailCall is the value of a function whose body ends with a call, which Call
+// makes in place of the function's call.
+type tailCall struct {
+	fn   *Function
+	args []object.Object
+	call token.Position
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// Call calls fn with args, the call at pos, and returns its value.
+func Call(pos token.Position, fn object.Object,
+	args ...object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		result := fn.Fn(args...)
+		if err, ok := result.(*object.Error); ok {
+			if !err.Pos.IsValid() {
+				err.Pos = pos
+			}
+			panic(err)
+		}
+		if result == nil {
+			result = Null
+		}
+		return result
+	case *Function:
+		checkArity(pos, fn, args)
+		if len(frames) >= MaxDepth {
+			fail(pos, "maximum call depth (%d) exceeded", MaxDepth)
+		}
+		// Each tail call the body makes replaces the call being made.
+		frames = append(frames, frame{fn: fn, call: pos})
+		for {
+			result := fn.Fn(args)
+			tc, ok := result.(*tailCall)
+			if !ok {
+				frames = frames[:len(frames)-1]
+				return result
+			}
+			fn, args = tc.fn, tc.args
+			frames[len(frames)-1] = frame{fn: fn, call: tc.call}
+		}
+	default:
+		fail(pos, "not a function: %s", fn.Type())
+		return nil
+	}
+}
+
+// TailCall is like Call, but for a call in tail position, whose value is the
+// value of the function making it. The function must return the value of
+// TailCall. Calls that fail immediately, and calls of builtins, are made
+// directly.
+func TailCall(pos token.Position, fn object.Object,
+	args ...object.Object) object.Object {
+	f, ok := fn.(*Function)
+	if !ok || len(args) != f.Arity {
+		return Call(pos, fn, args...)
+	}
+	return &tailCall{fn: f, args: args, call: pos}
+}
+
+func checkArity(pos token.Position, fn *Function, args []object.Object) {
+	if len(args) != fn.Arity {
+		fail(pos, "wrong number of arguments: expected %d, found %d",
+			fn.Arity, len(args))
+	}
+}
+
+// Run runs program, the top level of a translated program, and returns its
+// value. If the program fails, Run returns the error, with the stack of the
+// function calls active when it failed.
+func Run(program func() object.Object) (result object.Object,
+	err *object.Error) {
+	frames = frames[:0]
+	defer func() {
+		r := recover()
+		switch r := r.(type) {
+		case nil:
+		case *object.Error:
+			for i := len(frames) - 1; i >= 0; i-- {
+				name := frames[i].fn.Name
+				if name == "" {
+					name = "fn"
+				}
+				r.Stack = append(r.Stack, object.Frame{
+					Function: name,
+					CallPos:  frames[i].call,
+				})
+			}
+			result, err = nil, r
+		default:
+			panic(r)
+		}
+		frames = frames[:0]
+	}()
+	return program(), nil
+}
+
+// Main runs program, which was translated from the Monkey program in
+// filename. If the program fails, Main writes the error and a trace of the
+// active function calls to standard error, as "monkey run" does, and exits
+// with status 1.
+func Main(filename string, program func() object.Object) {
+	if _, err := Run(program); err != nil {
+		fmt.Fprint(os.Stderr, err.Trace(filename))
+		os.Exit(1)
+	}
+}
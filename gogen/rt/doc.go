@@ -0,0 +1,19 @@
+// Package rt is the runtime of the Go programs package gogen translates Monkey
+// programs into. It provides the operations of the language on the values of
+// package object, so that a translated program computes the same values as
+// package evaluator, fails with the same errors, and calls the same builtins.
+//
+// A translated program's top level is a Go function, which it passes to Main:
+//  func main() {
+//      rt.Main("fib.mk", program)
+//  }
+//
+// Each Monkey function is a *Function, whose Go function is called by Call.
+// Calls in tail position are made by TailCall, which returns a request for the
+// call instead of making it, so that recursion in tail position doesn't grow
+// the Go stack. Operations that fail panic with an *object.Error, which Run
+// recovers, recording the stack of active calls as the evaluator does.
+//
+// Package rt is not meant to be used by hand, and its state is global, so only
+// one program may run at a time.
+package rt
@@ -0,0 +1,250 @@
+package rt
+
+import (
+	"fmt"
+
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// Translated programs share these values with the evaluator's builtins, so
+// that they can be compared by identity.
+var (
+	True  = evaluator.TRUE
+	False = evaluator.FALSE
+	Null  = evaluator.NULL
+)
+
+// fail stops the program with an error at pos.
+func fail(pos token.Position, format string, a ...interface{}) {
+	panic(&object.Error{Message: fmt.Sprintf(format, a...), Pos: pos})
+}
+
+// Pos returns the position at line and column of the program text.
+func Pos(line, column int) token.Position {
+	return token.Position{Line: line, Column: column}
+}
+
+// Int returns an integer.
+func Int(value int64) *object.Integer {
+	return &object.Integer{Value: value}
+}
+
+// Str returns a string.
+func Str(value string) *object.String {
+	return &object.String{Value: value}
+}
+
+// Bool returns True or False.
+func Bool(value bool) *object.Boolean {
+	if value {
+		return True
+	}
+	return False
+}
+
+// Truthy reports whether obj counts as true in a condition: everything does
+// but null and false.
+func Truthy(obj object.Object) bool {
+	return obj != Null && obj != False
+}
+
+// Get returns the value of the variable called name, which is the first of
+// values that isn't nil. The values are those of the variables called name in
+// the scopes enclosing the read, innermost first; a variable is nil until its
+// let statement runs. If all are nil, Get returns the builtin called name, and
+// fails at pos if there is none.
+func Get(pos token.Position, name string, values ...object.Object) object.Object {
+	for _, v := range values {
+		if v != nil {
+			return v
+		}
+	}
+	if builtin, ok := evaluator.LookupBuiltin(name); ok {
+		return builtin
+	}
+	fail(pos, "identifier not found: %s", name)
+	return nil
+}
+
+// Name returns obj, the value bound to name by a let statement. If obj is an
+// anonymous function, it takes name as its name.
+func Name(obj object.Object, name string) object.Object {
+	if fn, ok := obj.(*Function); ok && fn.Name == "" {
+		fn.Name = name
+	}
+	return obj
+}
+
+// Not returns the value of !right.
+func Not(right object.Object) object.Object {
+	return Bool(!Truthy(right))
+}
+
+// Neg returns the value of -right, the operation at pos.
+func Neg(pos token.Position, right object.Object) object.Object {
+	i, ok := right.(*object.Integer)
+	if !ok {
+		fail(pos, "unknown operator: -%s", right.Type())
+	}
+	return &object.Integer{Value: -i.Value}
+}
+
+// Add returns the value of left + right, the operation at pos.
+func Add(pos token.Position, left, right object.Object) object.Object {
+	if l, r, ok := integers(left, right); ok {
+		return &object.Integer{Value: l + r}
+	}
+	return infix(pos, "+", left, right)
+}
+
+// Sub returns the value of left - right, the operation at pos.
+func Sub(pos token.Position, left, right object.Object) object.Object {
+	if l, r, ok := integers(left, right); ok {
+		return &object.Integer{Value: l - r}
+	}
+	return infix(pos, "-", left, right)
+}
+
+// Mul returns the value of left * right, the operation at pos.
+func Mul(pos token.Position, left, right object.Object) object.Object {
+	if l, r, ok := integers(left, right); ok {
+		return &object.Integer{Value: l * r}
+	}
+	return infix(pos, "*", left, right)
+}
+
+// Div returns the value of left / right, the operation at pos.
+func Div(pos token.Position, left, right object.Object) object.Object {
+	if l, r, ok := integers(left, right); ok && r != 0 {
+		return &object.Integer{Value: l / r}
+	}
+	return infix(pos, "/", left, right)
+}
+
+// Lt returns the value of left < right, the operation at pos.
+func Lt(pos token.Position, left, right object.Object) object.Object {
+	if l, r, ok := integers(left, right); ok {
+		return Bool(l < r)
+	}
+	return infix(pos, "<", left, right)
+}
+
+// Gt returns the value of left > right, the operation at pos.
+func Gt(pos token.Position, left, right object.Object) object.Object {
+	if l, r, ok := integers(left, right); ok {
+		return Bool(l > r)
+	}
+	return infix(pos, ">", left, right)
+}
+
+// Eq returns the value of left == right, the operation at pos.
+func Eq(pos token.Position, left, right object.Object) object.Object {
+	if l, r, ok := integers(left, right); ok {
+		return Bool(l == r)
+	}
+	return infix(pos, "==", left, right)
+}
+
+// NotEq returns the value of left != right, the operation at pos.
+func NotEq(pos token.Position, left, right object.Object) object.Object {
+	if l, r, ok := integers(left, right); ok {
+		return Bool(l != r)
+	}
+	return infix(pos, "!=", left, right)
+}
+
+// integers returns the values of left and right, if both are integers.
+func integers(left, right object.Object) (l, r int64, ok bool) {
+	li, ok := left.(*object.Integer)
+	if !ok {
+		return 0, 0, false
+	}
+	ri, ok := right.(*object.Integer)
+	if !ok {
+		return 0, 0, false
+	}
+	return li.Value, ri.Value, true
+}
+
+// infix applies operator to operands that aren't both integers, or to integers
+// it can't be applied to, failing as the evaluator does.
+func infix(pos token.Position, operator string,
+	left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		if operator == "/" {
+			fail(pos, "division by zero: %d / %d",
+				left.(*object.Integer).Value, right.(*object.Integer).Value)
+		}
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		l := left.(*object.String).Value
+		r := right.(*object.String).Value
+		switch operator {
+		case "+":
+			return &object.String{Value: l + r}
+		case "==":
+			return Bool(l == r)
+		case "!=":
+			return Bool(l != r)
+		}
+	case left.Type() != right.Type():
+		fail(pos, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case operator == "==":
+		return Bool(left == right)
+	case operator == "!=":
+		return Bool(left != right)
+	}
+	fail(pos, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	return nil
+}
+
+// Array returns an array of elements.
+func Array(elements ...object.Object) object.Object {
+	return &object.Array{Elements: elements}
+}
+
+// HashKey fails at pos, the position of a hash literal, if key can't be a key
+// of the hash.
+func HashKey(pos token.Position, key object.Object) {
+	if _, ok := key.(object.Hashable); !ok {
+		fail(pos, "unusable as hash key: %s", key.Type())
+	}
+}
+
+// Hash returns a hash of pairs, given as keys alternating with values, which
+// HashKey has checked. Later pairs replace earlier ones with the same key.
+func Hash(pairs ...object.Object) object.Object {
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key := pairs[i].(object.Hashable).HashKey()
+		hash.Pairs[key] = object.HashPair{Key: pairs[i], Value: pairs[i+1]}
+	}
+	return hash
+}
+
+// Index returns the value of left[index], the operation at pos: the element or
+// value at index, or null if there is none.
+func Index(pos token.Position, left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		if i, ok := index.(*object.Integer); ok {
+			if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+				return Null
+			}
+			return left.Elements[i.Value]
+		}
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			fail(pos, "unusable as hash key: %s", index.Type())
+		}
+		if pair, ok := left.Pairs[key.HashKey()]; ok {
+			return pair.Value
+		}
+		return Null
+	}
+	fail(pos, "index operator not supported: %s[%s]", left.Type(), index.Type())
+	return nil
+}
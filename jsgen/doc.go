@@ -0,0 +1,25 @@
+// Package jsgen translates Monkey programs into JavaScript programs, which run
+// in a browser or under Node.js. A translated program writes the same output
+// as the evaluator, and fails with the same errors and stack traces:
+//  src, err := jsgen.Program(program, "rules.mk")
+//  if err != nil {
+//      log.Fatal(err)
+//  }
+//  err = ioutil.WriteFile("rules.js", src, 0644)
+//
+// A translated program is ES2015 code that holds its own runtime, a small
+// library providing Monkey's operations: integer arithmetic, truthiness,
+// hashes, builtins and error values. As JavaScript numbers are exact only up
+// to 2^53, integers are pairs of 32-bit halves, on which the runtime does
+// 64-bit arithmetic, wrapping around as Monkey's does. The builtin puts writes
+// with console.log, and neither readFile nor the assertions of the "test"
+// module are provided.
+//
+// Each Monkey function becomes a JavaScript generator function, and each
+// Monkey variable a JavaScript variable of the function binding it, as in
+// package gogen. Rather than calling functions, a function yields its calls to
+// the runtime, which keeps the active calls on a stack of its own, so that
+// they nest as deeply as in the evaluator however small the JavaScript stack
+// is. Calls in tail position replace the call making them, so loops written as
+// tail recursion run in constant space.
+package jsgen
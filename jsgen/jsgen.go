@@ -0,0 +1,492 @@
+package jsgen
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/object"
+)
+
+// Program returns the source text of a JavaScript program that runs program,
+// which was read from filename. The program's errors are reported with
+// filename, as by "monkey run". Program returns an error if program holds a
+// node that can't be translated, as a program produced by a parser without
+// errors doesn't.
+func Program(program *ast.Program, filename string) ([]byte, error) {
+	body, err := translate(program, "program")
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by monkey build from %s. DO NOT EDIT.\n",
+		filename)
+	out.WriteString("(function () {\n\"use strict\";\n\n")
+	out.WriteString(runtime)
+	out.WriteString("\n")
+	out.WriteString(body)
+	fmt.Fprintf(&out, "\nrt.main(%s, program);\n})();\n", quote(filename))
+	return indent(out.Bytes()), nil
+}
+
+// translate returns the declaration of the JavaScript generator function called
+// name, which runs the top level of program and returns its value, yielding
+// the calls it makes to the runtime.
+func translate(program *ast.Program, name string) (string, error) {
+	t := &translator{}
+	s := newScope(0)
+	declare(s, &ast.BlockStatement{Statements: program.Statements})
+	f := &function{t: t, scopes: []*scope{s}}
+	// The top level of the program makes no calls in tail position.
+	f.statements(program.Statements, dest{ret: true}, false)
+	if t.err != nil {
+		return "", t.err
+	}
+	return "function* " + name + "() {\n" + f.body() + "}\n", nil
+}
+
+// indent indents the lines of src by the depth of the braces enclosing them.
+// It relies on a line that opens a brace ending with it, and on a line that
+// closes one starting with it, as in the code Program writes.
+func indent(src []byte) []byte {
+	var out bytes.Buffer
+	depth := 0
+	for _, line := range strings.SplitAfter(string(src), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "}") && depth > 0 {
+			depth--
+		}
+		if trimmed != "" {
+			out.WriteString(strings.Repeat("  ", depth))
+		}
+		out.WriteString(strings.TrimLeft(line, " \t"))
+		if strings.HasSuffix(trimmed, "{") {
+			depth++
+		}
+	}
+	return out.Bytes()
+}
+
+// translator holds the state of a translation.
+type translator struct {
+	err error // the first error, which stops translation
+}
+
+// fail records that node can't be translated.
+func (t *translator) fail(node ast.Node) {
+	if t.err == nil {
+		t.err = fmt.Errorf("%s: can't translate %T", ast.Pos(node), node)
+	}
+}
+
+// scope holds the variables of a Monkey function: its parameters and those
+// bound by let statements in its body.
+type scope struct {
+	depth  int // the number of functions enclosing the function
+	names  []string
+	params map[string]bool
+	vars   map[string]bool
+}
+
+func newScope(depth int) *scope {
+	return &scope{depth: depth, params: map[string]bool{},
+		vars: map[string]bool{}}
+}
+
+func (s *scope) declare(name string) {
+	if !s.vars[name] {
+		s.vars[name] = true
+		s.names = append(s.names, name)
+	}
+}
+
+// variable returns the name of the JavaScript variable holding the variable
+// name. The prefix keeps it from being a reserved word.
+func (s *scope) variable(name string) string {
+	return fmt.Sprintf("v%d_%s", s.depth, name)
+}
+
+// declare declares the variables bound by let statements in node, other than
+// those in the bodies of function literals, which have scopes of their own.
+func declare(s *scope, node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		s.declare(node.Name.Value)
+		declare(s, node.Value)
+	case *ast.ExpressionStatement:
+		declare(s, node.Expression)
+	case *ast.ReturnStatement:
+		declare(s, node.ReturnValue)
+	case *ast.BlockStatement:
+		for _, statement := range node.Statements {
+			declare(s, statement)
+		}
+	case *ast.IfExpression:
+		declare(s, node.Condition)
+		declare(s, node.Consequence)
+		if node.Alternative != nil {
+			declare(s, node.Alternative)
+		}
+	case *ast.PrefixExpression:
+		declare(s, node.Right)
+	case *ast.InfixExpression:
+		declare(s, node.Left)
+		declare(s, node.Right)
+	case *ast.CallExpression:
+		declare(s, node.Function)
+		for _, a := range node.Arguments {
+			declare(s, a)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			declare(s, el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			declare(s, pair.Key)
+			declare(s, pair.Value)
+		}
+	case *ast.IndexExpression:
+		declare(s, node.Left)
+		declare(s, node.Index)
+	}
+}
+
+// function holds the translation of a Monkey function, or of the top level of
+// the program, into a JavaScript function.
+type function struct {
+	t      *translator
+	scopes []*scope // the scopes of the enclosing functions, innermost last
+	out    bytes.Buffer
+	// throwsReturns is set if a return statement within an expression is
+	// translated into a throw of its value, which the function must catch.
+	throwsReturns bool
+}
+
+// dest is what is done with the value of a statement: it is returned from the
+// function or discarded.
+type dest struct {
+	ret bool
+}
+
+// body returns the body of the JavaScript function: the declarations of the
+// variables, followed by the statements translated.
+func (f *function) body() string {
+	var out bytes.Buffer
+	s := f.scopes[len(f.scopes)-1]
+	vars := []string{}
+	for _, name := range s.names {
+		if !s.params[name] {
+			vars = append(vars, s.variable(name))
+		}
+	}
+	if len(vars) > 0 {
+		fmt.Fprintf(&out, "let %s;\n", strings.Join(vars, ", "))
+	}
+	if f.throwsReturns {
+		out.WriteString("try {\n")
+		out.Write(f.out.Bytes())
+		out.WriteString("} catch (e) {\n")
+		out.WriteString("if (e instanceof rt.Return) {\nreturn e.value;\n}\n")
+		out.WriteString("throw e;\n}\n")
+	} else {
+		out.Write(f.out.Bytes())
+	}
+	return out.String()
+}
+
+func (f *function) emit(format string, a ...interface{}) {
+	fmt.Fprintf(&f.out, format+"\n", a...)
+}
+
+// statements translates a sequence of statements, whose value, that of the
+// last, goes to dest. If tail is true, the statements are in the body of a
+// function, where return statements make calls in tail position.
+func (f *function) statements(stmts []ast.Statement, to dest, tail bool) {
+	for n, stmt := range stmts {
+		last := n == len(stmts)-1
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			f.emit("%s;", f.let(stmt))
+			if last {
+				f.deliver("null", to)
+			}
+		case *ast.ReturnStatement:
+			// The statements after a return statement can't be reached.
+			f.value(stmt.ReturnValue, dest{ret: true}, tail)
+			return
+		case *ast.ExpressionStatement:
+			if last {
+				f.value(stmt.Expression, to, tail)
+			} else {
+				f.value(stmt.Expression, dest{}, tail)
+			}
+		default:
+			f.t.fail(stmt)
+		}
+	}
+	if len(stmts) == 0 {
+		f.deliver("null", to)
+	}
+}
+
+// let returns an expression assigning the value of stmt to its variable.
+func (f *function) let(stmt *ast.LetStatement) string {
+	s := f.scopes[len(f.scopes)-1]
+	return fmt.Sprintf("%s = rt.name(%s, %s)", s.variable(stmt.Name.Value),
+		f.expression(stmt.Value), quote(stmt.Name.Value))
+}
+
+// value translates expr, sending its value to dest. If tail is true, as for
+// statements, a call whose value is returned is made in tail position.
+func (f *function) value(expr ast.Expression, to dest, tail bool) {
+	switch expr := expr.(type) {
+	case *ast.IfExpression:
+		f.emit("if (rt.truthy(%s)) {", f.expression(expr.Condition))
+		f.statements(expr.Consequence.Statements, to, tail)
+		if expr.Alternative != nil {
+			f.emit("} else {")
+			f.statements(expr.Alternative.Statements, to, tail)
+		} else if to.ret {
+			f.emit("} else {")
+			f.deliver("null", to)
+		}
+		f.emit("}")
+		return
+	case *ast.CallExpression:
+		if to.ret && tail {
+			f.emit("return %s;", f.call(expr, "rt.tail"))
+			return
+		}
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		if !to.ret {
+			// The value is discarded, and computing it has no effect.
+			return
+		}
+	}
+	f.deliver(f.expression(expr), to)
+}
+
+// deliver sends the value of code to dest.
+func (f *function) deliver(code string, to dest) {
+	if to.ret {
+		f.emit("return %s;", code)
+	} else if code != "null" {
+		f.emit("%s;", code)
+	}
+}
+
+// expression returns a JavaScript expression computing the value of expr.
+// JavaScript evaluates the operands of operators and the arguments of calls
+// from left to right, as Monkey does.
+func (f *function) expression(expr ast.Expression) string {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		if v := expr.Value; v > -1<<53 && v < 1<<53 {
+			return fmt.Sprintf("rt.int(%d)", v)
+		}
+		// Larger integers aren't exact as JavaScript numbers, so they are
+		// given by their halves.
+		return fmt.Sprintf("rt.int(%d, %d)", int32(expr.Value>>32),
+			uint32(expr.Value))
+	case *ast.StringLiteral:
+		return quote(expr.Value)
+	case *ast.Boolean:
+		return strconv.FormatBool(expr.Value)
+	case *ast.Identifier:
+		return f.identifier(expr)
+	case *ast.PrefixExpression:
+		right := f.expression(expr.Right)
+		switch expr.Operator {
+		case "!":
+			return fmt.Sprintf("rt.not(%s)", right)
+		case "-":
+			return fmt.Sprintf("rt.neg(%s, %s)", pos(expr), right)
+		}
+	case *ast.InfixExpression:
+		left := f.expression(expr.Left)
+		right := f.expression(expr.Right)
+		if op, ok := infixOperators[expr.Operator]; ok {
+			return fmt.Sprintf("rt.%s(%s, %s, %s)", op, pos(expr), left, right)
+		}
+	case *ast.IfExpression:
+		alternative := "null"
+		if expr.Alternative != nil {
+			alternative = f.branch(expr.Alternative)
+		}
+		return fmt.Sprintf("(rt.truthy(%s) ? %s : %s)",
+			f.expression(expr.Condition), f.branch(expr.Consequence),
+			alternative)
+	case *ast.FunctionLiteral:
+		return f.functionLiteral(expr)
+	case *ast.CallExpression:
+		// The runtime makes the call, sending its value back.
+		return "(yield " + f.call(expr, "rt.call") + ")"
+	case *ast.ArrayLiteral:
+		return "[" + strings.Join(f.expressions(expr.Elements), ", ") + "]"
+	case *ast.HashLiteral:
+		pairs := []string{}
+		for _, pair := range expr.Pairs {
+			// The key is checked before the value is evaluated.
+			pairs = append(pairs, fmt.Sprintf("rt.key(%s, %s)", pos(expr),
+				f.expression(pair.Key)), f.expression(pair.Value))
+		}
+		return fmt.Sprintf("rt.hash(%s)", strings.Join(pairs, ", "))
+	case *ast.IndexExpression:
+		left := f.expression(expr.Left)
+		index := f.expression(expr.Index)
+		return fmt.Sprintf("rt.index(%s, %s, %s)", pos(expr), left, index)
+	}
+	f.t.fail(expr)
+	return "null"
+}
+
+func (f *function) expressions(exprs []ast.Expression) []string {
+	codes := []string{}
+	for _, e := range exprs {
+		codes = append(codes, f.expression(e))
+	}
+	return codes
+}
+
+// infixOperators maps each infix operator to the function of the runtime
+// applying it.
+var infixOperators = map[string]string{
+	"+":  "add",
+	"-":  "sub",
+	"*":  "mul",
+	"/":  "div",
+	"<":  "lt",
+	">":  "gt",
+	"==": "eq",
+	"!=": "notEq",
+}
+
+// branch returns an expression evaluating the statements of a branch of an if
+// expression, whose value is that of the last statement. A return statement
+// throws its value, to be caught by the enclosing function.
+func (f *function) branch(block *ast.BlockStatement) string {
+	parts := []string{}
+	value := "null"
+	for _, stmt := range block.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			parts = append(parts, f.let(stmt))
+			value = "null"
+		case *ast.ReturnStatement:
+			f.throwsReturns = true
+			parts = append(parts, fmt.Sprintf("rt.ret(%s)",
+				f.expression(stmt.ReturnValue)))
+			return "(" + strings.Join(parts, ", ") + ")"
+		case *ast.ExpressionStatement:
+			value = f.expression(stmt.Expression)
+			parts = append(parts, value)
+		default:
+			f.t.fail(stmt)
+		}
+	}
+	if len(parts) == 0 || parts[len(parts)-1] != value {
+		parts = append(parts, value)
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// identifier returns an expression reading the variable ident refers to: the
+// innermost of the variables of the enclosing functions called ident that has
+// been assigned, or else the builtin called ident.
+func (f *function) identifier(ident *ast.Identifier) string {
+	name := ident.Value
+	values := []string{}
+	for i := len(f.scopes) - 1; i >= 0; i-- {
+		s := f.scopes[i]
+		if !s.vars[name] {
+			continue
+		}
+		values = append(values, s.variable(name))
+		if s.params[name] {
+			// A parameter is always assigned, so it is read directly if
+			// it's the only candidate.
+			if len(values) == 1 {
+				return values[0]
+			}
+			break
+		}
+	}
+	args := append([]string{pos(ident), quote(name)}, values...)
+	return fmt.Sprintf("rt.get(%s)", strings.Join(args, ", "))
+}
+
+// call returns an expression calling the function of expr with its arguments,
+// using the function of the runtime called name.
+func (f *function) call(expr *ast.CallExpression, name string) string {
+	args := append([]string{pos(expr), f.expression(expr.Function)},
+		f.expressions(expr.Arguments)...)
+	return fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+}
+
+// functionLiteral returns an expression creating the function of expr, whose
+// body is translated into a JavaScript generator function.
+func (f *function) functionLiteral(expr *ast.FunctionLiteral) string {
+	s := newScope(len(f.scopes))
+	params := make([]string, len(expr.Parameters))
+	for n := len(expr.Parameters) - 1; n >= 0; n-- {
+		// Of parameters with the same name, the last is assigned to the
+		// variable, and the others are ignored.
+		p := expr.Parameters[n].Value
+		if s.params[p] {
+			params[n] = fmt.Sprintf("$%d", n)
+			continue
+		}
+		params[n] = s.variable(p)
+		s.declare(p)
+		s.params[p] = true
+	}
+	declare(s, expr.Body)
+	scopes := append(f.scopes[:len(f.scopes):len(f.scopes)], s)
+	inner := &function{t: f.t, scopes: scopes}
+	inner.statements(expr.Body.Statements, dest{ret: true}, true)
+
+	src := (&object.Function{Parameters: expr.Parameters, Body: expr.Body}).Inspect()
+	return fmt.Sprintf("rt.fn(%s, %d, function* (%s) {\n%s})", quote(src),
+		len(expr.Parameters), strings.Join(params, ", "), inner.body())
+}
+
+// pos returns an expression for the position of node.
+func pos(node ast.Node) string {
+	p := ast.Pos(node)
+	return fmt.Sprintf("[%d, %d]", p.Line, p.Column)
+}
+
+// quote returns a JavaScript string literal for s. Bytes of s that aren't
+// valid UTF-8 become the replacement character.
+func quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == '"' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\r':
+			out.WriteString(`\r`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r < ' ' || r == 0x7f || r == 0x2028 || r == 0x2029:
+			fmt.Fprintf(&out, `\u%04x`, r)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
@@ -0,0 +1,282 @@
+package jsgen
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/ast/build"
+	"github.com/adamvinueza/monkey/difftest"
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+var (
+	update   = flag.Bool("update", false, "update the golden files in testdata")
+	seed     = flag.Int64("seed", 1, "seed for random programs")
+	programs = flag.Int("programs", 500, "number of random programs to run")
+)
+
+func TestProgram(t *testing.T) {
+	src, err := Program(parse(t, "let x = 1; puts(x + 2);"), "x.mk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"// Code generated by monkey build from x.mk. DO NOT EDIT.\n",
+		"\n  const rt = (function () {\n",
+		"\n  rt.main(\"x.mk\", program);\n",
+		"\n  function* program() {\n",
+		"\n    v0_x = rt.name(rt.int(1), \"x\");\n",
+		"return (yield rt.call([1, 12], rt.get([1, 12], \"puts\"), ",
+		"rt.add([1, 17], rt.get([1, 17], \"x\", v0_x), rt.int(2))",
+	} {
+		if !strings.Contains(string(src), expected) {
+			t.Errorf("translation doesn't contain %q:\n%s", expected, src)
+		}
+	}
+}
+
+func TestProgramErrors(t *testing.T) {
+	tests := []struct {
+		program  *ast.Program
+		expected string
+	}{
+		{
+			build.Program(build.ExprStmt(&ast.PrefixExpression{
+				Operator: "~",
+				Right:    build.Int(1),
+			})),
+			"-: can't translate *ast.PrefixExpression",
+		},
+		{
+			build.Program(build.Block(build.ExprStmt(build.Int(1)))),
+			"-: can't translate *ast.BlockStatement",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := Program(tt.program, "x.mk")
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. expected=%q, got=%v",
+				ast.SExpr(tt.program), tt.expected, err)
+		}
+	}
+}
+
+// TestGolden checks the translations of the programs in testdata against the
+// golden files next to them, which hold the translations without the runtime.
+// Run with -update to rewrite the golden files.
+func TestGolden(t *testing.T) {
+	paths, err := filepath.Glob("testdata/*.mky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := translate(parse(t, string(src)), "program")
+		if err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		got := indent([]byte(body))
+		golden := strings.TrimSuffix(path, ".mky") + ".js"
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Errorf("%s: translation differs from %s.\nexpected:\n%s\ngot:\n%s",
+				path, golden, expected, got)
+		}
+	}
+}
+
+// TestCorpus checks that translated programs write the same output as the
+// evaluator, and have the same values or fail with the same errors. The
+// programs are those of testdata and of the corpora of packages difftest and
+// gogen, along with random programs.
+func TestCorpus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping running translated programs in short mode")
+	}
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node command not found")
+	}
+
+	names := []string{}
+	byName := map[string]*ast.Program{}
+	for _, pattern := range []string{"../difftest/testdata/*.mky",
+		"../gogen/testdata/*.mky", "testdata/*.mky"} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range paths {
+			src, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, path)
+			byName[path] = parse(t, string(src))
+		}
+	}
+	sort.Strings(names)
+	g := difftest.NewGenerator(*seed)
+	for n := 0; n < *programs; n++ {
+		program := g.Program()
+		name := ast.SExpr(program)
+		names = append(names, name)
+		byName[name] = program
+	}
+
+	ordered := make([]*ast.Program, len(names))
+	for n, name := range names {
+		ordered[n] = byName[name]
+	}
+	outputs := runTranslated(t, ordered)
+	for n, name := range names {
+		expected := evaluate(byName[name])
+		if outputs[n] != expected {
+			t.Errorf("%s: translation disagrees with the evaluator.\n"+
+				"expected=%q\ngot=%q", name, expected, outputs[n])
+		}
+	}
+}
+
+// TestDepthLimit checks that the calls of a translated program can nest as
+// deeply as the evaluator's, and fail as they do beyond that.
+func TestDepthLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping running translated programs in short mode")
+	}
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node command not found")
+	}
+
+	sum := "let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } };\n"
+	programs := []*ast.Program{
+		parse(t, sum+"sum(9999)"),
+		parse(t, sum+"sum(10000)"),
+	}
+	outputs := runTranslated(t, programs)
+	for n, program := range programs {
+		if expected := evaluate(program); outputs[n] != expected {
+			t.Errorf("%s: translation disagrees with the evaluator.\n"+
+				"expected=%q\ngot=%q", program, expected, outputs[n])
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+// evaluate returns what program writes when the evaluator evaluates it,
+// followed by its value or error trace.
+func evaluate(program *ast.Program) string {
+	var out bytes.Buffer
+	puts, _ := evaluator.LookupBuiltin("puts")
+	evaluator.RegisterBuiltin(&object.Builtin{
+		Name:   puts.Name,
+		Module: puts.Module,
+		Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprintln(&out, arg.Inspect())
+			}
+			return evaluator.NULL
+		},
+	})
+	defer evaluator.RegisterBuiltin(puts)
+
+	switch result := evaluator.Eval(program, object.NewEnvironment()).(type) {
+	case *object.Error:
+		out.WriteString(result.Trace(""))
+	case nil:
+		// A program ending with a let statement has no value.
+		out.WriteString("null\n")
+	default:
+		out.WriteString(result.Inspect() + "\n")
+	}
+	return out.String()
+}
+
+// separator precedes the output of each program run by runTranslated.
+const separator = "\n=== program ===\n"
+
+// runTranslated translates programs into a single JavaScript program, which
+// runs each in turn, and runs it with node, returning the output of each
+// program as evaluate does.
+func runTranslated(t *testing.T, programs []*ast.Program) []string {
+	t.Helper()
+	var src bytes.Buffer
+	src.WriteString("\"use strict\";\n\n")
+	src.WriteString(runtime)
+	src.WriteString("\nconst programs = [];\n\n")
+	for n, program := range programs {
+		name := fmt.Sprintf("program%d", n)
+		body, err := translate(program, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&src, "%s\nprograms.push(%s);\n\n", body, name)
+	}
+	fmt.Fprintf(&src, `for (const program of programs) {
+process.stdout.write(%s);
+const result = rt.run(program);
+if (result.error !== undefined) {
+process.stdout.write(rt.trace(result.error, ""));
+} else {
+console.log(rt.inspect(result.value));
+}
+}
+`, quote(separator))
+
+	dir, err := ioutil.TempDir("", "jsgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "programs.js")
+	if err := ioutil.WriteFile(path, indent(src.Bytes()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("node", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("running translated programs: %s\n%s", err, stderr.Bytes())
+	}
+	outputs := strings.Split(string(stdout), separator)[1:]
+	if len(outputs) != len(programs) {
+		t.Fatalf("translated programs wrote %d outputs, expected %d",
+			len(outputs), len(programs))
+	}
+	return outputs
+}
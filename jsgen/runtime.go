@@ -0,0 +1,592 @@
+package jsgen
+
+// runtime is the source text of the runtime of translated programs, which
+// defines rt, the object holding Monkey's operations on JavaScript values:
+// strings are strings, booleans booleans, null null and arrays arrays, while
+// integers, hashes, functions and builtins are objects of classes of their
+// own. An integer holds the high and low 32 bits of a 64-bit two's complement
+// integer, for JavaScript numbers are exact only up to 2^53, and the runtime
+// does arithmetic on the halves, so that it wraps around as Monkey's does.
+const runtime = `const rt = (function () {
+const maxDepth = 10000;
+const traceFrames = 10;
+
+class MonkeyError {
+constructor(message, pos) {
+this.message = message;
+this.pos = pos;
+this.stack = [];
+}
+}
+
+class Int {
+constructor(hi, lo) {
+this.hi = hi; // signed
+this.lo = lo; // unsigned
+}
+
+toString() {
+return decimal(this);
+}
+}
+
+class Fn {
+constructor(source, arity, body) {
+this.name = "";
+this.source = source;
+this.arity = arity;
+this.body = body;
+}
+}
+
+class Builtin {
+constructor(name, fn) {
+this.name = name;
+this.fn = fn;
+}
+}
+
+class Hash {
+constructor() {
+this.pairs = new Map();
+}
+}
+
+// A Call is yielded by the body of a function to call fn, and a TailCall
+// returned by it to be replaced by a call of fn.
+class Call {
+constructor(pos, fn, args) {
+this.pos = pos;
+this.fn = fn;
+this.args = args;
+}
+}
+
+class TailCall {
+constructor(pos, fn, args) {
+this.pos = pos;
+this.fn = fn;
+this.args = args;
+}
+}
+
+class Return {
+constructor(value) {
+this.value = value;
+}
+}
+
+let frames = [];
+
+const two32 = 4294967296;
+
+function int(n, lo) {
+if (lo !== undefined) {
+return new Int(n, lo);
+}
+// n is a number, exactly an integer.
+return new Int(Math.floor(n / two32) | 0, n >>> 0);
+}
+
+function small(a) {
+return a.hi === (a.lo | 0) >> 31;
+}
+
+function safe(a) {
+return a.hi >= -0x200000 && a.hi < 0x200000;
+}
+
+function number(a) {
+return a.hi * two32 + a.lo;
+}
+
+function add(a, b) {
+const lo = a.lo + b.lo;
+return new Int((a.hi + b.hi + (lo >= two32 ? 1 : 0)) | 0, lo >>> 0);
+}
+
+function negate(a) {
+const lo = (~a.lo + 1) >>> 0;
+return new Int((~a.hi + (lo === 0 ? 1 : 0)) | 0, lo);
+}
+
+function mul(a, b) {
+// The product of the low halves is made of products of 16-bit parts, which
+// numbers hold exactly. Of the products with the high halves, only their
+// low 32 bits count.
+const a0 = a.lo & 0xffff, a1 = a.lo >>> 16;
+const b0 = b.lo & 0xffff, b1 = b.lo >>> 16;
+const mid = a1 * b0 + a0 * b1;
+const lo = a0 * b0 + (mid % 65536) * 65536;
+const hi = a1 * b1 + Math.floor(mid / 65536) + Math.floor(lo / two32) +
+Math.imul(a.hi, b.lo) + Math.imul(a.lo, b.hi);
+return new Int(hi | 0, lo >>> 0);
+}
+
+// udivmod divides n by d, unsigned 64-bit integers given by their unsigned
+// halves, returning the halves of the quotient and remainder.
+function udivmod(nh, nl, dh, dl) {
+let qh = 0, ql = 0, rh = 0, rl = 0;
+for (let i = 63; i >= 0; i--) {
+const over = rh >>> 31;
+const bit = i >= 32 ? (nh >>> (i - 32)) & 1 : (nl >>> i) & 1;
+rh = ((rh << 1) | (rl >>> 31)) >>> 0;
+rl = ((rl << 1) | bit) >>> 0;
+if (over === 1 || rh > dh || (rh === dh && rl >= dl)) {
+const borrow = rl < dl ? 1 : 0;
+rl = (rl - dl) >>> 0;
+rh = (rh - dh - borrow) >>> 0;
+if (i >= 32) {
+qh = (qh | (1 << (i - 32))) >>> 0;
+} else {
+ql = (ql | (1 << i)) >>> 0;
+}
+}
+}
+return {qh, ql, rh, rl};
+}
+
+function div(a, b) {
+if (small(a) && small(b)) {
+return int(Math.trunc(number(a) / number(b)));
+}
+// The quotient of the magnitudes, as unsigned integers, which the
+// magnitude of -2^63 is.
+const na = a.hi < 0, nb = b.hi < 0;
+const ua = na ? negate(a) : a, ub = nb ? negate(b) : b;
+const r = udivmod(ua.hi >>> 0, ua.lo, ub.hi >>> 0, ub.lo);
+const q = new Int(r.qh | 0, r.ql);
+return na !== nb ? negate(q) : q;
+}
+
+function less(a, b) {
+return a.hi < b.hi || (a.hi === b.hi && a.lo < b.lo);
+}
+
+function same(a, b) {
+return a.hi === b.hi && a.lo === b.lo;
+}
+
+function decimal(a) {
+if (safe(a)) {
+return String(number(a));
+}
+const neg = a.hi < 0;
+const u = neg ? negate(a) : a;
+let h = u.hi >>> 0, l = u.lo, out = "";
+while (h !== 0 || l >= 1e9) {
+const r = udivmod(h, l, 0, 1e9);
+out = ("00000000" + r.rl).slice(-9) + out;
+h = r.qh;
+l = r.ql;
+}
+return (neg ? "-" : "") + l + out;
+}
+
+function fail(pos, message) {
+throw new MonkeyError(message, pos);
+}
+
+function type(v) {
+if (v instanceof Int) {
+return "INTEGER";
+}
+switch (typeof v) {
+case "string":
+return "STRING";
+case "boolean":
+return "BOOLEAN";
+}
+if (v === null) {
+return "NULL";
+}
+if (Array.isArray(v)) {
+return "ARRAY";
+}
+if (v instanceof Hash) {
+return "HASH";
+}
+if (v instanceof Fn) {
+return "FUNCTION";
+}
+return "BUILTIN";
+}
+
+function truthy(v) {
+return v !== null && v !== false;
+}
+
+function quote(s) {
+const escapes = {"\"": "\\\"", "\\": "\\\\", "\x07": "\\a", "\b": "\\b",
+"\f": "\\f", "\n": "\\n", "\r": "\\r", "\t": "\\t", "\v": "\\v"};
+let out = "\"";
+for (const c of s) {
+if (escapes[c] !== undefined) {
+out += escapes[c];
+} else if (c < " " || c === "\x7f") {
+out += "\\x" + ("0" + c.charCodeAt(0).toString(16)).slice(-2);
+} else {
+out += c;
+}
+}
+return out + "\"";
+}
+
+function sortedPairs(hash) {
+const pairs = Array.from(hash.pairs.values());
+return pairs.sort(function (a, b) {
+const ta = type(a.key), tb = type(b.key);
+if (ta !== tb) {
+return ta < tb ? -1 : 1;
+}
+if (ta === "INTEGER") {
+return less(a.key, b.key) ? -1 : less(b.key, a.key) ? 1 : 0;
+}
+return a.key < b.key ? -1 : a.key > b.key ? 1 : 0;
+});
+}
+
+function inspect(v) {
+switch (type(v)) {
+case "NULL":
+return "null";
+case "ARRAY":
+return "[" + v.map(inspect).join(", ") + "]";
+case "HASH":
+return "{" + sortedPairs(v).map(function (pair) {
+const key = typeof pair.key === "string" ? quote(pair.key) :
+inspect(pair.key);
+return key + ": " + inspect(pair.value);
+}).join(", ") + "}";
+case "FUNCTION":
+return v.source;
+case "BUILTIN":
+return "builtin function " + v.name;
+}
+return String(v);
+}
+
+function name(v, n) {
+if (v instanceof Fn && v.name === "") {
+v.name = n;
+}
+return v;
+}
+
+function fn(source, arity, body) {
+return new Fn(source, arity, body);
+}
+
+function get(pos, n, ...values) {
+for (const v of values) {
+if (v !== undefined) {
+return v;
+}
+}
+if (builtins.has(n)) {
+return builtins.get(n);
+}
+fail(pos, "identifier not found: " + n);
+}
+
+function not(right) {
+return !truthy(right);
+}
+
+function neg(pos, right) {
+if (!(right instanceof Int)) {
+fail(pos, "unknown operator: -" + type(right));
+}
+return negate(right);
+}
+
+function infix(pos, op, left, right) {
+const tl = type(left), tr = type(right);
+if (tl === "INTEGER" && tr === "INTEGER") {
+switch (op) {
+case "+":
+return add(left, right);
+case "-":
+return add(left, negate(right));
+case "*":
+return mul(left, right);
+case "/":
+if (right.hi === 0 && right.lo === 0) {
+fail(pos, "division by zero: " + left + " / " + right);
+}
+return div(left, right);
+case "<":
+return less(left, right);
+case ">":
+return less(right, left);
+case "==":
+return same(left, right);
+case "!=":
+return !same(left, right);
+}
+}
+if (tl === "STRING" && tr === "STRING" && op === "+") {
+return left + right;
+}
+if (tl !== tr) {
+fail(pos, "type mismatch: " + tl + " " + op + " " + tr);
+}
+if (op === "==") {
+return left === right;
+}
+if (op === "!=") {
+return left !== right;
+}
+fail(pos, "unknown operator: " + tl + " " + op + " " + tr);
+}
+
+function operator(op) {
+return function (pos, left, right) {
+return infix(pos, op, left, right);
+};
+}
+
+function hashKey(key) {
+return type(key) + ":" + key;
+}
+
+function key(pos, k) {
+if (k instanceof Int) {
+return k;
+}
+switch (typeof k) {
+case "string":
+case "boolean":
+return k;
+}
+fail(pos, "unusable as hash key: " + type(k));
+}
+
+function hash(...pairs) {
+const h = new Hash();
+for (let i = 0; i + 1 < pairs.length; i += 2) {
+h.pairs.set(hashKey(pairs[i]), {key: pairs[i], value: pairs[i + 1]});
+}
+return h;
+}
+
+function index(pos, left, i) {
+if (Array.isArray(left) && i instanceof Int) {
+return i.hi === 0 && i.lo < left.length ? left[i.lo] : null;
+}
+if (left instanceof Hash) {
+const pair = left.pairs.get(hashKey(key(pos, i)));
+return pair === undefined ? null : pair.value;
+}
+fail(pos, "index operator not supported: " + type(left) + "[" + type(i) +
+"]");
+}
+
+function call(pos, f, ...args) {
+if (f instanceof Builtin) {
+const result = f.fn(args);
+if (result instanceof MonkeyError) {
+result.pos = pos;
+throw result;
+}
+return result;
+}
+if (!(f instanceof Fn)) {
+fail(pos, "not a function: " + type(f));
+}
+if (args.length !== f.arity) {
+fail(pos, "wrong number of arguments: expected " + f.arity + ", found " +
+args.length);
+}
+if (frames.length >= maxDepth) {
+fail(pos, "maximum call depth (" + maxDepth + ") exceeded");
+}
+return new Call(pos, f, args);
+}
+
+function tail(pos, f, ...args) {
+if (!(f instanceof Fn) || args.length !== f.arity) {
+return call(pos, f, ...args);
+}
+return new TailCall(pos, f, args);
+}
+
+function ret(value) {
+throw new Return(value);
+}
+
+function error(message) {
+return new MonkeyError(message);
+}
+
+function checkArgs(n, args, ...types) {
+if (args.length !== types.length) {
+return error("wrong number of arguments to \x60" + n + "\x60: expected " +
+types.length + ", found " + args.length);
+}
+for (let i = 0; i < types.length; i++) {
+if (types[i] !== "" && type(args[i]) !== types[i]) {
+return error("argument " + (i + 1) + " to \x60" + n + "\x60 must be " +
+types[i] + ", found " + type(args[i]));
+}
+}
+return null;
+}
+
+function utf8Length(s) {
+let n = 0;
+for (const c of s) {
+const code = c.codePointAt(0);
+n += code < 0x80 ? 1 : code < 0x800 ? 2 : code < 0x10000 ? 3 : 4;
+}
+return n;
+}
+
+const builtins = new Map([
+["len", function (args) {
+const err = checkArgs("len", args, "");
+if (err !== null) {
+return err;
+}
+if (Array.isArray(args[0])) {
+return int(args[0].length);
+}
+if (typeof args[0] === "string") {
+return int(utf8Length(args[0]));
+}
+return error("argument 1 to \x60len\x60 must be ARRAY or STRING, found " +
+type(args[0]));
+}],
+["first", function (args) {
+const err = checkArgs("first", args, "ARRAY");
+if (err !== null) {
+return err;
+}
+return args[0].length > 0 ? args[0][0] : null;
+}],
+["last", function (args) {
+const err = checkArgs("last", args, "ARRAY");
+if (err !== null) {
+return err;
+}
+return args[0].length > 0 ? args[0][args[0].length - 1] : null;
+}],
+["rest", function (args) {
+const err = checkArgs("rest", args, "ARRAY");
+if (err !== null) {
+return err;
+}
+return args[0].length > 0 ? args[0].slice(1) : null;
+}],
+["push", function (args) {
+const err = checkArgs("push", args, "ARRAY", "");
+if (err !== null) {
+return err;
+}
+return args[0].concat([args[1]]);
+}],
+["puts", function (args) {
+for (const arg of args) {
+console.log(inspect(arg));
+}
+return null;
+}],
+["now", function (args) {
+const err = checkArgs("now", args);
+if (err !== null) {
+return err;
+}
+return int(Date.now());
+}],
+].map(function (b) {
+return [b[0], new Builtin(b[0], b[1])];
+}));
+
+// run runs program, whose body, like those of functions, is a generator
+// yielding the values of the calls it makes, and returning its value. Rather
+// than nesting on the JavaScript stack, the calls are kept on a stack of their
+// own, so that they can reach the maximum depth.
+function run(program) {
+frames = [];
+const bodies = [program()];
+let value;
+try {
+for (;;) {
+const step = bodies[bodies.length - 1].next(value);
+value = step.value;
+if (!step.done) {
+// The call of a builtin has been made already.
+if (value instanceof Call) {
+frames.push({fn: value.fn, call: value.pos});
+bodies.push(value.fn.body(...value.args));
+value = undefined;
+}
+} else if (value instanceof TailCall) {
+frames[frames.length - 1] = {fn: value.fn, call: value.pos};
+bodies[bodies.length - 1] = value.fn.body(...value.args);
+value = undefined;
+} else {
+bodies.pop();
+if (bodies.length === 0) {
+return {value};
+}
+frames.pop();
+}
+}
+} catch (e) {
+if (!(e instanceof MonkeyError)) {
+throw e;
+}
+for (let i = frames.length - 1; i >= 0; i--) {
+e.stack.push({name: frames[i].fn.name || "fn", call: frames[i].call});
+}
+return {error: e};
+} finally {
+frames = [];
+}
+}
+
+function position(pos) {
+return pos !== undefined && pos[0] > 0 ? pos[0] + ":" + pos[1] : "-";
+}
+
+function trace(err, filename) {
+let out = "ERROR: " + err.message + "\n\nstack trace:\n";
+const frame = function (n, pos) {
+out += n + "\n\t" + (filename ? filename + ":" : "") + position(pos) + "\n";
+};
+// As in the evaluator, only the ends of a deep stack are shown.
+const n = err.stack.length;
+let pos = err.pos;
+err.stack.forEach(function (f, i) {
+if (i === traceFrames && n > 2 * traceFrames) {
+out += "..." + (n - 2 * traceFrames) + " calls elided...\n";
+}
+if (i < traceFrames || i >= n - traceFrames) {
+frame(f.name + "(...)", pos);
+}
+pos = f.call;
+});
+frame("main", pos);
+return out;
+}
+
+function main(filename, program) {
+const result = run(program);
+if (result.error !== undefined) {
+console.error(trace(result.error, filename).replace(/\n$/, ""));
+if (typeof process !== "undefined") {
+process.exitCode = 1;
+}
+}
+return result;
+}
+
+return {
+Return, add: operator("+"), call, div: operator("/"), eq: operator("=="),
+fn, get, gt: operator(">"), hash, index, inspect, int, key, lt: operator("<"),
+main, mul: operator("*"), name, neg, not, notEq: operator("!="), ret, run,
+sub: operator("-"), tail, trace, truthy,
+};
+})();
+`
@@ -0,0 +1,13 @@
+function* program() {
+  let v0_x;
+  v0_x = rt.name(rt.int(7), "x");
+  (yield rt.call([2, 1], rt.get([2, 1], "puts"), rt.div([2, 6], rt.get([2, 6], "x", v0_x), rt.int(2)), rt.div([2, 13], rt.neg([2, 13], rt.get([2, 14], "x", v0_x)), rt.int(2)), rt.sub([2, 21], rt.mul([2, 21], rt.get([2, 21], "x", v0_x), rt.int(3)), rt.int(1)), rt.div([2, 32], rt.int(10), rt.neg([2, 37], rt.int(3)))));
+  (yield rt.call([3, 1], rt.get([3, 1], "puts"), rt.lt([3, 6], rt.int(1), rt.int(2)), rt.gt([3, 13], rt.int(2), rt.int(3)), rt.eq([3, 20], rt.get([3, 20], "x", v0_x), rt.int(7)), rt.notEq([3, 28], rt.get([3, 28], "x", v0_x), rt.int(7)), rt.not(rt.get([3, 37], "x", v0_x)), rt.not(rt.not(false))));
+  (yield rt.call([4, 1], rt.get([4, 1], "puts"), rt.add([4, 6], "foo", "bar"), rt.eq([4, 21], "a", "a"), (yield rt.call([4, 33], rt.get([4, 33], "len"), "héllo"))));
+  if (rt.truthy(rt.gt([5, 5], rt.get([5, 5], "x", v0_x), rt.int(5)))) {
+    (yield rt.call([5, 14], rt.get([5, 14], "puts"), "big"));
+  } else {
+    (yield rt.call([5, 35], rt.get([5, 35], "puts"), "small"));
+  }
+  return rt.div([6, 1], rt.int(1), rt.int(0));
+}
@@ -0,0 +1,25 @@
+function* program() {
+  let v0_classify, v0_count, v0_empty, v0_y;
+  v0_classify = rt.name(rt.fn("fn(n) {\nif(n < 0) return negative;let even = if(n > 0) let half = (n / 2);((half * 2) == n)else true;ifeven evenelse odd\n}", 1, function* (v1_n) {
+    let v1_even, v1_half;
+    if (rt.truthy(rt.lt([2, 7], v1_n, rt.int(0)))) {
+      return "negative";
+    }
+    v1_even = rt.name((rt.truthy(rt.gt([3, 18], v1_n, rt.int(0))) ? (v1_half = rt.name(rt.div([3, 38], v1_n, rt.int(2)), "half"), rt.eq([3, 45], rt.mul([3, 45], rt.get([3, 45], "half", v1_half), rt.int(2)), v1_n)) : true), "even");
+    if (rt.truthy(rt.get([4, 7], "even", v1_even))) {
+      return "even";
+    } else {
+      return "odd";
+    }
+  }), "classify");
+  (yield rt.call([6, 1], rt.get([6, 1], "puts"), (yield rt.call([6, 6], rt.get([6, 6], "classify", v0_classify), rt.neg([6, 15], rt.int(1)))), (yield rt.call([6, 20], rt.get([6, 20], "classify", v0_classify), rt.int(2))), (yield rt.call([6, 33], rt.get([6, 33], "classify", v0_classify), rt.int(3)))));
+  v0_count = rt.name(rt.fn("fn(n, acc) {\nif(n == 0) return acc;count((n - 1), (acc + 1))\n}", 2, function* (v1_n, v1_acc) {
+    if (rt.truthy(rt.eq([8, 7], v1_n, rt.int(0)))) {
+      return v1_acc;
+    }
+    return rt.tail([9, 3], rt.get([9, 3], "count", v0_count), rt.sub([9, 9], v1_n, rt.int(1)), rt.add([9, 16], v1_acc, rt.int(1)));
+  }), "count");
+  (yield rt.call([11, 1], rt.get([11, 1], "puts"), (yield rt.call([11, 6], rt.get([11, 6], "count", v0_count), rt.int(50000), rt.int(0)))));
+  v0_empty = rt.name((rt.truthy(false) ? rt.int(1) : null), "empty");
+  return (yield rt.call([13, 1], rt.get([13, 1], "puts"), rt.get([13, 6], "empty", v0_empty), (rt.truthy(true) ? (v0_y = rt.name(rt.int(1), "y"), rt.get([13, 36], "y", v0_y)) : null)));
+}
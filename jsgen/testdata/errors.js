@@ -0,0 +1,13 @@
+function* program() {
+  let v0_inner, v0_outer;
+  v0_inner = rt.name(rt.fn("fn(x) {\n(x + one)\n}", 1, function* (v1_x) {
+    return rt.add([1, 21], v1_x, "one");
+  }), "inner");
+  v0_outer = rt.name(rt.fn("fn() {\nlet r = inner(1);r\n}", 0, function* () {
+    let v1_r;
+    v1_r = rt.name((yield rt.call([2, 28], rt.get([2, 28], "inner", v0_inner), rt.int(1))), "r");
+    return rt.get([2, 38], "r", v1_r);
+  }), "outer");
+  (yield rt.call([3, 1], rt.get([3, 1], "puts"), "before"));
+  return (yield rt.call([4, 1], rt.get([4, 1], "outer", v0_outer)));
+}
@@ -0,0 +1,14 @@
+function* program() {
+  let v0_rules, v0_check;
+  v0_rules = rt.name(rt.hash(rt.key([1, 13], "limit"), rt.int(10), rt.key([1, 13], true), [rt.int(1), rt.int(2)], rt.key([1, 13], rt.int(3)), rt.fn("fn(x) {\n(x * 3)\n}", 1, function* (v1_x) {
+    return rt.mul([1, 52], v1_x, rt.int(3));
+  })), "rules");
+  (yield rt.call([2, 1], rt.get([2, 1], "puts"), rt.get([2, 6], "rules", v0_rules), rt.index([2, 13], rt.get([2, 13], "rules", v0_rules), "limit"), (yield rt.call([2, 29], rt.index([2, 29], rt.get([2, 29], "rules", v0_rules), rt.int(3)), rt.int(4))), rt.index([2, 42], rt.index([2, 42], rt.get([2, 42], "rules", v0_rules), true), rt.int(1)), rt.index([2, 58], rt.get([2, 58], "rules", v0_rules), "none")));
+  (yield rt.call([3, 1], rt.get([3, 1], "puts"), [rt.int(1), "two", [rt.int(3)]], (yield rt.call([3, 23], rt.get([3, 23], "first"), [rt.int(1), rt.int(2)])), (yield rt.call([3, 38], rt.get([3, 38], "last"), [])), (yield rt.call([3, 48], rt.get([3, 48], "rest"), [rt.int(1), rt.int(2), rt.int(3)])), (yield rt.call([3, 65], rt.get([3, 65], "push"), [], "x"))));
+  v0_check = rt.name(rt.fn("fn(h) {\n(h[fn() ])\n}", 1, function* (v1_h) {
+    return rt.index([4, 21], v1_h, rt.fn("fn() {\n\n}", 0, function* () {
+      return null;
+    }));
+  }), "check");
+  return (yield rt.call([5, 1], rt.get([5, 1], "check", v0_check), rt.get([5, 7], "rules", v0_rules)));
+}
@@ -0,0 +1,8 @@
+function* program() {
+  let v0_max;
+  v0_max = rt.name(rt.int(2147483647, 4294967295), "max");
+  (yield rt.call([2, 1], rt.get([2, 1], "puts"), rt.mul([2, 6], rt.int(3037000500), rt.int(3037000500)), rt.int(2097152, 1), rt.sub([2, 49], rt.int(2097152, 1), rt.int(1))));
+  (yield rt.call([3, 1], rt.get([3, 1], "puts"), rt.add([3, 6], rt.get([3, 6], "max", v0_max), rt.int(1)), rt.sub([3, 15], rt.neg([3, 15], rt.get([3, 16], "max", v0_max)), rt.int(1)), rt.div([3, 26], rt.sub([3, 26], rt.neg([3, 26], rt.get([3, 27], "max", v0_max)), rt.int(1)), rt.neg([3, 38], rt.int(1))), rt.div([3, 42], rt.get([3, 42], "max", v0_max), rt.int(1000000007))));
+  (yield rt.call([4, 1], rt.get([4, 1], "puts"), rt.eq([4, 6], rt.int(2097152, 1), rt.int(2097152, 0)), rt.hash(rt.key([4, 44], rt.int(2097152, 1)), "big", rt.key([4, 44], rt.neg([4, 70], rt.int(1))), "small")));
+  return [rt.mul([5, 2], rt.get([5, 2], "max", v0_max), rt.get([5, 8], "max", v0_max)), rt.mul([5, 13], (yield rt.call([5, 13], rt.get([5, 13], "len"), "abc")), rt.int(1073741824, 0))];
+}
@@ -11,11 +11,13 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char being read
+	line         int  // line of current char
+	column       int  // column of current char
 }
 
 // New returns a Lexer that will analyze the specified input.
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
@@ -26,6 +28,9 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	// every token starts at the current char
+	pos := token.Position{Line: l.line, Column: l.column}
+
 	// create the appropriate Token based on the current character
 	switch l.ch {
 	case '=':
@@ -39,6 +44,11 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '"':
+		tok.Type = token.STRING
+		tok.Literal = l.readString()
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -47,6 +57,10 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
@@ -54,6 +68,12 @@ func (l *Lexer) NextToken() token.Token {
 	case '-':
 		tok = newToken(token.MINUS, l.ch)
 	case '/':
+		if l.peekChar() == '/' {
+			tok.Type = token.COMMENT
+			tok.Literal = l.readComment()
+			tok.Pos = pos
+			return tok
+		}
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
@@ -77,15 +97,18 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			tok.Pos = pos
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
+	tok.Pos = pos
 	// read the next char
 	l.readChar()
 	// return the token created from char just read
@@ -93,6 +116,14 @@ func (l *Lexer) NextToken() token.Token {
 }
 
 func (l *Lexer) readChar() {
+	// a newline ends the line it's on
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	if l.readPosition <= len(l.input) {
+		l.column++
+	}
 	// current char is null if we've gone over the end
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
@@ -126,6 +157,24 @@ func (l *Lexer) readNumber() string {
 	return l.readMultiChar(isDigit)
 }
 
+// reads a string up to the closing quote or the end of the input, leaving the
+// closing quote as the current char
+func (l *Lexer) readString() string {
+	position := l.position + 1
+	for {
+		l.readChar()
+		if l.ch == '"' || l.ch == 0 {
+			break
+		}
+	}
+	return l.input[position:l.position]
+}
+
+// reads a comment up to, but not including, the end of its line
+func (l *Lexer) readComment() string {
+	return l.readMultiChar(func(ch byte) bool { return ch != '\n' && ch != 0 })
+}
+
 // generalizes reading of numbers and words
 func (l *Lexer) readMultiChar(fn func(byte) bool) string {
 	position := l.position
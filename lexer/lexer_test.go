@@ -95,10 +95,7 @@ bana&a   "ohmygod!"`
 		{token.IDENT, "bana"},
 		{token.ILLEGAL, "&"},
 		{token.IDENT, "a"},
-        {token.ILLEGAL, "\""},
-        {token.IDENT, "ohmygod"},
-		{token.BANG, "!"},
-        {token.ILLEGAL, "\""},
+		{token.STRING, "ohmygod!"},
 		{token.EOF, ""},
 	}
 	l := New(input)
@@ -116,7 +113,7 @@ bana&a   "ohmygod!"`
 }
 
 func TestNextTokenSimpleInput(t *testing.T) {
-	input := `=+(){},;`
+	input := `=+(){},;[]:"foo bar"""`
 	tests := []struct {
 		expectedType    token.TokenType
 		expectedLiteral string
@@ -129,6 +126,80 @@ func TestNextTokenSimpleInput(t *testing.T) {
 		{token.RBRACE, "}"},
 		{token.COMMA, ","},
 		{token.SEMICOLON, ";"},
+		{token.LBRACKET, "["},
+		{token.RBRACKET, "]"},
+		{token.COLON, ":"},
+		{token.STRING, "foo bar"},
+		{token.STRING, ""},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + 10;
+`
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+	}{
+		{token.LET, token.Position{Line: 1, Column: 1}},
+		{token.IDENT, token.Position{Line: 1, Column: 5}},
+		{token.ASSIGN, token.Position{Line: 1, Column: 7}},
+		{token.INT, token.Position{Line: 1, Column: 9}},
+		{token.SEMICOLON, token.Position{Line: 1, Column: 10}},
+		{token.IDENT, token.Position{Line: 2, Column: 3}},
+		{token.PLUS, token.Position{Line: 2, Column: 5}},
+		{token.INT, token.Position{Line: 2, Column: 7}},
+		{token.SEMICOLON, token.Position{Line: 2, Column: 9}},
+		{token.EOF, token.Position{Line: 3, Column: 1}},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - position wrong. expected=%s, got=%s",
+				i, tt.expectedPos, tok.Pos)
+		}
+	}
+}
+
+func TestNextTokenComments(t *testing.T) {
+	input := `// add adds
+// two numbers.
+let add = x / y; // not a doc comment
+//`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// add adds"},
+		{token.COMMENT, "// two numbers."},
+		{token.LET, "let"},
+		{token.IDENT, "add"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// not a doc comment"},
+		{token.COMMENT, "//"},
 		{token.EOF, ""},
 	}
 	l := New(input)
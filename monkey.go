@@ -0,0 +1,75 @@
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+// Options configures an evaluation.
+type Options struct {
+	// Globals are bound by name before the program is evaluated. Their
+	// values are converted as by ToObject.
+	Globals map[string]interface{}
+	// Limits bounds the resources the evaluation may use.
+	Limits evaluator.Limits
+}
+
+// ParseError is the error returned when program text cannot be parsed.
+type ParseError struct {
+	Errors []string // the parser's error messages
+}
+
+func (e *ParseError) Error() string {
+	return "parse error: " + strings.Join(e.Errors, "; ")
+}
+
+// RuntimeError is the error returned when evaluating a program fails.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Err.Pos.IsValid() {
+		return e.Err.Pos.String() + ": " + e.Err.Message
+	}
+	return e.Err.Message
+}
+
+// Eval lexes, parses and evaluates the Monkey program src, and returns the
+// value of its last statement converted as by FromObject. Functions in the
+// value are called under opts.Limits, but without ctx.
+//
+// The error is a *ParseError if src cannot be parsed, a *RuntimeError if
+// evaluation fails, and an *evaluator.LimitError if evaluation is stopped
+// because it exceeds opts.Limits or ctx is done.
+func Eval(ctx context.Context, src string, opts Options) (interface{}, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	env := object.NewEnvironment()
+	for name, value := range opts.Globals {
+		obj, err := ToObject(value)
+		if err != nil {
+			return nil, fmt.Errorf("global %s: %s", name, err)
+		}
+		env.Set(name, obj)
+	}
+
+	result, err := evaluator.EvalContext(ctx, program, env, opts.Limits)
+	if err != nil {
+		return nil, err
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, &RuntimeError{Err: errObj}
+	}
+	return fromObject(result, applyUnder(opts.Limits))
+}
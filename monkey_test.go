@@ -0,0 +1,189 @@
+package monkey
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adamvinueza/monkey/evaluator"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		globals  map[string]interface{}
+		expected interface{}
+	}{
+		{"1 + 2", nil, int64(3)},
+		{`"a" + "b"`, nil, "ab"},
+		{"1 < 2", nil, true},
+		{"if (false) { 1 }", nil, nil},
+		{"[1, [true, \"x\"]]", nil, []interface{}{int64(1),
+			[]interface{}{true, "x"}}},
+		{`{"a": 1, "b": [2]}`, nil, map[string]interface{}{"a": int64(1),
+			"b": []interface{}{int64(2)}}},
+		{`{1: "one", "two": 2}`, nil, map[interface{}]interface{}{
+			int64(1): "one", "two": int64(2)}},
+		{"limit * 2", map[string]interface{}{"limit": 50}, int64(100)},
+		{"len(names)", map[string]interface{}{
+			"names": []string{"a", "b", "c"}}, int64(3)},
+		{`config["port"] + offset`, map[string]interface{}{
+			"config": map[string]int{"port": 8000},
+			"offset": uint8(80)}, int64(8080)},
+		{`flags[true]`, map[string]interface{}{
+			"flags": map[bool]string{true: "yes"}}, "yes"},
+		{"p", map[string]interface{}{"p": new(int)}, int64(0)},
+		{"n", map[string]interface{}{"n": (*int)(nil)}, nil},
+		{`greet("Monkey")`, map[string]interface{}{
+			"greet": func(args ...interface{}) (interface{}, error) {
+				return "hello " + args[0].(string), nil
+			}}, "hello Monkey"},
+		{`sum([1, 2, 3])`, map[string]interface{}{
+			"sum": Func(func(args ...interface{}) (interface{}, error) {
+				var total int64
+				for _, a := range args[0].([]interface{}) {
+					total += a.(int64)
+				}
+				return total, nil
+			})}, int64(6)},
+	}
+
+	for _, tt := range tests {
+		result, err := Eval(context.Background(), tt.input,
+			Options{Globals: tt.globals})
+		if err != nil {
+			t.Errorf("Eval(%q) failed: %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Eval(%q) wrong. expected=%#v, found=%#v", tt.input,
+				tt.expected, result)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	failing := func(args ...interface{}) (interface{}, error) {
+		return nil, errors.New("host failure")
+	}
+	tests := []struct {
+		input       string
+		globals     map[string]interface{}
+		expectedErr string
+	}{
+		{"let = 1", nil, "parse error: expected next token to be IDENT, " +
+			"found =; no prefix parse function for = found"},
+		{"1 + true", nil, "1:1: type mismatch: INTEGER + BOOLEAN"},
+		{"f()", map[string]interface{}{"f": failing}, "1:1: host failure"},
+		{"x", map[string]interface{}{"x": 1.5},
+			"global x: cannot convert float64 to a Monkey value"},
+		{"x", map[string]interface{}{"x": uint64(1 << 63)},
+			"global x: 9223372036854775808 overflows a Monkey integer"},
+		{"x", map[string]interface{}{"x": map[float32]int{1.5: 1}},
+			"global x: cannot convert float32 to a Monkey value"},
+		{"x", map[string]interface{}{"x": struct{}{}},
+			"global x: cannot convert struct {} to a Monkey value"},
+	}
+
+	for _, tt := range tests {
+		_, err := Eval(context.Background(), tt.input,
+			Options{Globals: tt.globals})
+		if err == nil {
+			t.Errorf("Eval(%q) succeeded, expected error %q", tt.input,
+				tt.expectedErr)
+			continue
+		}
+		if err.Error() != tt.expectedErr {
+			t.Errorf("Eval(%q) wrong error. expected=%q, found=%q", tt.input,
+				tt.expectedErr, err.Error())
+		}
+	}
+
+	_, err := Eval(context.Background(), "1 +", Options{})
+	if _, ok := err.(*ParseError); !ok {
+		t.Errorf("error is not *ParseError, found %T", err)
+	}
+	_, err = Eval(context.Background(), "-true", Options{})
+	if _, ok := err.(*RuntimeError); !ok {
+		t.Errorf("error is not *RuntimeError, found %T", err)
+	}
+}
+
+func TestEvalLimits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Eval(ctx, "1", Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error is not context.Canceled: %v", err)
+	}
+
+	_, err = Eval(context.Background(), "let f = fn() { 1 + f() }; f()",
+		Options{Limits: evaluator.Limits{MaxDepth: 100}})
+	le, ok := err.(*evaluator.LimitError)
+	if !ok || le.Kind != evaluator.DepthLimit {
+		t.Errorf("error is not a depth limit error: %v", err)
+	}
+
+	_, err = Eval(context.Background(), "let f = fn() { f() }; f()",
+		Options{Limits: evaluator.Limits{MaxSteps: 1000}})
+	le, ok = err.(*evaluator.LimitError)
+	if !ok || le.Kind != evaluator.StepLimit {
+		t.Errorf("error is not a step limit error: %v", err)
+	}
+}
+
+func TestMonkeyFunctionsFromGo(t *testing.T) {
+	result, err := Eval(context.Background(), `
+let make = fn(n) { fn(x) { if (x == 0) { -true } else { x * n } } };
+{"triple": make(3), "len": len}
+`, Options{})
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+	fns := result.(map[string]interface{})
+
+	triple := fns["triple"].(Func)
+	tripled, err := triple(14)
+	if err != nil {
+		t.Fatalf("triple failed: %s", err)
+	}
+	if tripled != int64(42) {
+		t.Errorf("triple(14) wrong. expected=42, found=%#v", tripled)
+	}
+
+	_, err = triple(0)
+	if err == nil || !strings.Contains(err.Error(), "unknown operator: -BOOLEAN") {
+		t.Errorf("triple(0) wrong error: %v", err)
+	}
+
+	length, err := fns["len"].(Func)([]int{1, 2})
+	if err != nil || length != int64(2) {
+		t.Errorf("len([1, 2]) wrong. expected=2, found=%#v (%v)", length, err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	values := []interface{}{
+		nil, true, int64(-7), "str",
+		[]interface{}{int64(1), "two", []interface{}{}},
+		map[string]interface{}{"k": map[interface{}]interface{}{int64(1): false}},
+	}
+
+	for _, v := range values {
+		obj, err := ToObject(v)
+		if err != nil {
+			t.Errorf("ToObject(%#v) failed: %s", v, err)
+			continue
+		}
+		back, err := FromObject(obj)
+		if err != nil {
+			t.Errorf("FromObject(%s) failed: %s", obj.Inspect(), err)
+			continue
+		}
+		if !reflect.DeepEqual(v, back) {
+			t.Errorf("round trip wrong. expected=%#v, found=%#v", v, back)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package object
+
+import (
+	"github.com/adamvinueza/monkey/code"
+)
+
+// CompiledFunction represents the bytecode compiled from a function literal.
+// It is a constant of the compiled program, from which the virtual machine
+// creates a Closure each time the literal is evaluated.
+type CompiledFunction struct {
+	Name          string // the name the function is bound to, if any
+	Instructions  code.Instructions
+	NumParameters int
+	// Locals holds the names of the function's local variables, by index.
+	// The parameters come first.
+	Locals []string
+	// Lines gives the positions in the source text of the instructions.
+	Lines code.LineTable
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	if cf.Name == "" {
+		return "compiled function"
+	}
+	return "compiled function " + cf.Name
+}
+
+// Closure represents a function value in the virtual machine: a compiled
+// function along with the local variables of the calls enclosing its
+// definition, which it shares with them. It is the counterpart of Function, so
+// its type is FUNCTION.
+type Closure struct {
+	Fn *CompiledFunction
+	// Free holds the locals of the enclosing calls, innermost first.
+	Free []*Locals
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string  { return c.Fn.Inspect() }
+
+// Locals holds the values of the local variables of a call of a compiled
+// function. A nil value is a variable that hasn't been assigned yet.
+type Locals struct {
+	Fn     *CompiledFunction
+	Values []Object
+}
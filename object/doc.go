@@ -0,0 +1,8 @@
+// Package object contains the values that Monkey programs compute with, such
+// as integers, booleans and functions, along with the environments that bind
+// names to them.
+//
+// Every value implements the Object interface. Functions are closures: each
+// Function holds the Environment in which it was defined, and calling it
+// evaluates its body in a new Environment enclosed by that one.
+package object
@@ -0,0 +1,39 @@
+package object
+
+// Environment binds names to values. An Environment may be enclosed by an
+// outer Environment, in which case names not bound in it are looked up in the
+// outer one.
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+}
+
+// NewEnvironment returns an empty Environment with no outer Environment.
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object)}
+}
+
+// NewEnclosedEnvironment returns an empty Environment enclosed by outer.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// Get returns the value bound to name in this Environment or, failing that, in
+// the Environments enclosing it. The boolean result reports whether the name
+// was found.
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set binds name to val in this Environment, shadowing any binding of name in
+// the Environments enclosing it, and returns val.
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
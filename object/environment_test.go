@@ -0,0 +1,44 @@
+package object
+
+import "testing"
+
+func TestEnvironmentChaining(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("a", &Integer{Value: 1})
+	outer.Set("b", &Integer{Value: 2})
+
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("b", &Integer{Value: 3})
+	inner.Set("c", &Integer{Value: 4})
+
+	tests := []struct {
+		env      *Environment
+		name     string
+		expected int64 // -1 if the name should not be found
+	}{
+		{inner, "a", 1},
+		{inner, "b", 3},
+		{inner, "c", 4},
+		{inner, "d", -1},
+		{outer, "b", 2},
+		{outer, "c", -1},
+	}
+
+	for _, tt := range tests {
+		obj, ok := tt.env.Get(tt.name)
+		if tt.expected == -1 {
+			if ok {
+				t.Errorf("%s: expected not found, found %s", tt.name,
+					obj.Inspect())
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: not found", tt.name)
+			continue
+		}
+		if i := obj.(*Integer).Value; i != tt.expected {
+			t.Errorf("%s: expected %d, found %d", tt.name, tt.expected, i)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// ObjectType represents the type of a Monkey value.
+type ObjectType string
+
+const (
+	INTEGER_OBJ      = "INTEGER"
+	BOOLEAN_OBJ      = "BOOLEAN"
+	NULL_OBJ         = "NULL"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	ERROR_OBJ        = "ERROR"
+	FUNCTION_OBJ     = "FUNCTION"
+	BUILTIN_OBJ      = "BUILTIN"
+	ARRAY_OBJ        = "ARRAY"
+	STRING_OBJ       = "STRING"
+	HASH_OBJ         = "HASH"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+)
+
+// Object is a value produced by evaluating a Monkey program.
+type Object interface {
+	Type() ObjectType
+	// Inspect returns a representation of the value for display, e.g. in
+	// the REPL.
+	Inspect() string
+}
+
+// Integer represents a signed 64-bit integer.
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+
+// Boolean represents a boolean value.
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+
+// Null represents the absence of a value, such as the value of an if
+// expression whose condition is false and which has no else branch.
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+// ReturnValue wraps the value of a return statement while it is passed up
+// through the enclosing statements.
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// Error represents a runtime error, such as applying an operator to values of
+// the wrong type. An Error stops evaluation of the program.
+//
+// Stack holds the function calls that were active when the error occurred,
+// innermost first. A function that made a call in tail position, as its last
+// action, is no longer active, so it is not in the stack.
+type Error struct {
+	Message string
+	Pos     token.Position // where the error occurred, if known
+	Stack   []Frame
+}
+
+// Frame represents an active function call.
+type Frame struct {
+	Function string         // the name of the function called
+	CallPos  token.Position // where the function was called
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+	return "ERROR: " + e.Message
+}
+
+// TraceFrames is the number of calls shown at each end of a long stack trace.
+const TraceFrames = 10
+
+// Trace returns the error's message followed by a trace of the function calls
+// active when it occurred, in the manner of a Go panic. Each call is shown with
+// the position reached in the function, innermost first, ending with the top
+// level of the program, "main". For example:
+//  ERROR: division by zero: 1 / 0
+//
+//  stack trace:
+//  divide(...)
+//  	math.mk:2:14
+//  main
+//  	math.mk:5:1
+// The filename, which may be empty, is prefixed to each position. Of a stack
+// deeper than 2*TraceFrames calls, as when the maximum depth of calls was
+// exceeded, only the innermost and outermost TraceFrames calls are shown.
+func (e *Error) Trace(filename string) string {
+	var out bytes.Buffer
+
+	out.WriteString("ERROR: " + e.Message + "\n\nstack trace:\n")
+
+	pos := e.Pos
+	for i, f := range e.Stack {
+		if i == TraceFrames && len(e.Stack) > 2*TraceFrames {
+			fmt.Fprintf(&out, "...%d calls elided...\n",
+				len(e.Stack)-2*TraceFrames)
+		}
+		if i < TraceFrames || i >= len(e.Stack)-TraceFrames {
+			writeFrame(&out, f.Function+"(...)", filename, pos)
+		}
+		pos = f.CallPos
+	}
+	writeFrame(&out, "main", filename, pos)
+
+	return out.String()
+}
+
+func writeFrame(out *bytes.Buffer, function, filename string,
+	pos token.Position) {
+	out.WriteString(function + "\n\t")
+	if filename != "" {
+		out.WriteString(filename + ":")
+	}
+	out.WriteString(pos.String() + "\n")
+}
+
+// Function represents a function value: the parameters and body of a function
+// literal, along with the environment in which the literal was evaluated.
+//
+// Name is the name the function was first bound to by a let statement, or
+// empty if the function is anonymous.
+type Function struct {
+	Name       string
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+// BuiltinFunction is the Go implementation of a built-in function.
+type BuiltinFunction func(args ...Object) Object
+
+// Applier calls fn, a function or builtin, with args, for a builtin that
+// calls the functions it is passed. The call is made under the limits of the
+// evaluation calling the builtin, and the error is non-nil if the evaluation
+// is stopped, in which case the builtin should return as soon as it can.
+type Applier func(fn Object, args []Object) (Object, error)
+
+// Builtin represents a built-in function, such as len, which is implemented in
+// Go rather than in Monkey.
+type Builtin struct {
+	Name   string
+	Module string // the module the builtin belongs to, such as "io", if any
+	Fn     BuiltinFunction
+	// Apply, if set, is called by the evaluator instead of Fn, so that the
+	// builtin can call the functions it is passed with apply.
+	Apply func(apply Applier, args ...Object) Object
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function " + b.Name }
+
+// Array represents an array of values.
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// String represents a string.
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
+// HashKey is the key under which a value is stored in a Hash. Equal values
+// have equal HashKeys.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64 // the value of an integer or boolean
+	Str   string // the value of a string
+}
+
+// Hashable is implemented by the values that can be used as keys in a Hash.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Str: s.Value}
+}
+
+// HashPair is a key-value pair stored in a Hash.
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash represents a hash, which maps keys to values.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+
+// Inspect returns a representation of the hash with its pairs sorted by key,
+// so that equal hashes have equal representations.
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.SortedPairs() {
+		pairs = append(pairs, inspectKey(pair.Key)+": "+pair.Value.Inspect())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// SortedPairs returns the pairs of the hash, sorted by the type of their keys
+// and then by the keys' values.
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return lessKey(pairs[i].Key, pairs[j].Key)
+	})
+	return pairs
+}
+
+func lessKey(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value < b.(*Integer).Value
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	}
+	return false
+}
+
+// inspectKey quotes string keys, so that the key "1" can be told from the key
+// 1.
+func inspectKey(key Object) string {
+	if s, ok := key.(*String); ok {
+		return fmt.Sprintf("%q", s.Value)
+	}
+	return key.Inspect()
+}
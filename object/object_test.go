@@ -0,0 +1,133 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/adamvinueza/monkey/token"
+)
+
+func TestErrorTrace(t *testing.T) {
+	err := &Error{
+		Message: "division by zero: 1 / 0",
+		Pos:     token.Position{Line: 2, Column: 3},
+		Stack: []Frame{
+			{Function: "divide", CallPos: token.Position{Line: 5, Column: 22}},
+			{Function: "fn", CallPos: token.Position{Line: 9, Column: 1}},
+		},
+	}
+
+	tests := []struct {
+		filename string
+		expected string
+	}{
+		{"", `ERROR: division by zero: 1 / 0
+
+stack trace:
+divide(...)
+	2:3
+fn(...)
+	5:22
+main
+	9:1
+`},
+		{"math.mk", `ERROR: division by zero: 1 / 0
+
+stack trace:
+divide(...)
+	math.mk:2:3
+fn(...)
+	math.mk:5:22
+main
+	math.mk:9:1
+`},
+	}
+
+	for _, tt := range tests {
+		if trace := err.Trace(tt.filename); trace != tt.expected {
+			t.Errorf("Trace(%q) wrong. expected=%q, found=%q", tt.filename,
+				tt.expected, trace)
+		}
+	}
+
+	topLevel := &Error{Message: "oops", Pos: token.Position{Line: 1, Column: 1}}
+	expected := "ERROR: oops\n\nstack trace:\nmain\n\t1:1\n"
+	if trace := topLevel.Trace(""); trace != expected {
+		t.Errorf("Trace wrong. expected=%q, found=%q", expected, trace)
+	}
+}
+
+func TestErrorTraceElision(t *testing.T) {
+	err := &Error{Message: "too deep", Pos: token.Position{Line: 1, Column: 1}}
+	for i := 0; i < 2*TraceFrames+3; i++ {
+		err.Stack = append(err.Stack, Frame{Function: "f",
+			CallPos: token.Position{Line: i + 2, Column: 1}})
+	}
+
+	lines := strings.Split(err.Trace(""), "\n")
+	elided := "...3 calls elided..."
+	if lines[3+2*TraceFrames] != elided {
+		t.Errorf("expected %q after %d frames, found %q", elided, TraceFrames,
+			lines[3+2*TraceFrames])
+	}
+	// The header, the innermost and outermost frames, the elision, main and
+	// the empty line after it.
+	if len(lines) != 3+4*TraceFrames+1+2+1 {
+		t.Errorf("wrong number of lines: %d", len(lines))
+	}
+	// The first frame shown after the elision is called from the position
+	// given by the frame before it.
+	expected := fmt.Sprintf("\t%d:1", TraceFrames+3+1)
+	if lines[5+2*TraceFrames] != expected {
+		t.Errorf("wrong position after elision. expected=%q, found=%q",
+			expected, lines[5+2*TraceFrames])
+	}
+}
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeyTypes(t *testing.T) {
+	one := &Integer{Value: 1}
+	yes := &Boolean{Value: true}
+	str := &String{Value: "1"}
+
+	if one.HashKey() == yes.HashKey() || one.HashKey() == str.HashKey() {
+		t.Errorf("values of different types have same hash keys")
+	}
+	if yes.HashKey() != (&Boolean{Value: true}).HashKey() {
+		t.Errorf("booleans with same value have different hash keys")
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	h := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, pair := range []HashPair{
+		{Key: &String{Value: "b"}, Value: &Integer{Value: 2}},
+		{Key: &Integer{Value: 10}, Value: &String{Value: "ten"}},
+		{Key: &String{Value: "a"}, Value: &Boolean{Value: true}},
+		{Key: &Integer{Value: -1}, Value: &Array{}},
+	} {
+		h.Pairs[pair.Key.(Hashable).HashKey()] = pair
+	}
+
+	expected := `{-1: [], 10: ten, "a": true, "b": 2}`
+	if h.Inspect() != expected {
+		t.Errorf("Inspect wrong. expected=%q, found=%q", expected, h.Inspect())
+	}
+}
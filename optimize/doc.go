@@ -0,0 +1,16 @@
+// Package optimize rewrites Monkey programs so that they do less work when
+// they run, without changing what they do. Its result can be evaluated by
+// package evaluator or compiled by package compiler:
+//  program = optimize.Program(program)
+//  result := evaluator.Eval(program, object.NewEnvironment())
+//
+// Two optimizations are made:
+//
+// Constant folding replaces prefix and infix expressions whose operands are
+// integer or boolean literals with the literal they evaluate to, so that
+// "2 * 3 + x" becomes "6 + x". Expressions that fail, such as "1 / 0", are
+// left to fail when the program runs.
+//
+// Dead-branch elimination removes the branches of if expressions whose
+// conditions are literals, so that "if (true) { a } else { b }" becomes "a".
+package optimize
@@ -0,0 +1,286 @@
+package optimize
+
+import (
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/ast/build"
+)
+
+// Program returns an optimized version of program, which evaluates to the same
+// value, fails with the same errors, and has the same effects. Program doesn't
+// modify program; the nodes of the result that aren't changed by optimization
+// are shared with it.
+func Program(program *ast.Program) *ast.Program {
+	p := *program
+	p.Statements = statements(program.Statements, true)
+	return &p
+}
+
+// statements optimizes a sequence of statements. If value is true, the value
+// of the last statement is the value of the sequence, and is preserved.
+func statements(stmts []ast.Statement, value bool) []ast.Statement {
+	result := []ast.Statement{}
+	for n, stmt := range stmts {
+		stmt = statement(stmt)
+		last := value && n == len(stmts)-1
+		if branch, ok := decidedBranch(stmt); ok && canSplice(branch, last) {
+			// An if statement whose branch is known is replaced by the
+			// statements of the branch, since blocks don't have scopes of
+			// their own.
+			if branch != nil {
+				result = append(result, branch.Statements...)
+			}
+			continue
+		}
+		result = append(result, stmt)
+	}
+	return result
+}
+
+// decidedBranch returns the branch that will be taken by stmt, if it is an
+// expression statement holding an if expression whose condition is constant.
+// The branch is nil if no branch will be taken.
+func decidedBranch(stmt ast.Statement) (*ast.BlockStatement, bool) {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+	ie, ok := es.Expression.(*ast.IfExpression)
+	if !ok {
+		return nil, false
+	}
+	truthy, ok := constantTruth(ie.Condition)
+	if !ok {
+		return nil, false
+	}
+	if truthy {
+		return ie.Consequence, true
+	}
+	return ie.Alternative, true
+}
+
+// canSplice reports whether the statements of branch can replace the if
+// statement taking it. If the if statement is last, its value must be that of
+// the last of those statements.
+func canSplice(branch *ast.BlockStatement, last bool) bool {
+	if !last {
+		return true
+	}
+	if branch == nil || len(branch.Statements) == 0 {
+		return false
+	}
+	switch branch.Statements[len(branch.Statements)-1].(type) {
+	case *ast.ExpressionStatement, *ast.ReturnStatement:
+		return true
+	}
+	return false
+}
+
+func statement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		s := *stmt
+		s.Value = expression(stmt.Value)
+		return &s
+	case *ast.ReturnStatement:
+		s := *stmt
+		s.ReturnValue = expression(stmt.ReturnValue)
+		return &s
+	case *ast.ExpressionStatement:
+		s := *stmt
+		s.Expression = expression(stmt.Expression)
+		return &s
+	case *ast.BlockStatement:
+		return block(stmt)
+	}
+	return stmt
+}
+
+// block optimizes a block whose value is that of its last statement.
+func block(b *ast.BlockStatement) *ast.BlockStatement {
+	if b == nil {
+		return nil
+	}
+	s := *b
+	s.Statements = statements(b.Statements, true)
+	return &s
+}
+
+func expression(expr ast.Expression) ast.Expression {
+	switch expr := expr.(type) {
+	case *ast.PrefixExpression:
+		e := *expr
+		e.Right = expression(expr.Right)
+		if folded := foldPrefix(&e); folded != nil {
+			return folded
+		}
+		return &e
+	case *ast.InfixExpression:
+		e := *expr
+		e.Left = expression(expr.Left)
+		e.Right = expression(expr.Right)
+		if folded := foldInfix(&e); folded != nil {
+			return folded
+		}
+		return &e
+	case *ast.IfExpression:
+		return ifExpression(expr)
+	case *ast.FunctionLiteral:
+		e := *expr
+		e.Body = block(expr.Body)
+		return &e
+	case *ast.CallExpression:
+		e := *expr
+		e.Function = expression(expr.Function)
+		e.Arguments = expressions(expr.Arguments)
+		return &e
+	case *ast.ArrayLiteral:
+		e := *expr
+		e.Elements = expressions(expr.Elements)
+		return &e
+	case *ast.IndexExpression:
+		e := *expr
+		e.Left = expression(expr.Left)
+		e.Index = expression(expr.Index)
+		return &e
+	case *ast.HashLiteral:
+		e := *expr
+		e.Pairs = make([]*ast.HashPair, len(expr.Pairs))
+		for n, pair := range expr.Pairs {
+			e.Pairs[n] = &ast.HashPair{
+				Key:   expression(pair.Key),
+				Value: expression(pair.Value),
+			}
+		}
+		return &e
+	}
+	return expr
+}
+
+func expressions(exprs []ast.Expression) []ast.Expression {
+	if exprs == nil {
+		return nil
+	}
+	result := make([]ast.Expression, len(exprs))
+	for n, e := range exprs {
+		result[n] = expression(e)
+	}
+	return result
+}
+
+// ifExpression optimizes an if expression. If its condition is constant and
+// the branch it takes consists of a single expression, the if expression is
+// replaced by that expression. Otherwise a branch that can't be taken is
+// dropped, if the if expression remains valid without it.
+func ifExpression(expr *ast.IfExpression) ast.Expression {
+	e := *expr
+	e.Condition = expression(expr.Condition)
+	e.Consequence = block(expr.Consequence)
+	e.Alternative = block(expr.Alternative)
+
+	truthy, ok := constantTruth(e.Condition)
+	if !ok {
+		return &e
+	}
+	branch := e.Alternative
+	if truthy {
+		branch = e.Consequence
+		e.Alternative = nil
+	}
+	if branch != nil && len(branch.Statements) == 1 {
+		if es, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+			return es.Expression
+		}
+	}
+	return &e
+}
+
+// constantTruth reports whether expr is a literal, which can't fail or have
+// effects, and if so, whether it is truthy.
+func constantTruth(expr ast.Expression) (truthy, ok bool) {
+	switch expr := expr.(type) {
+	case *ast.Boolean:
+		return expr.Value, true
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		return true, true
+	}
+	return false, false
+}
+
+// foldPrefix returns the literal e evaluates to, or nil if its operand isn't
+// constant or applying the operator to it fails.
+func foldPrefix(e *ast.PrefixExpression) ast.Expression {
+	var result ast.Expression
+	switch e.Operator {
+	case "-":
+		if il, ok := e.Right.(*ast.IntegerLiteral); ok {
+			result = positioned(build.Int(-il.Value), e)
+		}
+	case "!":
+		if truthy, ok := constantTruth(e.Right); ok {
+			result = positioned(build.Bool(!truthy), e)
+		}
+	}
+	return result
+}
+
+// foldInfix returns the literal e evaluates to, or nil if its operands aren't
+// constant or applying the operator to them fails. Division by zero is left to
+// fail when the program runs.
+func foldInfix(e *ast.InfixExpression) ast.Expression {
+	if left, ok := e.Left.(*ast.IntegerLiteral); ok {
+		right, ok := e.Right.(*ast.IntegerLiteral)
+		if !ok {
+			return nil
+		}
+		l, r := left.Value, right.Value
+		switch e.Operator {
+		case "+":
+			return positioned(build.Int(l+r), e)
+		case "-":
+			return positioned(build.Int(l-r), e)
+		case "*":
+			return positioned(build.Int(l*r), e)
+		case "/":
+			if r == 0 {
+				return nil
+			}
+			return positioned(build.Int(l/r), e)
+		case "<":
+			return positioned(build.Bool(l < r), e)
+		case ">":
+			return positioned(build.Bool(l > r), e)
+		case "==":
+			return positioned(build.Bool(l == r), e)
+		case "!=":
+			return positioned(build.Bool(l != r), e)
+		}
+		return nil
+	}
+	if left, ok := e.Left.(*ast.Boolean); ok {
+		right, ok := e.Right.(*ast.Boolean)
+		if !ok {
+			return nil
+		}
+		switch e.Operator {
+		case "==":
+			return positioned(build.Bool(left.Value == right.Value), e)
+		case "!=":
+			return positioned(build.Bool(left.Value != right.Value), e)
+		}
+	}
+	return nil
+}
+
+// positioned gives literal, which replaces the expression original, the
+// position of original, so that errors involving the literal are reported
+// where the expression was.
+func positioned(literal ast.Expression, original ast.Expression) ast.Expression {
+	pos := ast.Pos(original)
+	switch literal := literal.(type) {
+	case *ast.IntegerLiteral:
+		literal.Token.Pos = pos
+	case *ast.Boolean:
+		literal.Token.Pos = pos
+	}
+	return literal
+}
@@ -0,0 +1,137 @@
+package optimize
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/difftest"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/parser"
+	"github.com/adamvinueza/monkey/token"
+)
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 * 3 + x", "(program (expr (infix + (int 6) (ident x))))"},
+		{"x + 2 * 3", "(program (expr (infix + (ident x) (int 6))))"},
+		{"-(1 - 5)", "(program (expr (int 4)))"},
+		{"1 < 2 == !false", "(program (expr (bool true)))"},
+		{"!5; !!\"\"", "(program (expr (bool false)) (expr (bool true)))"},
+		{"10 / (5 - 5)",
+			"(program (expr (infix / (int 10) (int 0))))"},
+		{"1 + true", "(program (expr (infix + (int 1) (bool true))))"},
+		{"-true", "(program (expr (prefix - (bool true))))"},
+		{`"a" + "b"`,
+			`(program (expr (infix + (string "a") (string "b"))))`},
+		{"if (true) { a } else { b }", "(program (expr (ident a)))"},
+		{"if (1 > 2) { a } else { b + 1 }",
+			"(program (expr (infix + (ident b) (int 1))))"},
+		{"let x = if (false) { a }; x",
+			"(program (let x (if (bool false) (block (expr (ident a))))) " +
+				"(expr (ident x)))"},
+		{"let x = if (true) { let y = 1; y } else { 2 }; x",
+			"(program (let x (if (bool true) (block (let y (int 1)) " +
+				"(expr (ident y))))) (expr (ident x)))"},
+		{"if (2 > 1) { let y = 1; puts(y); } 3",
+			"(program (let y (int 1)) (expr (call (ident puts) (ident y))) " +
+				"(expr (int 3)))"},
+		{"if (false) { let y = 1; } 3", "(program (expr (int 3)))"},
+		{"if (false) { 1 }", "(program (expr (if (bool false) " +
+			"(block (expr (int 1))))))"},
+		{"if (true) { let y = 1; }",
+			"(program (expr (if (bool true) (block (let y (int 1))))))"},
+		{"fn(x) { if (true) { return 2 * x; } x }",
+			"(program (expr (fn (x) (block (return (infix * (int 2) " +
+				"(ident x))) (expr (ident x))))))"},
+		{"if (x) { 1 + 1 } else { 2 * 2 }",
+			"(program (expr (if (ident x) (block (expr (int 2))) " +
+				"(block (expr (int 4))))))"},
+		{"[1 + 1, {2 * 2: f(3 - 3)}[4]]",
+			"(program (expr (array (int 2) (index (hash (pair (int 4) " +
+				"(call (ident f) (int 0)))) (int 4)))))"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		original := ast.SExpr(program)
+
+		optimized := ast.SExpr(Program(program))
+		if optimized != tt.expected {
+			t.Errorf("wrong optimization of %q.\nexpected=%s\nfound=%s",
+				tt.input, tt.expected, optimized)
+		}
+		if after := ast.SExpr(program); after != original {
+			t.Errorf("optimizing %q modified it.\nbefore=%s\nafter=%s",
+				tt.input, original, after)
+		}
+	}
+}
+
+func TestFoldedPositions(t *testing.T) {
+	program := Program(parse(t, "let x = 1;\nlet y = -(2 * 3) + x;"))
+	infix := program.Statements[1].(*ast.LetStatement).Value.(*ast.InfixExpression)
+	literal, ok := infix.Left.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("left operand not folded. found=%s", ast.SExpr(infix.Left))
+	}
+	expected := token.Position{Line: 2, Column: 9}
+	if literal.Token.Pos != expected {
+		t.Errorf("wrong position. expected=%s, found=%s", expected,
+			literal.Token.Pos)
+	}
+}
+
+// TestBehaviorPreserved checks that optimized programs behave like the
+// originals, with the evaluator and in the virtual machine.
+func TestBehaviorPreserved(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "difftest", "testdata",
+		"*.mky"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkBehavior(t, path, parse(t, string(src)))
+	}
+
+	g := difftest.NewGenerator(1)
+	for n := 0; n < 2000; n++ {
+		program := g.Program()
+		checkBehavior(t, ast.SExpr(program), program)
+	}
+}
+
+func checkBehavior(t *testing.T, name string, program *ast.Program) {
+	t.Helper()
+	expected := difftest.Eval(program)
+	optimized := Program(program)
+	if found := difftest.Eval(optimized); found != expected {
+		t.Errorf("%s: optimization changed evaluation.\nexpected=%+v\nfound=%+v",
+			name, expected, found)
+	}
+	found, err := difftest.Run(optimized)
+	if err != nil {
+		t.Errorf("%s: compiler error: %s", name, err)
+	} else if found != expected {
+		t.Errorf("%s: optimization changed compiled program.\n"+
+			"expected=%+v\nfound=%+v", name, expected, found)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/ast/build"
 	"github.com/adamvinueza/monkey/lexer"
 )
 
@@ -96,6 +97,14 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	t.FailNow()
 }
 
+// testEqualAST fails the test if the two trees differ in anything but their
+// tokens.
+func testEqualAST(t *testing.T, found, expected ast.Node) {
+	if d := ast.Diff(found, expected, ast.CompareOptions{IgnoreTokens: true}); d != "" {
+		t.Fatalf("AST mismatch at %s", d)
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("Expected s.TokenLiteral() to be 'let', found '%s'",
@@ -231,7 +240,12 @@ func TestParsingPrefixExpressions(t *testing.T) {
 			t.Fatalf("program.Statements[0] not *ast.ExpressionStatement, found %T",
 				program.Statements[0])
 		}
-		_ = stmt
+
+		expected := &ast.PrefixExpression{
+			Operator: tt.operator,
+			Right:    &ast.IntegerLiteral{Value: tt.integerValue},
+		}
+		testEqualAST(t, stmt.Expression, expected)
 	}
 }
 
@@ -309,3 +323,352 @@ func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 	}
 	return true
 }
+
+func TestLetStatementDoc(t *testing.T) {
+	input := `// five is
+//   the number 5.
+let five = 5;
+let six = 6; // not doc
+let seven = 7;
+
+// detached
+
+let eight = 8;
+// first group
+
+// second group
+let nine = 9;
+let ten = 10;
+`
+	tests := []struct {
+		expectedName string
+		expectedDoc  string
+	}{
+		{"five", "five is\n  the number 5.\n"},
+		{"six", ""},
+		{"seven", ""},
+		{"eight", ""},
+		{"nine", "second group\n"},
+		{"ten", ""},
+	}
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != len(tests) {
+		t.Fatalf("Expected program.Statements to have %d statements, found %d",
+			len(tests), len(program.Statements))
+	}
+
+	for i, tt := range tests {
+		stmt := program.Statements[i]
+		if !testLetStatement(t, stmt, tt.expectedName) {
+			return
+		}
+		doc := stmt.(*ast.LetStatement).Doc
+		if doc.Text() != tt.expectedDoc {
+			t.Errorf("%s: Doc.Text() wrong. expected=%q, found=%q",
+				tt.expectedName, tt.expectedDoc, doc.Text())
+		}
+		if tt.expectedDoc == "" && doc != nil {
+			t.Errorf("%s: expected nil Doc, found %q", tt.expectedName,
+				doc.Text())
+		}
+	}
+}
+
+func TestLetAndReturnValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ast.Statement
+	}{
+		{"let x = 5;", build.Let("x", build.Int(5))},
+		{"let y = true;", build.Let("y", build.Bool(true))},
+		{"let foobar = y", build.Let("foobar", build.Ident("y"))},
+		{"return 5;", build.Return(build.Int(5))},
+		{"return x + y", build.Return(
+			build.Infix(build.Ident("x"), "+", build.Ident("y")))},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement, found %d",
+				len(program.Statements))
+		}
+		testEqualAST(t, program.Statements[0], tt.expected)
+	}
+}
+
+func TestOperatorPrecedenceParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-a * b", "((-a) * b)"},
+		{"!-a", "(!(-a))"},
+		{"a + b + c", "((a + b) + c)"},
+		{"a + b - c", "((a + b) - c)"},
+		{"a * b * c", "((a * b) * c)"},
+		{"a * b / c", "((a * b) / c)"},
+		{"a + b / c", "(a + (b / c))"},
+		{"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
+		{"3 + 4; -5 * 5", "(3 + 4)((-5) * 5)"},
+		{"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
+		{"5 < 4 != 3 > 4", "((5 < 4) != (3 > 4))"},
+		{"3 + 4 * 5 == 3 * 1 + 4 * 5",
+			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
+		{"true", "true"},
+		{"3 > 5 == false", "((3 > 5) == false)"},
+		{"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4)"},
+		{"(5 + 5) * 2", "((5 + 5) * 2)"},
+		{"-(5 + 5)", "(-(5 + 5))"},
+		{"!(true == true)", "(!(true == true))"},
+		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
+		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
+			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
+		{"add(a + b + c * d / f + g)",
+			"add((((a + b) + ((c * d) / f)) + g))"},
+		{"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, found=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ast.Expression
+	}{
+		{"if (x < y) { x }", build.If(
+			build.Infix(build.Ident("x"), "<", build.Ident("y")),
+			build.Block(build.ExprStmt(build.Ident("x"))),
+			nil)},
+		{"if (x < y) { x } else { let z = y; z }", build.If(
+			build.Infix(build.Ident("x"), "<", build.Ident("y")),
+			build.Block(build.ExprStmt(build.Ident("x"))),
+			build.Block(build.Let("z", build.Ident("y")),
+				build.ExprStmt(build.Ident("z"))))},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement, found %d",
+				len(program.Statements))
+		}
+		testEqualAST(t, program.Statements[0], build.ExprStmt(tt.expected))
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{"fn() {};", []string{}},
+		{"fn(x) {};", []string{"x"}},
+		{"fn(x, y, z) {};", []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		expected := build.ExprStmt(build.Fn(tt.expectedParams, build.Block()))
+		testEqualAST(t, program.Statements[0], expected)
+	}
+
+	p := New(lexer.New("fn(x, y) { x + y; }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	expected := build.ExprStmt(build.Fn([]string{"x", "y"}, build.Block(
+		build.ExprStmt(build.Infix(build.Ident("x"), "+", build.Ident("y"))))))
+	testEqualAST(t, program.Statements[0], expected)
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	p := New(lexer.New("add(1, 2 * 3, 4 + 5);"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := build.ExprStmt(build.Call(build.Ident("add"),
+		build.Int(1),
+		build.Infix(build.Int(2), "*", build.Int(3)),
+		build.Infix(build.Int(4), "+", build.Int(5))))
+	testEqualAST(t, program.Statements[0], expected)
+}
+
+func TestFunctionAndCallErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"fn(x, 1) {}", "expected next token to be IDENT, found INT"},
+		{"fn(x y) {}", "expected next token to be ), found IDENT"},
+		{"add(1, 2", "expected next token to be ), found EOF"},
+		{"if x { y }", "expected next token to be (, found IDENT"},
+		// A return statement must have a value.
+		{"return;", "no prefix parse function for ; found"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("%q: expected first error %q, found %q", tt.input,
+				tt.expectedError, errors)
+		}
+	}
+}
+
+func TestArrayAndIndexParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ast.Expression
+	}{
+		{"[]", build.Array()},
+		{"[1, 2 * 2, 3 + 3]", build.Array(build.Int(1),
+			build.Infix(build.Int(2), "*", build.Int(2)),
+			build.Infix(build.Int(3), "+", build.Int(3)))},
+		{"myArray[1 + 1]", build.Index(build.Ident("myArray"),
+			build.Infix(build.Int(1), "+", build.Int(1)))},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		testEqualAST(t, program.Statements[0], build.ExprStmt(tt.expected))
+	}
+}
+
+func TestStringAndHashParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ast.Expression
+	}{
+		{`"hello world";`, build.Str("hello world")},
+		{"{}", build.Hash()},
+		{`{"one": 1, "two": 2}`, build.Hash(
+			build.Pair(build.Str("one"), build.Int(1)),
+			build.Pair(build.Str("two"), build.Int(2)))},
+		{`{1: "a" + "b", true: x * 2}`, build.Hash(
+			build.Pair(build.Int(1),
+				build.Infix(build.Str("a"), "+", build.Str("b"))),
+			build.Pair(build.Bool(true),
+				build.Infix(build.Ident("x"), "*", build.Int(2))))},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		testEqualAST(t, program.Statements[0], build.ExprStmt(tt.expected))
+	}
+}
+
+func TestHashLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`{"a" 1}`, "expected next token to be :, found INT"},
+		{`{"a": 1 "b": 2}`, "expected next token to be ,, found STRING"},
+		{`{"a": 1`, "expected next token to be ,, found EOF"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("%q: expected first error %q, found %q", tt.input,
+				tt.expectedError, errors)
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "(program (let x (type int) (int 5)))"},
+		{"let xs: [string] = [];", "(program (let xs (type [string]) (array)))"},
+		{"let h: {string: [int]} = {};",
+			"(program (let h (type {string: [int]}) (hash)))"},
+		{"fn(a: int, b) { a }",
+			"(program (expr (fn ((a (type int)) b) (block (expr (ident a))))))"},
+		{"fn(a, b: string): bool { true }",
+			"(program (expr (fn (a (b (type string))) (type bool) (block (expr (bool true))))))"},
+		{"let f: fn(int, fn(): null): {int: bool} = g;",
+			"(program (let f (type fn(int, fn(): null): {int: bool}) (ident g)))"},
+		{"fn(): [int] { [] }", "(program (expr (fn () (type [int]) (block (expr (array))))))"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if found := ast.SExpr(program); found != tt.expected {
+			t.Errorf("wrong tree for %q.\nexpected=%s\nfound=%s", tt.input,
+				tt.expected, found)
+		}
+	}
+
+	p := New(lexer.New("let f = fn(a: int, b): [int] { [a] }; let y: bool = f(1, 2);"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	expected := "let f = fn(a: int, b): [int] [a];let y: bool = f(1, 2);"
+	if found := program.String(); found != expected {
+		t.Errorf("wrong string. expected=%q, found=%q", expected, found)
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x: = 5;", "expected a type, found ="},
+		{"let x: int 5;", "expected next token to be =, found INT"},
+		{"let x: [int = [];", "expected next token to be ], found ="},
+		{"let h: {int} = {};", "expected next token to be :, found }"},
+		{"let f: fn(int) = g;", "expected next token to be :, found ="},
+		{"fn(a: 1) { a }", "expected a type, found INT"},
+		{"fn(a): { a }", "expected next token to be :, found }"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("%q: expected first error %q, found %q", tt.input,
+				tt.expectedError, errors)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package monkey
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/object"
+)
+
+var (
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	funcType   = reflect.TypeOf(Func(nil))
+)
+
+// RegisterFunc makes the Go function fn available to Monkey programs as a
+// builtin with the specified name. The name may be qualified by a module, as
+// in "os.getenv", in which case programs call it by the part after the dot,
+// and an evaluator.Sandbox can allow it by its module. RegisterFunc panics if
+// fn is not a function, if its results are not one of (), (T), (error) or
+// (T, error), or if a builtin of another module has the same name, since
+// programs couldn't tell them apart.
+//
+// When the builtin is called, its arguments are converted to the types of
+// fn's parameters, and its result is converted as by ToObject. If fn returns
+// a non-nil error, the builtin returns a Monkey error with the error's message.
+// Calls with the wrong number of arguments, or with arguments that cannot be
+// converted, also return Monkey errors. Arguments are converted as follows:
+//  - to an interface{} parameter, as by FromObject
+//  - to an object.Object parameter, not at all
+//  - to bool, string and integer parameters, from booleans, strings and
+//    integers that fit in the parameter's type
+//  - to slice and map parameters, from arrays and hashes whose elements can
+//    be converted to the parameter's element and key types
+//  - to a Func parameter, from a function or builtin
+//
+// A Func argument calls the Monkey function under the limits of the
+// evaluation calling the builtin, including its context and Sandbox, and
+// counts toward them, so it should only be called before fn returns. If the
+// evaluation is stopped during the call, the Func returns the
+// *evaluator.LimitError, and the evaluation stops once fn returns.
+//
+// Like evaluator.RegisterBuiltin, RegisterFunc is meant to be called during
+// initialization.
+func RegisterFunc(name string, fn interface{}) {
+	b := reflectBuiltin(name, fn)
+	if old, ok := evaluator.LookupBuiltin(b.Name); ok && old.Module != b.Module {
+		panic(fmt.Sprintf("monkey: RegisterFunc of %s conflicts with builtin %s",
+			name, qualifiedName(old)))
+	}
+	evaluator.RegisterBuiltin(b)
+}
+
+// qualifiedName returns the name of b qualified by its module, if it has one.
+func qualifiedName(b *object.Builtin) string {
+	if b.Module == "" {
+		return b.Name
+	}
+	return b.Module + "." + b.Name
+}
+
+func reflectBuiltin(qualified string, fn interface{}) *object.Builtin {
+	module, name := "", qualified
+	if i := strings.LastIndex(qualified, "."); i >= 0 {
+		module, name = qualified[:i], qualified[i+1:]
+	}
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	if ft.Kind() != reflect.Func {
+		panic(fmt.Sprintf("monkey: RegisterFunc of %s with non-function %T",
+			qualified, fn))
+	}
+	returnsError := ft.NumOut() > 0 && ft.Out(ft.NumOut()-1) == errorType
+	if ft.NumOut() > 2 || ft.NumOut() == 2 && !returnsError {
+		panic(fmt.Sprintf("monkey: RegisterFunc of %s with function of "+
+			"unsupported results %s", qualified, ft))
+	}
+
+	call := func(apply object.Applier, args ...object.Object) object.Object {
+		in, err := reflectArgs(name, ft, args, apply)
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		out := fv.Call(in)
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return evaluator.NULL
+		}
+		obj, err := ToObject(out[0].Interface())
+		if err != nil {
+			return &object.Error{
+				Message: fmt.Sprintf("result of `%s`: %s", name, err),
+			}
+		}
+		return obj
+	}
+	return &object.Builtin{
+		Name:   name,
+		Module: module,
+		Fn: func(args ...object.Object) object.Object {
+			return call(applyBuiltin, args...)
+		},
+		Apply: call,
+	}
+}
+
+// reflectArgs converts the arguments of a call of the builtin with the
+// specified name to the parameter types of ft. Functions converted to Funcs
+// are called with apply.
+func reflectArgs(name string, ft reflect.Type, args []object.Object,
+	apply object.Applier) ([]reflect.Value, error) {
+	numIn := ft.NumIn()
+	if ft.IsVariadic() {
+		if len(args) < numIn-1 {
+			return nil, fmt.Errorf("wrong number of arguments to `%s`: "+
+				"expected at least %d, found %d", name, numIn-1, len(args))
+		}
+	} else if len(args) != numIn {
+		return nil, fmt.Errorf("wrong number of arguments to `%s`: "+
+			"expected %d, found %d", name, numIn, len(args))
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var t reflect.Type
+		if ft.IsVariadic() && i >= numIn-1 {
+			t = ft.In(numIn - 1).Elem()
+		} else {
+			t = ft.In(i)
+		}
+		v, err := convertTo(arg, t, apply)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d to `%s`: %s", i+1, name, err)
+		}
+		in[i] = v
+	}
+	return in, nil
+}
+
+// convertTo converts obj to a value of type t, calling functions converted to
+// Funcs with apply.
+func convertTo(obj object.Object, t reflect.Type,
+	apply object.Applier) (reflect.Value, error) {
+	switch {
+	case t == objectType:
+		return reflect.ValueOf(&obj).Elem(), nil
+	case t == funcType:
+		switch obj.(type) {
+		case *object.Function, *object.Builtin:
+			return reflect.ValueOf(monkeyFunc(obj, apply)), nil
+		}
+	case t.Kind() == reflect.Interface && t.NumMethod() == 0:
+		v, err := fromObject(obj, apply)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if v == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(v), nil
+	}
+
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		if t.Kind() == reflect.Bool {
+			return reflect.ValueOf(obj.Value).Convert(t), nil
+		}
+	case *object.String:
+		if t.Kind() == reflect.String {
+			return reflect.ValueOf(obj.Value).Convert(t), nil
+		}
+	case *object.Integer:
+		return convertInteger(obj.Value, t)
+	case *object.Array:
+		if t.Kind() == reflect.Slice {
+			s := reflect.MakeSlice(t, len(obj.Elements), len(obj.Elements))
+			for i, e := range obj.Elements {
+				v, err := convertTo(e, t.Elem(), apply)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("element %d: %s", i, err)
+				}
+				s.Index(i).Set(v)
+			}
+			return s, nil
+		}
+	case *object.Hash:
+		if t.Kind() == reflect.Map {
+			m := reflect.MakeMapWithSize(t, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				k, err := convertTo(pair.Key, t.Key(), apply)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("key %s: %s",
+						pair.Key.Inspect(), err)
+				}
+				v, err := convertTo(pair.Value, t.Elem(), apply)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("value of %s: %s",
+						pair.Key.Inspect(), err)
+				}
+				m.SetMapIndex(k, v)
+			}
+			return m, nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+}
+
+// convertInteger converts i to a value of the integer type t, returning an
+// error if t is not an integer type or i does not fit in it.
+func convertInteger(i int64, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(i) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i, t)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i, t)
+		}
+		v.SetUint(uint64(i))
+	default:
+		return reflect.Value{}, fmt.Errorf("cannot use INTEGER as %s", t)
+	}
+	return v, nil
+}
@@ -0,0 +1,245 @@
+package monkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/object"
+)
+
+func init() {
+	RegisterFunc("testAdd", func(a, b int) int { return a + b })
+	RegisterFunc("testJoin", strings.Join)
+	RegisterFunc("testRepeat", strings.Repeat)
+	RegisterFunc("testByte", func(b uint8) uint8 { return b })
+	RegisterFunc("testSum", func(base int64, rest ...int64) int64 {
+		for _, r := range rest {
+			base += r
+		}
+		return base
+	})
+	RegisterFunc("testDivide", func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("cannot divide by zero")
+		}
+		return a / b, nil
+	})
+	RegisterFunc("testCheck", func(ok bool) error {
+		if !ok {
+			return errors.New("check failed")
+		}
+		return nil
+	})
+	RegisterFunc("testNothing", func() {})
+	RegisterFunc("testKeys", func(m map[string]int) int { return len(m) })
+	RegisterFunc("testAny", func(v interface{}) string {
+		return reflect.TypeOf(v).String()
+	})
+	RegisterFunc("testType", func(obj object.Object) string {
+		return string(obj.Type())
+	})
+	RegisterFunc("testApply", func(f Func, x int) (interface{}, error) {
+		return f(x)
+	})
+	RegisterFunc("testFloat", func() float64 { return 1.5 })
+	RegisterFunc("testsecrets.secret", func() string { return "hunter2" })
+}
+
+func TestRegisterFunc(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"testAdd(1, 2)", int64(3)},
+		{`testJoin(["a", "b"], "-")`, "a-b"},
+		{`testRepeat("ab", 3)`, "ababab"},
+		{"testByte(255)", int64(255)},
+		{"testSum(1)", int64(1)},
+		{"testSum(1, 2, 3)", int64(6)},
+		{"testDivide(7, 2)", int64(3)},
+		{"testCheck(true)", nil},
+		{"testNothing()", nil},
+		{`testKeys({"a": 1, "b": 2})`, int64(2)},
+		{"testAny([1])", "[]interface {}"},
+		{`testAny({"a": true})`, "map[string]interface {}"},
+		{"testType(fn() {})", "FUNCTION"},
+		{"testApply(fn(x) { x * 2 }, 21)", int64(42)},
+		{"let testAdd = fn(a, b) { a - b }; testAdd(1, 2)", int64(-1)},
+	}
+
+	for _, tt := range tests {
+		result, err := Eval(context.Background(), tt.input, Options{})
+		if err != nil {
+			t.Errorf("Eval(%q) failed: %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Eval(%q) wrong. expected=%#v, found=%#v", tt.input,
+				tt.expected, result)
+		}
+	}
+}
+
+func TestRegisterFuncErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedErr string
+	}{
+		{"testAdd(1)", "1:1: wrong number of arguments to `testAdd`: " +
+			"expected 2, found 1"},
+		{"testSum()", "1:1: wrong number of arguments to `testSum`: " +
+			"expected at least 1, found 0"},
+		{`testAdd(1, "2")`, "1:1: argument 2 to `testAdd`: " +
+			"cannot use STRING as int"},
+		{"testByte(256)", "1:1: argument 1 to `testByte`: 256 overflows uint8"},
+		{"testByte(-1)", "1:1: argument 1 to `testByte`: -1 overflows uint8"},
+		{`testSum(1, 2, true)`, "1:1: argument 3 to `testSum`: " +
+			"cannot use BOOLEAN as int64"},
+		{`testJoin([1], "")`, "1:1: argument 1 to `testJoin`: " +
+			"element 0: cannot use INTEGER as string"},
+		{`testKeys({1: 1})`, "1:1: argument 1 to `testKeys`: " +
+			"key 1: cannot use INTEGER as string"},
+		{"testApply(1, 2)", "1:1: argument 1 to `testApply`: " +
+			"cannot use INTEGER as monkey.Func"},
+		{"testDivide(1, 0)", "1:1: cannot divide by zero"},
+		{"testCheck(false)", "1:1: check failed"},
+		{"testFloat()", "1:1: result of `testFloat`: " +
+			"cannot convert float64 to a Monkey value"},
+	}
+
+	for _, tt := range tests {
+		_, err := Eval(context.Background(), tt.input, Options{})
+		if err == nil {
+			t.Errorf("Eval(%q) succeeded, expected error %q", tt.input,
+				tt.expectedErr)
+			continue
+		}
+		if err.Error() != tt.expectedErr {
+			t.Errorf("Eval(%q) wrong error. expected=%q, found=%q", tt.input,
+				tt.expectedErr, err.Error())
+		}
+	}
+}
+
+func TestRegisterFuncSandbox(t *testing.T) {
+	tests := []struct {
+		sandbox     *evaluator.Sandbox
+		expectedErr string
+	}{
+		{nil, ""},
+		{&evaluator.Sandbox{Allow: []string{"testsecrets"}}, ""},
+		{&evaluator.Sandbox{Allow: []string{"testsecrets.secret"}}, ""},
+		{&evaluator.Sandbox{Allow: []string{"secret"}},
+			"1:1: permission denied: testsecrets.secret is not allowed"},
+	}
+
+	for _, tt := range tests {
+		result, err := Eval(context.Background(), "secret()", Options{
+			Limits: evaluator.Limits{Sandbox: tt.sandbox},
+		})
+		if tt.expectedErr == "" {
+			if err != nil || result != "hunter2" {
+				t.Errorf("sandbox %+v: expected %q, found %v, %v", tt.sandbox,
+					"hunter2", result, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.expectedErr {
+			t.Errorf("sandbox %+v: expected error %q, found %v", tt.sandbox,
+				tt.expectedErr, err)
+		}
+	}
+}
+
+func TestRegisterFuncCallbackLimits(t *testing.T) {
+	f, err := ioutil.TempFile("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("secret")
+	f.Close()
+
+	readFile := fmt.Sprintf("readFile(%q)", f.Name())
+	goApply := Func(func(args ...interface{}) (interface{}, error) {
+		return args[0].(Func)()
+	})
+	sandbox := &evaluator.Sandbox{Allow: []string{"core", "testApply"}}
+	tests := []struct {
+		input       string
+		limits      evaluator.Limits
+		expectedErr string
+	}{
+		{"testApply(fn(x) { " + readFile + " }, 1)",
+			evaluator.Limits{Sandbox: sandbox},
+			"permission denied: io.readFile is not allowed"},
+		{"goApply(fn() { " + readFile + " })",
+			evaluator.Limits{Sandbox: sandbox},
+			"permission denied: io.readFile is not allowed"},
+		{"testApply(fn(x) { goApply(fn() { " + readFile + " }) }, 1)",
+			evaluator.Limits{Sandbox: sandbox},
+			"permission denied: io.readFile is not allowed"},
+		{`let loop = fn(n) { if (n > 0) { loop(n - 1) } };
+testApply(fn(x) { loop(x) }, 5000)`,
+			evaluator.Limits{MaxSteps: 100},
+			"maximum number of steps (100) exceeded"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } };
+testApply(f, 20)`,
+			evaluator.Limits{MaxDepth: 10}, "maximum call depth (10) exceeded"},
+		{`let loop = fn(n) { if (n > 0) { loop(n - 1) } };
+goApply(fn() { loop(5000) })`,
+			evaluator.Limits{MaxObjects: 100},
+			"maximum number of objects (100) exceeded"},
+	}
+
+	for _, tt := range tests {
+		result, err := Eval(context.Background(), tt.input, Options{
+			Globals: map[string]interface{}{"goApply": goApply},
+			Limits:  tt.limits,
+		})
+		if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+			t.Errorf("Eval(%q) expected error %q, found %v, %v", tt.input,
+				tt.expectedErr, result, err)
+		}
+	}
+}
+
+func TestRegisterFuncPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   interface{}
+	}{
+		{"not a function", 42},
+		{"too many results", func() (int, int, error) { return 0, 0, nil }},
+		{"second result not error", func() (int, int) { return 0, 0 }},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", tt.name)
+				}
+			}()
+			RegisterFunc("testPanic", tt.fn)
+		}()
+	}
+}
+
+func TestRegisterFuncClash(t *testing.T) {
+	RegisterFunc("testa.testGetenv", func() string { return "a" })
+	RegisterFunc("testa.testGetenv", func() string { return "a" })
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	RegisterFunc("testb.testGetenv", func() string { return "b" })
+}
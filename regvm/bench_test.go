@@ -0,0 +1,98 @@
+package regvm
+
+import (
+	"testing"
+
+	"github.com/adamvinueza/monkey/compiler"
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/vm"
+)
+
+// benchmarks are programs to compare the engines with.
+var benchmarks = []struct {
+	name  string
+	input string
+}{
+	{"fib", `
+let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) };
+fib(20)`},
+	{"sort", `
+let random = fn(n, seed, acc) {
+  if (n == 0) { return acc; }
+  let next = (seed * 1103515245 + 12345) / 65536;
+  let value = next - next / 1000 * 1000;
+  random(n - 1, next - next / 2147483648 * 2147483648, push(acc, value))
+};
+let insert = fn(sorted, x) {
+  let iter = fn(xs, acc) {
+    if (len(xs) == 0) { return push(acc, x); }
+    if (x < first(xs)) {
+      let done = fn(xs, acc) {
+        if (len(xs) == 0) { acc } else { done(rest(xs), push(acc, first(xs))) }
+      };
+      return done(xs, push(acc, x));
+    }
+    iter(rest(xs), push(acc, first(xs)))
+  };
+  iter(sorted, [])
+};
+let sort = fn(arr) {
+  let iter = fn(arr, acc) {
+    if (len(arr) == 0) { acc } else { iter(rest(arr), insert(acc, first(arr))) }
+  };
+  iter(arr, [])
+};
+sort(random(100, 42, []))`},
+	{"strings", `
+let digits = ["0", "1", "2", "3", "4", "5", "6", "7", "8", "9"];
+let itoa = fn(n) {
+  if (n < 10) { return digits[n]; }
+  itoa(n / 10) + digits[n - n / 10 * 10]
+};
+let join = fn(n, acc) {
+  if (n == 0) { return acc; }
+  join(n - 1, acc + itoa(n) + ",")
+};
+len(join(1000, ""))`},
+}
+
+// BenchmarkEngines times each program in the evaluator, in the stack machine
+// of package vm and in the register machine.
+func BenchmarkEngines(b *testing.B) {
+	for _, bm := range benchmarks {
+		program := parse(bm.input)
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			b.Fatalf("compiler error: %s", err)
+		}
+		bytecode := c.Bytecode()
+		compiled, err := Compile(program)
+		if err != nil {
+			b.Fatalf("compiler error: %s", err)
+		}
+
+		b.Run(bm.name+"/evaluator", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				result := evaluator.Eval(program, object.NewEnvironment())
+				if err, ok := result.(*object.Error); ok {
+					b.Fatalf("evaluator error: %s", err.Message)
+				}
+			}
+		})
+		b.Run(bm.name+"/vm", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				if err := vm.New(bytecode).Run(); err != nil {
+					b.Fatalf("vm error: %s", err)
+				}
+			}
+		})
+		b.Run(bm.name+"/regvm", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				if err := New(compiled).Run(); err != nil {
+					b.Fatalf("regvm error: %s", err)
+				}
+			}
+		})
+	}
+}
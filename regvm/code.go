@@ -0,0 +1,204 @@
+package regvm
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Instruction is an instruction of the register machine: an opcode and three
+// operands, A, B and C, whose meanings depend on the opcode. Most instructions
+// put their result in register A.
+type Instruction struct {
+	Op      Opcode
+	A, B, C uint16
+}
+
+// constBit marks an operand of OpAdd through OpLessThan as the index of a
+// constant rather than of a register.
+const constBit = 1 << 15
+
+// Opcode identifies the operation an instruction performs. R[n] is register
+// n of the current call, K[n] constant n of the current function and G[n]
+// global variable n.
+type Opcode byte
+
+const (
+	// OpLoadConst sets R[A] to K[B].
+	OpLoadConst Opcode = iota
+	// OpLoadTrue, OpLoadFalse and OpLoadNull set R[A] to true, false and
+	// null.
+	OpLoadTrue
+	OpLoadFalse
+	OpLoadNull
+	// OpMove sets R[A] to R[B].
+	OpMove
+	// OpCheck fails unless the variable in R[A] has been assigned.
+	OpCheck
+
+	// OpGetGlobal sets R[A] to G[B], failing if it hasn't been assigned,
+	// and OpSetGlobal sets G[B] to R[A].
+	OpGetGlobal
+	OpSetGlobal
+	// OpGetCell sets R[A] to cell B of the current call, failing if it
+	// hasn't been assigned, and OpSetCell sets cell B to R[A]. Cells hold
+	// the variables the functions defined in a call refer to.
+	OpGetCell
+	OpSetCell
+	// OpGetFree sets R[A] to cell C of a call enclosing the definition of
+	// the current function, B counting from 0 for the innermost, failing if
+	// it hasn't been assigned.
+	OpGetFree
+	// OpGetBuiltin sets R[A] to the builtin whose name has index B in the
+	// program's list of builtin names.
+	OpGetBuiltin
+
+	// OpAdd, OpSub, OpMul, OpDiv, OpEqual, OpNotEqual, OpGreaterThan and
+	// OpLessThan set R[A] to the result of applying the operator to B and C,
+	// each a register or, with constBit set, a constant.
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	// OpMinus and OpBang set R[A] to the result of applying the prefix
+	// operator to R[B].
+	OpMinus
+	OpBang
+
+	// OpJump jumps to the instruction at offset B. OpJumpNotTruthy does so
+	// only if R[A] is not truthy.
+	OpJump
+	OpJumpNotTruthy
+
+	// OpArray sets R[A] to an array of the C registers from R[B]. OpHash
+	// sets R[A] to a hash of them, alternating between keys and values.
+	OpArray
+	OpHash
+	// OpHashKey fails unless R[A] can be a hash key.
+	OpHashKey
+	// OpIndex sets R[A] to the element of R[B] at index R[C].
+	OpIndex
+
+	// OpClosure sets R[A] to a closure of function B of the current
+	// function.
+	OpClosure
+	// OpCall calls the function in R[A] with the B arguments following it,
+	// setting R[A] to the result. The registers of the call start after
+	// R[A], so that the arguments are its first registers. OpTailCall does
+	// the same, but in place of the current call, returning its result.
+	OpCall
+	OpTailCall
+	// OpReturn returns R[A] from the current call. OpReturnNull returns
+	// null, or, from the top level of the program, no value.
+	OpReturn
+	OpReturnNull
+)
+
+var opcodeNames = [...]string{
+	OpLoadConst:     "OpLoadConst",
+	OpLoadTrue:      "OpLoadTrue",
+	OpLoadFalse:     "OpLoadFalse",
+	OpLoadNull:      "OpLoadNull",
+	OpMove:          "OpMove",
+	OpCheck:         "OpCheck",
+	OpGetGlobal:     "OpGetGlobal",
+	OpSetGlobal:     "OpSetGlobal",
+	OpGetCell:       "OpGetCell",
+	OpSetCell:       "OpSetCell",
+	OpGetFree:       "OpGetFree",
+	OpGetBuiltin:    "OpGetBuiltin",
+	OpAdd:           "OpAdd",
+	OpSub:           "OpSub",
+	OpMul:           "OpMul",
+	OpDiv:           "OpDiv",
+	OpEqual:         "OpEqual",
+	OpNotEqual:      "OpNotEqual",
+	OpGreaterThan:   "OpGreaterThan",
+	OpLessThan:      "OpLessThan",
+	OpMinus:         "OpMinus",
+	OpBang:          "OpBang",
+	OpJump:          "OpJump",
+	OpJumpNotTruthy: "OpJumpNotTruthy",
+	OpArray:         "OpArray",
+	OpHash:          "OpHash",
+	OpHashKey:       "OpHashKey",
+	OpIndex:         "OpIndex",
+	OpClosure:       "OpClosure",
+	OpCall:          "OpCall",
+	OpTailCall:      "OpTailCall",
+	OpReturn:        "OpReturn",
+	OpReturnNull:    "OpReturnNull",
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opcodeNames) {
+		return opcodeNames[op]
+	}
+	return fmt.Sprintf("Opcode(%d)", op)
+}
+
+// operandCounts holds the number of operands each opcode uses.
+var operandCounts = [...]int{
+	OpLoadConst: 2, OpLoadTrue: 1, OpLoadFalse: 1, OpLoadNull: 1, OpMove: 2,
+	OpCheck: 1, OpGetGlobal: 2, OpSetGlobal: 2, OpGetCell: 2, OpSetCell: 2,
+	OpGetFree: 3, OpGetBuiltin: 2, OpAdd: 3, OpSub: 3, OpMul: 3, OpDiv: 3,
+	OpEqual: 3, OpNotEqual: 3, OpGreaterThan: 3, OpLessThan: 3, OpMinus: 2,
+	OpBang: 2, OpJump: 0, OpJumpNotTruthy: 1, OpArray: 3, OpHash: 3,
+	OpHashKey: 1, OpIndex: 3, OpClosure: 2, OpCall: 2, OpTailCall: 2,
+	OpReturn: 1, OpReturnNull: 0,
+}
+
+// String returns the instruction in the form of a line of a listing, such as
+// "OpAdd r2 r0 k1". Registers are written with the prefix "r", constants
+// with "k", and the targets of jumps with "@".
+func (in Instruction) String() string {
+	var out bytes.Buffer
+	out.WriteString(in.Op.String())
+	operands := []uint16{in.A, in.B, in.C}
+	n := 0
+	if int(in.Op) < len(operandCounts) {
+		n = operandCounts[in.Op]
+	}
+	for i, o := range operands[:n] {
+		out.WriteString(" " + operand(in.Op, i, o))
+	}
+	if in.Op == OpJump || in.Op == OpJumpNotTruthy {
+		fmt.Fprintf(&out, " @%d", in.B)
+	}
+	return out.String()
+}
+
+// operand formats operand i of an instruction with opcode op.
+func operand(op Opcode, i int, o uint16) string {
+	switch {
+	case i == 0:
+		return fmt.Sprintf("r%d", o)
+	case op >= OpAdd && op <= OpLessThan && o&constBit != 0:
+		return fmt.Sprintf("k%d", o&^constBit)
+	case op == OpLoadConst:
+		return fmt.Sprintf("k%d", o)
+	case op == OpMove || op == OpMinus || op == OpBang || op == OpIndex ||
+		op == OpArray && i == 1 || op == OpHash && i == 1 ||
+		op >= OpAdd && op <= OpLessThan:
+		return fmt.Sprintf("r%d", o)
+	}
+	return fmt.Sprint(o)
+}
+
+// Instructions is a sequence of instructions.
+type Instructions []Instruction
+
+// String returns a listing of the instructions, one per line, each prefixed by
+// its offset. For example:
+//  0000 OpLoadConst r0 k0
+//  0001 OpReturn r0
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+	for i, in := range ins {
+		fmt.Fprintf(&out, "%04d %s\n", i, in)
+	}
+	return out.String()
+}
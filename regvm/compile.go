@@ -0,0 +1,710 @@
+package regvm
+
+import (
+	"fmt"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/code"
+	"github.com/adamvinueza/monkey/compiler"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// Limits on the sizes of programs imposed by the widths of operands.
+const (
+	maxRegisters    = constBit
+	maxConstants    = constBit
+	maxOperand      = 1 << 16
+	maxInstructions = 1 << 16
+)
+
+// Program is a program compiled for the register machine.
+type Program struct {
+	// Main is the top level of the program, which returns the value of the
+	// last statement, if it is an expression statement.
+	Main *Function
+	// Globals holds the names of the program's global variables, by index.
+	Globals []string
+	// Builtins holds the names of the builtins the program uses, by index.
+	Builtins []string
+}
+
+// Function is a compiled function, or the top level of a program.
+type Function struct {
+	Name          string // the name the function is bound to, if any
+	NumParameters int
+	// NumRegisters is the number of registers a call of the function uses:
+	// its parameters, its other variables held in registers, and the
+	// registers holding intermediate results.
+	NumRegisters int
+	// Registers holds the names of the variables held in registers, by
+	// register. The parameters come first.
+	Registers []string
+	// Cells holds the names of the variables held in cells, by cell, which
+	// are those the functions defined in the function may refer to.
+	Cells        []string
+	Instructions Instructions
+	Constants    []object.Object
+	// Functions holds the functions defined in the function, by index.
+	Functions []*Function
+	// Lines gives the positions in the source text of the instructions.
+	Lines code.LineTable
+}
+
+// Compile compiles program for the register machine. It returns an error if
+// the program is too large for the instruction set, or holds a node that
+// can't be compiled.
+func Compile(program *ast.Program) (*Program, error) {
+	c := &compilation{}
+	symbols := compiler.NewSymbolTable()
+	f := c.enter(&Function{}, symbols, program.Statements)
+	f.body(program.Statements)
+	c.leave()
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &Program{
+		Main:     f.fn,
+		Globals:  symbols.Names(),
+		Builtins: symbols.BuiltinNames(),
+	}, nil
+}
+
+// compilation holds the state of the compilation of a program.
+type compilation struct {
+	functions []*function // the functions being compiled, innermost last
+	pos       token.Position
+	err       error // the first error, which stops compilation
+}
+
+// fail records an error, at the position of the node being compiled.
+func (c *compilation) fail(format string, a ...interface{}) {
+	if c.err == nil {
+		c.err = fmt.Errorf("%s: %s", c.pos, fmt.Sprintf(format, a...))
+	}
+}
+
+// enter starts compiling fn, whose body is body.
+func (c *compilation) enter(fn *Function, symbols *compiler.SymbolTable,
+	body []ast.Statement) *function {
+	f := &function{
+		c:        c,
+		fn:       fn,
+		symbols:  symbols,
+		captured: map[string]bool{},
+		assigned: map[int]bool{},
+	}
+	for _, s := range body {
+		captures(f.captured, s)
+	}
+	c.functions = append(c.functions, f)
+	return f
+}
+
+func (c *compilation) leave() {
+	c.functions = c.functions[:len(c.functions)-1]
+}
+
+// location is where a variable is held: in a register, or in a cell.
+type location struct {
+	cell  bool
+	index int
+}
+
+// function holds the state of the compilation of a function.
+type function struct {
+	c       *compilation
+	fn      *Function
+	symbols *compiler.SymbolTable
+	// captured holds the names the functions defined in the function refer
+	// to. Its variables with those names are held in cells.
+	captured  map[string]bool
+	locations []location // by symbol index
+	// assigned holds the registers of variables that have been assigned
+	// wherever the instruction being compiled runs.
+	assigned map[int]bool
+	next     int // the first free register
+	target   int // the offset of the last instruction jumped to
+}
+
+// main reports whether f is the top level of the program, whose variables are
+// global and which makes no calls in place of its own.
+func (f *function) main() bool {
+	return f.c.functions[0] == f
+}
+
+// at sets the position of the node being compiled to that of node, returning
+// a function that restores it.
+func (f *function) at(node ast.Node) func() {
+	outer := f.c.pos
+	if pos := ast.Pos(node); pos.IsValid() {
+		f.c.pos = pos
+	}
+	return func() { f.c.pos = outer }
+}
+
+// emit adds an instruction, returning its offset.
+func (f *function) emit(op Opcode, a, b, cc int) int {
+	for _, o := range []int{a, b, cc} {
+		if o < 0 || o >= maxOperand {
+			f.c.fail("operand out of range: %d", o)
+		}
+	}
+	ins := f.fn.Instructions
+	if len(ins) >= maxInstructions {
+		f.c.fail("too many instructions in function: %d", len(ins)+1)
+	}
+	f.fn.Instructions = append(ins, Instruction{Op: op, A: uint16(a),
+		B: uint16(b), C: uint16(cc)})
+	lines := f.fn.Lines
+	if len(lines) == 0 || lines[len(lines)-1].Pos != f.c.pos {
+		f.fn.Lines = append(lines, code.LineEntry{Offset: len(ins),
+			Pos: f.c.pos})
+	}
+	return len(ins)
+}
+
+// patch makes the jump at offset jump go to the next instruction.
+func (f *function) patch(jump int) {
+	f.fn.Instructions[jump].B = uint16(len(f.fn.Instructions))
+	f.target = len(f.fn.Instructions)
+}
+
+// temp reserves n consecutive registers, returning the first. Registers are
+// reserved in order and released at the end of each statement, so the
+// registers after those reserved are free, and a call can use them.
+func (f *function) temp(n int) int {
+	r := f.next
+	f.next += n
+	if f.next > maxRegisters {
+		f.c.fail("too many registers in function: %d", f.next)
+	}
+	if f.next > f.fn.NumRegisters {
+		f.fn.NumRegisters = f.next
+	}
+	return r
+}
+
+// dest returns dst, or a new register if dst is -1.
+func (f *function) dest(dst int) int {
+	if dst < 0 {
+		return f.temp(1)
+	}
+	return dst
+}
+
+// constant returns the index of a new constant holding obj.
+func (f *function) constant(obj object.Object) int {
+	if len(f.fn.Constants) >= maxConstants {
+		f.c.fail("too many constants in function: %d",
+			len(f.fn.Constants)+1)
+	}
+	f.fn.Constants = append(f.fn.Constants, obj)
+	return len(f.fn.Constants) - 1
+}
+
+// locate gives each of the variables of the function a location. Parameters
+// come first, in the registers calls put the arguments in.
+func (f *function) locate() {
+	for i, name := range f.symbols.Names() {
+		loc := location{cell: f.captured[name]}
+		if loc.cell {
+			loc.index = len(f.fn.Cells)
+			f.fn.Cells = append(f.fn.Cells, name)
+		}
+		if !loc.cell || i < f.fn.NumParameters {
+			// A captured parameter is copied from its register to its
+			// cell when the call starts.
+			r := f.temp(1)
+			f.fn.Registers = append(f.fn.Registers, name)
+			if !loc.cell {
+				loc.index = r
+			}
+		}
+		f.locations = append(f.locations, loc)
+	}
+}
+
+// body compiles the statements of a function body or of the top level of a
+// program, which returns the value of its last statement.
+func (f *function) body(stmts []ast.Statement) {
+	for _, s := range stmts {
+		declare(f.symbols, s)
+	}
+	if !f.main() {
+		f.locate()
+	}
+	for i := 0; i < f.fn.NumParameters; i++ {
+		if loc := f.locations[i]; loc.cell {
+			f.emit(OpSetCell, i, loc.index, 0)
+		}
+		f.assigned[i] = true
+	}
+	f.returnBlock(stmts, true)
+}
+
+// returnBlock compiles stmts, returning the value of the last statement. If
+// body is true, the statements are the body of a function or program, which
+// returns no value unless the last is an expression statement; otherwise
+// they are a block, whose value is then null.
+func (f *function) returnBlock(stmts []ast.Statement, body bool) {
+	for n, s := range stmts {
+		if es, ok := s.(*ast.ExpressionStatement); ok && n == len(stmts)-1 {
+			restore := f.at(es)
+			f.ret(es.Expression)
+			restore()
+			return
+		}
+		f.statement(s)
+		if _, ok := s.(*ast.ReturnStatement); ok && n == len(stmts)-1 {
+			return
+		}
+	}
+	if body {
+		f.emit(OpReturnNull, 0, 0, 0)
+		return
+	}
+	f.returnNull()
+}
+
+// returnNull compiles the return of null.
+func (f *function) returnNull() {
+	r := f.temp(1)
+	f.emit(OpLoadNull, r, 0, 0)
+	f.emit(OpReturn, r, 0, 0)
+}
+
+// ret compiles the return of the value of expr. In a function, a call whose
+// value is returned, directly or from a branch of an if expression, is made
+// in place of the current call.
+func (f *function) ret(expr ast.Expression) {
+	defer f.at(expr)()
+	mark := f.next
+	defer func() { f.next = mark }()
+	switch expr := expr.(type) {
+	case *ast.CallExpression:
+		if !f.main() {
+			f.call(expr, OpTailCall)
+			return
+		}
+	case *ast.IfExpression:
+		f.branches(expr, func(block *ast.BlockStatement) {
+			f.returnBlock(block.Statements, false)
+		}, f.returnNull)
+		return
+	}
+	f.emit(OpReturn, f.expression(expr, -1), 0, 0)
+}
+
+// statement compiles s, discarding its value.
+func (f *function) statement(s ast.Statement) {
+	defer f.at(s)()
+	mark := f.next
+	defer func() { f.next = mark }()
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		f.let(s)
+	case *ast.ReturnStatement:
+		f.ret(s.ReturnValue)
+	case *ast.ExpressionStatement:
+		if expr, ok := s.Expression.(*ast.IfExpression); ok {
+			// The value of the if expression isn't needed.
+			defer f.at(expr)()
+			f.branches(expr, func(block *ast.BlockStatement) {
+				for _, s := range block.Statements {
+					f.statement(s)
+				}
+			}, func() {})
+			break
+		}
+		f.expression(s.Expression, -1)
+	default:
+		f.c.fail("can't compile %T", s)
+	}
+}
+
+// let compiles a let statement, assigning the value to its variable.
+func (f *function) let(s *ast.LetStatement) {
+	value := func(dst int) int {
+		if fl, ok := s.Value.(*ast.FunctionLiteral); ok {
+			// A function is named by the let statement defining it.
+			defer f.at(fl)()
+			return f.closure(fl, s.Name.Value, dst)
+		}
+		return f.expression(s.Value, dst)
+	}
+	symbol := f.symbols.Define(s.Name.Value)
+	if symbol.Scope == compiler.GlobalScope {
+		f.emit(OpSetGlobal, value(-1), symbol.Index, 0)
+		return
+	}
+	loc := f.locations[symbol.Index]
+	if loc.cell {
+		f.emit(OpSetCell, value(-1), loc.index, 0)
+		return
+	}
+	value(loc.index)
+	f.assigned[loc.index] = true
+}
+
+// block compiles stmts, putting the value of the last statement, or null if
+// it isn't an expression statement, in register dst.
+func (f *function) block(stmts []ast.Statement, dst int) {
+	for n, s := range stmts {
+		if es, ok := s.(*ast.ExpressionStatement); ok && n == len(stmts)-1 {
+			restore := f.at(es)
+			f.expression(es.Expression, dst)
+			restore()
+			return
+		}
+		f.statement(s)
+	}
+	if !f.returned() {
+		f.emit(OpLoadNull, dst, 0, 0)
+	}
+}
+
+// branches compiles an if expression, compiling its blocks with block and,
+// if it has no alternative, the value of the missing alternative with none.
+func (f *function) branches(expr *ast.IfExpression,
+	block func(*ast.BlockStatement), none func()) {
+	cond := f.expression(expr.Condition, -1)
+	jump := f.emit(OpJumpNotTruthy, cond, 0, 0)
+	// Variables assigned in one branch may not be in the other, or after
+	// the if expression.
+	assigned := f.assigned
+	f.assigned = copyAssigned(assigned)
+	block(expr.Consequence)
+	end := -1
+	if !f.returned() {
+		end = f.emit(OpJump, 0, 0, 0)
+	}
+	f.patch(jump)
+	f.assigned = copyAssigned(assigned)
+	if expr.Alternative != nil {
+		block(expr.Alternative)
+	} else {
+		none()
+	}
+	f.assigned = assigned
+	if end >= 0 {
+		f.patch(end)
+	}
+}
+
+// returned reports whether the last instruction compiled ends the call, and
+// no jump goes past it, so that the next instruction can't be reached.
+func (f *function) returned() bool {
+	ins := f.fn.Instructions
+	if len(ins) == 0 || f.target == len(ins) {
+		return false
+	}
+	switch ins[len(ins)-1].Op {
+	case OpReturn, OpReturnNull, OpTailCall:
+		return true
+	}
+	return false
+}
+
+func copyAssigned(assigned map[int]bool) map[int]bool {
+	c := make(map[int]bool, len(assigned))
+	for r := range assigned {
+		c[r] = true
+	}
+	return c
+}
+
+// expression compiles expr, returning the register holding its value: dst,
+// unless dst is -1, in which case it is any register.
+func (f *function) expression(expr ast.Expression, dst int) int {
+	defer f.at(expr)()
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		return f.identifier(expr, dst)
+
+	case *ast.IntegerLiteral:
+		dst = f.dest(dst)
+		f.emit(OpLoadConst, dst,
+			f.constant(&object.Integer{Value: expr.Value}), 0)
+
+	case *ast.StringLiteral:
+		dst = f.dest(dst)
+		f.emit(OpLoadConst, dst, f.constant(&object.String{Value: expr.Value}),
+			0)
+
+	case *ast.Boolean:
+		dst = f.dest(dst)
+		if expr.Value {
+			f.emit(OpLoadTrue, dst, 0, 0)
+		} else {
+			f.emit(OpLoadFalse, dst, 0, 0)
+		}
+
+	case *ast.PrefixExpression:
+		right := f.expression(expr.Right, -1)
+		dst = f.dest(dst)
+		switch expr.Operator {
+		case "!":
+			f.emit(OpBang, dst, right, 0)
+		case "-":
+			f.emit(OpMinus, dst, right, 0)
+		default:
+			f.c.fail("unknown operator %s", expr.Operator)
+		}
+
+	case *ast.InfixExpression:
+		op, ok := infixOpcodes[expr.Operator]
+		if !ok {
+			f.c.fail("unknown operator %s", expr.Operator)
+		}
+		left := f.operand(expr.Left, hasLet(expr.Right))
+		right := f.operand(expr.Right, false)
+		dst = f.dest(dst)
+		f.emit(op, dst, left, right)
+
+	case *ast.IfExpression:
+		dst = f.dest(dst)
+		f.branches(expr, func(block *ast.BlockStatement) {
+			f.block(block.Statements, dst)
+		}, func() {
+			f.emit(OpLoadNull, dst, 0, 0)
+		})
+
+	case *ast.FunctionLiteral:
+		return f.closure(expr, "", dst)
+
+	case *ast.CallExpression:
+		r := f.call(expr, OpCall)
+		if dst < 0 {
+			return r
+		}
+		f.emit(OpMove, dst, r, 0)
+
+	case *ast.ArrayLiteral:
+		base := f.temp(len(expr.Elements))
+		for i, el := range expr.Elements {
+			f.expression(el, base+i)
+		}
+		dst = f.dest(dst)
+		f.emit(OpArray, dst, base, len(expr.Elements))
+
+	case *ast.HashLiteral:
+		base := f.temp(2 * len(expr.Pairs))
+		for i, pair := range expr.Pairs {
+			// An unusable key is reported before the value paired with
+			// it is evaluated, as in the evaluator.
+			f.expression(pair.Key, base+2*i)
+			f.emit(OpHashKey, base+2*i, 0, 0)
+			f.expression(pair.Value, base+2*i+1)
+		}
+		dst = f.dest(dst)
+		f.emit(OpHash, dst, base, 2*len(expr.Pairs))
+
+	case *ast.IndexExpression:
+		left := f.register(expr.Left, hasLet(expr.Index))
+		index := f.expression(expr.Index, -1)
+		dst = f.dest(dst)
+		f.emit(OpIndex, dst, left, index)
+
+	default:
+		f.c.fail("can't compile %T", expr)
+		return f.dest(dst)
+	}
+	return dst
+}
+
+var infixOpcodes = map[string]Opcode{
+	"+":  OpAdd,
+	"-":  OpSub,
+	"*":  OpMul,
+	"/":  OpDiv,
+	"==": OpEqual,
+	"!=": OpNotEqual,
+	">":  OpGreaterThan,
+	"<":  OpLessThan,
+}
+
+// register compiles expr, returning the register holding its value. If copy
+// is true, the value is copied to a new register if it is a variable's, for
+// the variable may be assigned before the value is used.
+func (f *function) register(expr ast.Expression, copy bool) int {
+	if copy {
+		return f.expression(expr, f.temp(1))
+	}
+	return f.expression(expr, -1)
+}
+
+// operand is like register, but compiles an integer or string literal to a
+// constant, returning its index with constBit set.
+func (f *function) operand(expr ast.Expression, copy bool) int {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return f.constant(&object.Integer{Value: expr.Value}) | constBit
+	case *ast.StringLiteral:
+		return f.constant(&object.String{Value: expr.Value}) | constBit
+	}
+	return f.register(expr, copy)
+}
+
+// identifier compiles a reference to a variable or builtin, which fails if
+// the variable hasn't been assigned.
+func (f *function) identifier(ident *ast.Identifier, dst int) int {
+	symbol, ok := f.symbols.Resolve(ident.Value)
+	if !ok {
+		symbol = f.symbols.DefineBuiltin(ident.Value)
+	}
+	switch symbol.Scope {
+	case compiler.GlobalScope:
+		dst = f.dest(dst)
+		f.emit(OpGetGlobal, dst, symbol.Index, 0)
+	case compiler.LocalScope:
+		loc := f.locations[symbol.Index]
+		if loc.cell {
+			dst = f.dest(dst)
+			f.emit(OpGetCell, dst, loc.index, 0)
+			break
+		}
+		if !f.assigned[loc.index] {
+			f.emit(OpCheck, loc.index, 0, 0)
+			f.assigned[loc.index] = true
+		}
+		if dst < 0 {
+			return loc.index
+		}
+		if dst != loc.index {
+			f.emit(OpMove, dst, loc.index, 0)
+		}
+	case compiler.FreeScope:
+		outer := f.c.functions[len(f.c.functions)-2-symbol.Depth]
+		loc := outer.locations[symbol.Index]
+		if !loc.cell {
+			f.c.fail("variable %s isn't held in a cell", ident.Value)
+		}
+		dst = f.dest(dst)
+		f.emit(OpGetFree, dst, symbol.Depth, loc.index)
+	case compiler.BuiltinScope:
+		dst = f.dest(dst)
+		f.emit(OpGetBuiltin, dst, symbol.Index, 0)
+	}
+	return dst
+}
+
+// call compiles a call of the function of expr with its arguments, using op,
+// returning the register the result is put in.
+func (f *function) call(expr *ast.CallExpression, op Opcode) int {
+	a := f.temp(1 + len(expr.Arguments))
+	f.expression(expr.Function, a)
+	for i, arg := range expr.Arguments {
+		f.expression(arg, a+1+i)
+	}
+	f.emit(op, a, len(expr.Arguments), 0)
+	return a
+}
+
+// closure compiles the function literal fl, bound to the specified name, if
+// any, to an instruction creating a closure of it.
+func (f *function) closure(fl *ast.FunctionLiteral, name string, dst int) int {
+	fn := &Function{Name: name, NumParameters: len(fl.Parameters)}
+	symbols := compiler.NewEnclosedSymbolTable(f.symbols)
+	for _, p := range fl.Parameters {
+		symbols.DefineParameter(p.Value)
+	}
+	inner := f.c.enter(fn, symbols, fl.Body.Statements)
+	inner.body(fl.Body.Statements)
+	f.c.leave()
+
+	f.fn.Functions = append(f.fn.Functions, fn)
+	dst = f.dest(dst)
+	f.emit(OpClosure, dst, len(f.fn.Functions)-1, 0)
+	return dst
+}
+
+// declare declares the variables bound by let statements in node, other than
+// those in the bodies of function literals, which have scopes of their own.
+func declare(s *compiler.SymbolTable, node ast.Node) {
+	walk(node, func(node ast.Node) {
+		if let, ok := node.(*ast.LetStatement); ok {
+			s.Declare(let.Name.Value)
+		}
+	})
+}
+
+// hasLet reports whether node holds a let statement, other than in the bodies
+// of function literals.
+func hasLet(node ast.Node) bool {
+	found := false
+	walk(node, func(node ast.Node) {
+		if _, ok := node.(*ast.LetStatement); ok {
+			found = true
+		}
+	})
+	return found
+}
+
+// captures adds to names the names of the identifiers in the function
+// literals within node.
+func captures(names map[string]bool, node ast.Node) {
+	walk(node, func(node ast.Node) {
+		if fl, ok := node.(*ast.FunctionLiteral); ok {
+			collect(names, fl.Body)
+		}
+	})
+}
+
+// collect adds to names the names of all the identifiers within node.
+func collect(names map[string]bool, node ast.Node) {
+	walk(node, func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			names[node.Value] = true
+		case *ast.FunctionLiteral:
+			collect(names, node.Body)
+		}
+	})
+}
+
+// walk calls visit for node and the nodes within it, in the order they are
+// evaluated, other than those in the bodies of function literals.
+func walk(node ast.Node, visit func(ast.Node)) {
+	visit(node)
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		walk(node.Value, visit)
+	case *ast.ExpressionStatement:
+		walk(node.Expression, visit)
+	case *ast.ReturnStatement:
+		walk(node.ReturnValue, visit)
+	case *ast.BlockStatement:
+		for _, statement := range node.Statements {
+			walk(statement, visit)
+		}
+	case *ast.IfExpression:
+		walk(node.Condition, visit)
+		walk(node.Consequence, visit)
+		if node.Alternative != nil {
+			walk(node.Alternative, visit)
+		}
+	case *ast.PrefixExpression:
+		walk(node.Right, visit)
+	case *ast.InfixExpression:
+		walk(node.Left, visit)
+		walk(node.Right, visit)
+	case *ast.CallExpression:
+		walk(node.Function, visit)
+		for _, a := range node.Arguments {
+			walk(a, visit)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			walk(el, visit)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			walk(pair.Key, visit)
+			walk(pair.Value, visit)
+		}
+	case *ast.IndexExpression:
+		walk(node.Left, visit)
+		walk(node.Index, visit)
+	}
+}
@@ -0,0 +1,25 @@
+// Package regvm is an experimental register machine for Monkey programs, an
+// alternative to the stack machine in package vm with the same results. It
+// has its own instruction set, and its own compiler from abstract syntax
+// trees:
+//  program, err := regvm.Compile(p.ParseProgram())
+//  if err != nil {
+//      log.Fatal(err)
+//  }
+//  machine := regvm.New(program)
+//  if err := machine.Run(); err != nil {
+//      log.Fatal(err)
+//  }
+//  fmt.Println(machine.Result().Inspect())
+//
+// Each instruction names the registers it reads and writes, so operands
+// aren't pushed and popped: a + 1 is one instruction, where the stack machine
+// takes four. Each call has a window of registers, starting with its
+// arguments, which the caller puts after the register holding the function,
+// so that calls don't copy them. A function's variables are held in its
+// registers, except those the functions defined within it refer to, which
+// are held in cells shared with the closures created during the call.
+//
+// Variables are resolved as by package compiler, and errors have the same
+// messages, positions and traces as in the evaluator.
+package regvm
@@ -0,0 +1,137 @@
+package regvm
+
+import (
+	"github.com/adamvinueza/monkey/object"
+)
+
+func identifierNotFound(name string) error {
+	return newError("identifier not found: %s", name)
+}
+
+// operators holds the operator each binary opcode applies.
+var operators = map[Opcode]string{
+	OpAdd:         "+",
+	OpSub:         "-",
+	OpMul:         "*",
+	OpDiv:         "/",
+	OpEqual:       "==",
+	OpNotEqual:    "!=",
+	OpGreaterThan: ">",
+	OpLessThan:    "<",
+}
+
+// binaryOperation applies the operator of op to left and right, which aren't
+// both integers.
+func binaryOperation(op Opcode, left, right object.Object) (object.Object, error) {
+	switch {
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return stringOperation(op, left, right)
+	case left.Type() != right.Type():
+		return nil, newError("type mismatch: %s %s %s", left.Type(),
+			operators[op], right.Type())
+	case op == OpEqual:
+		return nativeBoolToBooleanObject(left == right), nil
+	case op == OpNotEqual:
+		return nativeBoolToBooleanObject(left != right), nil
+	default:
+		return nil, newError("unknown operator: %s %s %s", left.Type(),
+			operators[op], right.Type())
+	}
+}
+
+func integerOperation(op Opcode, left, right int64) (object.Object, error) {
+	switch op {
+	case OpAdd:
+		return &object.Integer{Value: left + right}, nil
+	case OpSub:
+		return &object.Integer{Value: left - right}, nil
+	case OpMul:
+		return &object.Integer{Value: left * right}, nil
+	case OpDiv:
+		if right == 0 {
+			return nil, newError("division by zero: %d / %d", left, right)
+		}
+		return &object.Integer{Value: left / right}, nil
+	case OpEqual:
+		return nativeBoolToBooleanObject(left == right), nil
+	case OpNotEqual:
+		return nativeBoolToBooleanObject(left != right), nil
+	case OpGreaterThan:
+		return nativeBoolToBooleanObject(left > right), nil
+	default:
+		return nativeBoolToBooleanObject(left < right), nil
+	}
+}
+
+func stringOperation(op Opcode, left, right object.Object) (object.Object, error) {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch op {
+	case OpAdd:
+		return &object.String{Value: leftVal + rightVal}, nil
+	case OpEqual:
+		return nativeBoolToBooleanObject(leftVal == rightVal), nil
+	case OpNotEqual:
+		return nativeBoolToBooleanObject(leftVal != rightVal), nil
+	default:
+		return nil, newError("unknown operator: %s %s %s", left.Type(),
+			operators[op], right.Type())
+	}
+}
+
+// buildHash returns a hash of elements, which alternate between keys and
+// values.
+func buildHash(elements []object.Object) (object.Object, error) {
+	pairs := make(map[object.HashKey]object.HashPair)
+	for i := 0; i < len(elements); i += 2 {
+		key, value := elements[i], elements[i+1]
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, newError("unusable as hash key: %s", key.Type())
+		}
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	return &object.Hash{Pairs: pairs}, nil
+}
+
+func index(left, index object.Object) (object.Object, error) {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		i := index.(*object.Integer).Value
+		if i < 0 || i >= int64(len(elements)) {
+			return Null, nil
+		}
+		return elements[i], nil
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return nil, newError("unusable as hash key: %s", index.Type())
+		}
+		pair, ok := left.(*object.Hash).Pairs[key.HashKey()]
+		if !ok {
+			return Null, nil
+		}
+		return pair.Value, nil
+	default:
+		return nil, newError("index operator not supported: %s[%s]",
+			left.Type(), index.Type())
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case Null, False:
+		return false
+	default:
+		return true
+	}
+}
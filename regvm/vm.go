@@ -0,0 +1,450 @@
+package regvm
+
+import (
+	"fmt"
+
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/vm"
+)
+
+// initialRegisters is the number of registers the machine has before it has
+// to grow.
+const initialRegisters = 2048
+
+// The machine shares these values with the evaluator's builtins.
+var (
+	True  = evaluator.TRUE
+	False = evaluator.FALSE
+	Null  = evaluator.NULL
+)
+
+// Closure represents a function value in the register machine: a compiled
+// function along with the cells of the calls enclosing its definition, which
+// it shares with them. Its type is FUNCTION.
+type Closure struct {
+	Fn   *Function
+	free []*cells // the cells of the enclosing calls, innermost first
+}
+
+func (c *Closure) Type() object.ObjectType { return object.FUNCTION_OBJ }
+func (c *Closure) Inspect() string {
+	if c.Fn.Name == "" {
+		return "compiled function"
+	}
+	return "compiled function " + c.Fn.Name
+}
+
+// cells holds the values of the variables of a call held in cells. A nil
+// value is a variable that hasn't been assigned yet.
+type cells struct {
+	fn     *Function
+	values []object.Object
+}
+
+// VM runs a program compiled for the register machine.
+type VM struct {
+	// Sandbox restricts the builtins the program may use, as in the
+	// evaluator, if it is set before the program runs.
+	Sandbox *evaluator.Sandbox
+
+	program  *Program
+	globals  []object.Object
+	builtins []*object.Builtin
+
+	// regs holds the registers of the active calls, each call's starting
+	// after the register holding the function called.
+	regs   []object.Object
+	frames []frame // the active calls, the top level of the program first
+
+	result object.Object
+}
+
+// frame holds the state of a call.
+type frame struct {
+	cl    *Closure
+	pc    int // the offset of the current instruction
+	base  int // the index in regs of the call's first register
+	cells *cells
+
+	// caller is the function that made the call, and callPC the offset of
+	// the call instruction in it, for stack traces.
+	caller *Function
+	callPC int
+}
+
+// New returns a machine that runs program. The builtins the program uses are
+// looked up now.
+func New(program *Program) *VM {
+	builtins := make([]*object.Builtin, len(program.Builtins))
+	for i, name := range program.Builtins {
+		builtins[i], _ = evaluator.LookupBuiltin(name)
+	}
+	frames := make([]frame, 1, 16)
+	frames[0] = frame{cl: &Closure{Fn: program.Main}}
+	regs := initialRegisters
+	if program.Main.NumRegisters > regs {
+		regs = program.Main.NumRegisters
+	}
+	return &VM{
+		program:  program,
+		globals:  make([]object.Object, len(program.Globals)),
+		builtins: builtins,
+		regs:     make([]object.Object, regs),
+		frames:   frames,
+	}
+}
+
+// Result returns the value of the program, once it has run: that of the last
+// statement, if it is an expression statement, or of the top-level return
+// statement that ended it. Otherwise, the result is nil.
+func (m *VM) Result() object.Object {
+	return m.result
+}
+
+// Run runs the program. If the program fails, the error is a
+// *vm.RuntimeError, positioned at the instruction that failed, with a trace of
+// the active function calls.
+func (m *VM) Run() error {
+	err := m.run()
+	if err, ok := err.(*vm.RuntimeError); ok {
+		m.addTrace(err.Err)
+	}
+	return err
+}
+
+// addTrace gives err the position of the current instruction, unless it has a
+// position already, and the stack of active function calls.
+func (m *VM) addTrace(err *object.Error) {
+	f := &m.frames[len(m.frames)-1]
+	if !err.Pos.IsValid() {
+		err.Pos = f.cl.Fn.Lines.Pos(f.pc)
+	}
+	for i := len(m.frames) - 1; i > 0; i-- {
+		f := &m.frames[i]
+		name := f.cl.Fn.Name
+		if name == "" {
+			name = "fn"
+		}
+		err.Stack = append(err.Stack, object.Frame{
+			Function: name,
+			CallPos:  f.caller.Lines.Pos(f.callPC),
+		})
+	}
+}
+
+// lookup returns the value of name when the variable the compiler resolved it
+// to hasn't been assigned, because the let statement binding it hasn't run, as
+// when it is in a branch not taken. As in the evaluator, the value is that of
+// the innermost variable called name that has been assigned, among the cells
+// of the calls in free, innermost first, and the globals, or else the builtin
+// called name.
+func (m *VM) lookup(name string, free []*cells) (object.Object, error) {
+	for _, c := range free {
+		for i, cell := range c.fn.Cells {
+			if cell == name && c.values[i] != nil {
+				return c.values[i], nil
+			}
+		}
+	}
+	for i, global := range m.program.Globals {
+		if global == name && m.globals[i] != nil {
+			return m.globals[i], nil
+		}
+	}
+	if builtin, ok := evaluator.LookupBuiltin(name); ok {
+		if err := m.Sandbox.Check(builtin); err != nil {
+			return nil, &vm.RuntimeError{Err: err}
+		}
+		return builtin, nil
+	}
+	return nil, identifierNotFound(name)
+}
+
+func newError(format string, a ...interface{}) error {
+	return &vm.RuntimeError{Err: &object.Error{Message: fmt.Sprintf(format, a...)}}
+}
+
+func (m *VM) run() (err error) {
+	f := &m.frames[len(m.frames)-1]
+	fn := f.cl.Fn
+	ins := fn.Instructions
+	k := fn.Constants
+	regs := m.regs[f.base:]
+	pc := -1
+
+	defer func() {
+		if err != nil {
+			f.pc = pc
+		}
+	}()
+
+	for {
+		pc++
+		in := ins[pc]
+		switch in.Op {
+		case OpLoadConst:
+			regs[in.A] = k[in.B]
+
+		case OpLoadTrue:
+			regs[in.A] = True
+
+		case OpLoadFalse:
+			regs[in.A] = False
+
+		case OpLoadNull:
+			regs[in.A] = Null
+
+		case OpMove:
+			regs[in.A] = regs[in.B]
+
+		case OpCheck:
+			if regs[in.A] == nil {
+				// The variable's value is looked up once, as nothing can
+				// assign the variables it may be found in while the call
+				// runs.
+				value, err := m.lookup(fn.Registers[in.A], f.cl.free)
+				if err != nil {
+					return err
+				}
+				regs[in.A] = value
+			}
+
+		case OpGetGlobal:
+			value := m.globals[in.B]
+			if value == nil {
+				var err error
+				value, err = m.lookup(m.program.Globals[in.B], nil)
+				if err != nil {
+					return err
+				}
+			}
+			regs[in.A] = value
+
+		case OpSetGlobal:
+			m.globals[in.B] = regs[in.A]
+
+		case OpGetCell:
+			value := f.cells.values[in.B]
+			if value == nil {
+				var err error
+				value, err = m.lookup(fn.Cells[in.B], f.cl.free)
+				if err != nil {
+					return err
+				}
+			}
+			regs[in.A] = value
+
+		case OpSetCell:
+			f.cells.values[in.B] = regs[in.A]
+
+		case OpGetFree:
+			c := f.cl.free[in.B]
+			value := c.values[in.C]
+			if value == nil {
+				var err error
+				value, err = m.lookup(c.fn.Cells[in.C], f.cl.free[in.B+1:])
+				if err != nil {
+					return err
+				}
+			}
+			regs[in.A] = value
+
+		case OpGetBuiltin:
+			builtin := m.builtins[in.B]
+			if builtin == nil {
+				return identifierNotFound(m.program.Builtins[in.B])
+			}
+			if err := m.Sandbox.Check(builtin); err != nil {
+				return &vm.RuntimeError{Err: err}
+			}
+			regs[in.A] = builtin
+
+		case OpAdd, OpSub, OpMul, OpDiv, OpEqual, OpNotEqual, OpGreaterThan,
+			OpLessThan:
+			var left, right object.Object
+			if in.B&constBit != 0 {
+				left = k[in.B&^constBit]
+			} else {
+				left = regs[in.B]
+			}
+			if in.C&constBit != 0 {
+				right = k[in.C&^constBit]
+			} else {
+				right = regs[in.C]
+			}
+			if l, ok := left.(*object.Integer); ok {
+				if r, ok := right.(*object.Integer); ok {
+					regs[in.A], err = integerOperation(in.Op, l.Value, r.Value)
+					if err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if regs[in.A], err = binaryOperation(in.Op, left, right); err != nil {
+				return err
+			}
+
+		case OpMinus:
+			operand := regs[in.B]
+			integer, ok := operand.(*object.Integer)
+			if !ok {
+				return newError("unknown operator: -%s", operand.Type())
+			}
+			regs[in.A] = &object.Integer{Value: -integer.Value}
+
+		case OpBang:
+			regs[in.A] = nativeBoolToBooleanObject(!isTruthy(regs[in.B]))
+
+		case OpJump:
+			pc = int(in.B) - 1
+
+		case OpJumpNotTruthy:
+			if !isTruthy(regs[in.A]) {
+				pc = int(in.B) - 1
+			}
+
+		case OpArray:
+			elements := make([]object.Object, in.C)
+			copy(elements, regs[in.B:int(in.B)+int(in.C)])
+			regs[in.A] = &object.Array{Elements: elements}
+
+		case OpHash:
+			if regs[in.A], err = buildHash(regs[in.B : int(in.B)+int(in.C)]); err != nil {
+				return err
+			}
+
+		case OpHashKey:
+			if _, ok := regs[in.A].(object.Hashable); !ok {
+				return newError("unusable as hash key: %s", regs[in.A].Type())
+			}
+
+		case OpIndex:
+			if regs[in.A], err = index(regs[in.B], regs[in.C]); err != nil {
+				return err
+			}
+
+		case OpClosure:
+			var free []*cells
+			if len(m.frames) > 1 {
+				free = make([]*cells, 1+len(f.cl.free))
+				free[0] = f.cells
+				copy(free[1:], f.cl.free)
+			}
+			regs[in.A] = &Closure{Fn: fn.Functions[in.B], free: free}
+
+		case OpCall, OpTailCall:
+			f.pc = pc
+			a, numArgs := int(in.A), int(in.B)
+			switch callee := regs[a].(type) {
+			case *Closure:
+				if numArgs != callee.Fn.NumParameters {
+					return newError("wrong number of arguments: expected %d, found %d",
+						callee.Fn.NumParameters, numArgs)
+				}
+				base := f.base + a + 1
+				if in.Op == OpTailCall {
+					// The call replaces the current one, but, as in the
+					// evaluator, the position of the call is that of
+					// the tail call.
+					copy(m.regs[f.base-1:], regs[a:a+1+numArgs])
+					base = f.base
+					*f = frame{caller: fn, callPC: pc}
+				} else {
+					if len(m.frames) > vm.MaxDepth {
+						return newError("maximum call depth (%d) exceeded",
+							vm.MaxDepth)
+					}
+					m.frames = append(m.frames, frame{caller: fn, callPC: pc})
+					f = &m.frames[len(m.frames)-1]
+				}
+				fn = callee.Fn
+				f.cl = callee
+				f.base = base
+				if len(fn.Cells) > 0 {
+					f.cells = &cells{fn: fn,
+						values: make([]object.Object, len(fn.Cells))}
+				}
+				if need := base + fn.NumRegisters; need > len(m.regs) {
+					m.grow(need)
+				}
+				regs = m.regs[base:]
+				for i := fn.NumParameters; i < len(fn.Registers); i++ {
+					regs[i] = nil
+				}
+				ins = fn.Instructions
+				k = fn.Constants
+				pc = -1
+
+			case *object.Builtin:
+				args := make([]object.Object, numArgs)
+				copy(args, regs[a+1:a+1+numArgs])
+				result := callee.Fn(args...)
+				if rerr, ok := result.(*object.Error); ok {
+					return &vm.RuntimeError{Err: rerr}
+				}
+				if result == nil {
+					result = Null
+				}
+				if in.Op == OpCall {
+					regs[a] = result
+					break
+				}
+				if done := m.ret(result); done {
+					return nil
+				}
+				f = &m.frames[len(m.frames)-1]
+				fn, ins, k = f.cl.Fn, f.cl.Fn.Instructions, f.cl.Fn.Constants
+				regs = m.regs[f.base:]
+				pc = f.pc
+
+			default:
+				return newError("not a function: %s", callee.Type())
+			}
+
+		case OpReturn, OpReturnNull:
+			var result object.Object = Null
+			if in.Op == OpReturn {
+				result = regs[in.A]
+			} else if len(m.frames) == 1 {
+				result = nil
+			}
+			if done := m.ret(result); done {
+				return nil
+			}
+			f = &m.frames[len(m.frames)-1]
+			fn, ins, k = f.cl.Fn, f.cl.Fn.Instructions, f.cl.Fn.Constants
+			regs = m.regs[f.base:]
+			pc = f.pc
+
+		default:
+			return fmt.Errorf("unknown opcode %d", in.Op)
+		}
+	}
+}
+
+// ret returns result from the current call, reporting whether it was the top
+// level of the program.
+func (m *VM) ret(result object.Object) bool {
+	if len(m.frames) == 1 {
+		m.result = result
+		return true
+	}
+	f := &m.frames[len(m.frames)-1]
+	m.regs[f.base-1] = result
+	*f = frame{}
+	m.frames = m.frames[:len(m.frames)-1]
+	return false
+}
+
+// grow grows the registers to hold at least n.
+func (m *VM) grow(n int) {
+	size := 2 * len(m.regs)
+	for size < n {
+		size *= 2
+	}
+	regs := make([]object.Object, size)
+	copy(regs, m.regs)
+	m.regs = regs
+}
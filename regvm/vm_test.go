@@ -0,0 +1,260 @@
+package regvm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/parser"
+	"github.com/adamvinueza/monkey/token"
+	"github.com/adamvinueza/monkey/vm"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", "50"},
+		{"1 < 2 == true", "true"},
+		{"!(if (false) { 5; })", "true"},
+		{`"mon" + "key"`, "monkey"},
+		{`[1 + 2, "a"][1]`, "a"},
+		{`{"b": 1, "a": 2}`, `{"a": 2, "b": 1}`},
+		{"if (false) { 10 }", "null"},
+		{"let x = 1;", "<nil>"},
+		{"if (true) { let x = 5; }; x", "5"},
+		{"return 1; 2", "1"},
+		{"let f = fn(x, x) { x }; f(1, 2)", "2"},
+		{"let f = fn() { }; f()", "null"},
+		{"let f = fn(a) { let a = a + 1; a }; f(1)", "2"},
+		// A variable's old value is used if it is assigned before the
+		// operator is applied.
+		{"let f = fn(x) { x + if (true) { let x = 5; x } }; f(1)", "6"},
+		{"let f = fn(c) { if (c) { let y = 1; } y }; f(false)",
+			"identifier not found: y"},
+		{"let newAdder = fn(a) { fn(b) { a + b } }; newAdder(2)(3)", "5"},
+		{`let newAdder = fn(a, b) {
+  let c = a + b;
+  fn(d) { let e = d + c; fn(f) { e + f } }
+};
+newAdder(1, 2)(3)(8)`, "14"},
+		// Closures share the variables they refer to with their enclosing
+		// call.
+		{"let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() }; f()",
+			"2"},
+		{"let f = fn() { g() }; let g = fn() { 7 }; f()", "7"},
+		{"let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } }; loop(100000)",
+			"0"},
+		{"let f = fn(n) { if (n == 0) { 1 } else { 2 * f(n - 1) } }; f(10)",
+			"1024"},
+		{"let f = fn() { len([1, 2]) }; f()", "2"},
+		{"let f = fn(a) { push(a, len(a)) }; f(f(f([])))", "[0, 1, 2]"},
+	}
+
+	for _, tt := range tests {
+		found, err := run(tt.input)
+		if err != nil {
+			found = err.(*vm.RuntimeError).Err.Message
+		}
+		if found != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, found=%q", tt.input,
+				tt.expected, found)
+		}
+	}
+}
+
+func TestRunErrorTraces(t *testing.T) {
+	// As in the evaluator, a call nested too deeply fails with the stack of
+	// the calls made.
+	deep := make([]object.Frame, vm.MaxDepth)
+	for i := range deep {
+		deep[i] = object.Frame{Function: "f",
+			CallPos: token.Position{Line: 1, Column: 20}}
+	}
+	deep[len(deep)-1].CallPos = token.Position{Line: 2, Column: 1}
+
+	tests := []struct {
+		input           string
+		expectedMessage string
+		expectedPos     token.Position
+		expectedStack   []object.Frame
+	}{
+		{"let x = 1;\nlet y = x * -true;", "unknown operator: -BOOLEAN",
+			token.Position{Line: 2, Column: 13}, nil},
+		{"if (true) {\n  foobar\n}", "identifier not found: foobar",
+			token.Position{Line: 2, Column: 3}, nil},
+		{"let f = fn(x) { x };\n f(1, 2)",
+			"wrong number of arguments: expected 1, found 2",
+			token.Position{Line: 2, Column: 2}, nil},
+		{"{1: 2, [3]: 4 / 0}", "unusable as hash key: ARRAY",
+			token.Position{Line: 1, Column: 1}, nil},
+		{"len(1)", "argument 1 to `len` must be ARRAY or STRING, found INTEGER",
+			token.Position{Line: 1, Column: 1}, nil},
+		{`let divide = fn(a, b) {
+  a / b
+};
+let compute = fn(x) {
+  let half = fn(y) { divide(y, 0) };
+  1 + half(x)
+};
+let alias = compute;
+alias(10);
+`, "division by zero: 10 / 0", token.Position{Line: 2, Column: 3},
+			[]object.Frame{
+				{Function: "divide", CallPos: token.Position{Line: 5, Column: 22}},
+				{Function: "compute", CallPos: token.Position{Line: 9, Column: 1}},
+			}},
+		{"fn() { -true }()", "unknown operator: -BOOLEAN",
+			token.Position{Line: 1, Column: 8}, []object.Frame{
+				{Function: "fn", CallPos: token.Position{Line: 1, Column: 1}},
+			}},
+		{"let f = fn() { 1 + f() };\nf()", "maximum call depth (10000) exceeded",
+			token.Position{Line: 1, Column: 20}, deep},
+	}
+
+	for _, tt := range tests {
+		_, err := run(tt.input)
+		rerr, ok := err.(*vm.RuntimeError)
+		if !ok {
+			t.Errorf("expected *vm.RuntimeError for %q, found %T (%v)",
+				tt.input, err, err)
+			continue
+		}
+		if rerr.Err.Message != tt.expectedMessage {
+			t.Errorf("wrong error message for %q. expected=%q, found=%q",
+				tt.input, tt.expectedMessage, rerr.Err.Message)
+		}
+		if rerr.Err.Pos != tt.expectedPos {
+			t.Errorf("wrong error position for %q. expected=%s, found=%s",
+				tt.input, tt.expectedPos, rerr.Err.Pos)
+		}
+		if fmt.Sprint(rerr.Err.Stack) != fmt.Sprint(tt.expectedStack) {
+			t.Errorf("wrong stack for %q. expected=%v, found=%v", tt.input,
+				tt.expectedStack, rerr.Err.Stack)
+		}
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	sandbox := &evaluator.Sandbox{Allow: []string{"core"}}
+	tests := []struct {
+		input           string
+		expectedMessage string // empty if the program should run
+	}{
+		{`len([1])`, ""},
+		{`puts(1)`, "permission denied: io.puts is not allowed"},
+		{`let call = fn(f) { f("/etc/passwd") }; call(readFile)`,
+			"permission denied: io.readFile is not allowed"},
+		// A global not yet bound falls back to the builtin of the same name.
+		{`let f = fn() { readFile("/etc/passwd") }; f(); let readFile = 1;`,
+			"permission denied: io.readFile is not allowed"},
+		{`let puts = fn(x) { x }; puts(1)`, ""},
+	}
+
+	for _, tt := range tests {
+		program, err := Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		machine := New(program)
+		machine.Sandbox = sandbox
+		err = machine.Run()
+		if tt.expectedMessage == "" {
+			if err != nil {
+				t.Errorf("unexpected error for %q: %s", tt.input, err)
+			}
+			continue
+		}
+		rerr, ok := err.(*vm.RuntimeError)
+		if !ok {
+			t.Errorf("expected error %q for %q, found %v", tt.expectedMessage,
+				tt.input, err)
+			continue
+		}
+		if rerr.Err.Message != tt.expectedMessage {
+			t.Errorf("wrong error message for %q. expected=%q, found=%q",
+				tt.input, tt.expectedMessage, rerr.Err.Message)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	program, err := Compile(parse(`let adder = fn(a) { fn(b) { a + b } };
+let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) };
+let count = fn(n) { if (n > 0) { count(n - 1) } };`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder, fib, count := program.Main.Functions[0], program.Main.Functions[1],
+		program.Main.Functions[2]
+	tests := []struct {
+		fn       *Function
+		expected string
+	}{
+		{adder, `0000 OpSetCell r0 0
+0001 OpClosure r1 0
+0002 OpReturn r1
+`},
+		{adder.Functions[0], `0000 OpGetFree r1 0 0
+0001 OpAdd r2 r1 r0
+0002 OpReturn r2
+`},
+		{fib, `0000 OpLessThan r1 r0 k0
+0001 OpJumpNotTruthy r1 @3
+0002 OpReturn r0
+0003 OpGetGlobal r1 1
+0004 OpSub r2 r0 k1
+0005 OpCall r1 1
+0006 OpGetGlobal r3 1
+0007 OpSub r4 r0 k2
+0008 OpCall r3 1
+0009 OpAdd r5 r1 r3
+0010 OpReturn r5
+`},
+		{count, `0000 OpGreaterThan r1 r0 k0
+0001 OpJumpNotTruthy r1 @5
+0002 OpGetGlobal r2 2
+0003 OpSub r3 r0 k1
+0004 OpTailCall r2 1
+0005 OpLoadNull r2
+0006 OpReturn r2
+`},
+	}
+
+	for _, tt := range tests {
+		if found := tt.fn.Instructions.String(); found != tt.expected {
+			t.Errorf("wrong instructions for %s.\nexpected:\n%s\nfound:\n%s",
+				tt.fn.Name, tt.expected, found)
+		}
+	}
+	if fmt.Sprint(adder.Cells) != "[a]" {
+		t.Errorf("wrong cells for adder: %v", adder.Cells)
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+// run compiles input and runs it, returning its value as shown by Inspect, or
+// "<nil>" if it has none.
+func run(input string) (string, error) {
+	program, err := Compile(parse(input))
+	if err != nil {
+		return "", err
+	}
+	machine := New(program)
+	if err := machine.Run(); err != nil {
+		return "", err
+	}
+	if machine.Result() == nil {
+		return "<nil>", nil
+	}
+	return machine.Result().Inspect(), nil
+}
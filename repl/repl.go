@@ -1,29 +1,85 @@
 package repl
 
 import (
-    "bufio"
-    "fmt"
-    "io"
-    "github.com/adamvinueza/monkey/lexer"
-    "github.com/adamvinueza/monkey/token"
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/parser"
+	"github.com/adamvinueza/monkey/token"
 )
 
 const PROMPT = ">> "
 
+// SEXPR is the command that shows the S-expression for the rest of the line,
+// e.g. ":sexpr let x = 1 + y;".
+const SEXPR = ":sexpr"
+
+// TOKENS is the command that shows the tokens in the rest of the line.
+const TOKENS = ":tokens"
+
+// Start reads lines from in, evaluating each one and writing its value to out.
+// Values bound by let statements persist from line to line.
 func Start(in io.Reader, out io.Writer) {
-    scanner := bufio.NewScanner(in)
-    for {
-        fmt.Printf(PROMPT)
-        scanned := scanner.Scan()
-        if !scanned {
-            return
-        }
-
-        line := scanner.Text()
-        l := lexer.New(line)
-
-        for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-            fmt.Printf("%s\n", tok)
-        }
-    }
+	scanner := bufio.NewScanner(in)
+	env := object.NewEnvironment()
+	for {
+		fmt.Fprintf(out, PROMPT)
+		scanned := scanner.Scan()
+		if !scanned {
+			return
+		}
+
+		line := scanner.Text()
+		if strings.HasPrefix(line, SEXPR) {
+			printSExpr(out, strings.TrimPrefix(line, SEXPR))
+			continue
+		}
+		if strings.HasPrefix(line, TOKENS) {
+			printTokens(out, strings.TrimPrefix(line, TOKENS))
+			continue
+		}
+
+		p := parser.New(lexer.New(line))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			printParserErrors(out, p.Errors())
+			continue
+		}
+
+		evaluated := evaluator.Eval(program, env)
+		if err, ok := evaluated.(*object.Error); ok {
+			fmt.Fprint(out, err.Trace(""))
+		} else if evaluated != nil {
+			fmt.Fprintf(out, "%s\n", evaluated.Inspect())
+		}
+	}
+}
+
+func printTokens(out io.Writer, line string) {
+	l := lexer.New(line)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(out, "%s\n", tok)
+	}
+}
+
+func printSExpr(out io.Writer, line string) {
+	p := parser.New(lexer.New(line))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, p.Errors())
+		return
+	}
+	fmt.Fprintf(out, "%s\n", ast.SExpr(program))
+}
+
+func printParserErrors(out io.Writer, errors []string) {
+	for _, msg := range errors {
+		fmt.Fprintf(out, "\t%s\n", msg)
+	}
 }
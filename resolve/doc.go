@@ -0,0 +1,27 @@
+// Package resolve binds the identifiers of a Monkey program to the variables
+// they refer to, without running it, so that misspelled names are reported
+// before the lines using them run:
+//  info, errs := resolve.Program(program)
+//  for _, err := range errs {
+//  	fmt.Println(err) // e.g. "3:5: identifier not found: lenght"
+//  }
+//
+// A variable is a global, bound by a let statement at the top level of the
+// program; a local variable or parameter of a function; or a builtin. Within
+// a function, a variable the function binds is visible from its first let
+// statement on, while the variables of the enclosing functions and the
+// globals are visible throughout, so that functions may refer to each other
+// whatever order they are defined in. These are the rules of package
+// compiler, and the symbol recorded for each identifier is the one the
+// compiler resolves it to.
+//
+// Three kinds of mistakes are reported: identifiers that refer to no
+// variable, functions with two parameters of the same name, and let
+// statements binding a name that an earlier let statement in the same
+// function has already bound on every path to it. Let statements in the
+// branches of an if expression may bind the same name, and so may a let
+// statement and a parameter, as in "fn(n) { let n = n + 1; n }".
+//
+// The builtins are those known to package evaluator when Program is called,
+// including any registered by package monkey.
+package resolve
@@ -0,0 +1,396 @@
+package resolve
+
+import (
+	"fmt"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/compiler"
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// Kind identifies what binds a variable, as seen from an identifier referring
+// to it.
+type Kind int
+
+const (
+	Global    Kind = iota // bound at the top level of the program
+	Local                 // bound by a let statement in the current function
+	Parameter             // a parameter of the current function
+	Free                  // a local variable or parameter of an enclosing function
+	Builtin               // not bound by the program
+)
+
+var kindNames = [...]string{
+	Global:    "global",
+	Local:     "local",
+	Parameter: "parameter",
+	Free:      "free",
+	Builtin:   "builtin",
+}
+
+func (k Kind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Declaration describes a variable.
+type Declaration struct {
+	Name string
+	Kind Kind // Global, Local, Parameter or Builtin
+	// Ident is the identifier that binds the variable: the parameter, or
+	// the name in its first let statement. It is nil for a builtin.
+	Ident *ast.Identifier
+	// Function is the function literal binding a local variable or
+	// parameter.
+	Function *ast.FunctionLiteral
+	// Uses holds the identifiers referring to the variable, in the order
+	// they appear, other than those binding it.
+	Uses []*ast.Identifier
+}
+
+// Pos returns the position of the identifier binding the variable, or the
+// zero Position for a builtin.
+func (d *Declaration) Pos() token.Position {
+	if d.Ident == nil {
+		return token.Position{}
+	}
+	return d.Ident.Token.Pos
+}
+
+// Binding records what an identifier refers to.
+type Binding struct {
+	Decl *Declaration
+	// Kind is the kind of the variable as seen from the identifier: that
+	// of its declaration, or Free for a local variable or parameter of an
+	// enclosing function.
+	Kind Kind
+	// Symbol is the symbol package compiler resolves the identifier to.
+	Symbol compiler.Symbol
+}
+
+// Info holds the results of resolving a program.
+type Info struct {
+	// Bindings holds the binding of each identifier that refers to a
+	// variable, including those in let statements and parameter lists.
+	Bindings map[*ast.Identifier]*Binding
+	// Declarations holds the program's variables in the order they are
+	// bound, and the builtins it uses in the order they are first used.
+	Declarations []*Declaration
+
+	idents []*ast.Identifier // all the identifiers, in the order they appear
+}
+
+// At returns the identifier at pos, which may be any position within its
+// name, or nil if there is none.
+func (info *Info) At(pos token.Position) *ast.Identifier {
+	for _, ident := range info.idents {
+		start := ident.Token.Pos
+		if start.Line == pos.Line && start.Column <= pos.Column &&
+			pos.Column < start.Column+len(ident.Value) {
+			return ident
+		}
+	}
+	return nil
+}
+
+// Error is a mistake found in a program.
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Message
+}
+
+// Program resolves the identifiers in program. It returns the errors found in
+// the order they appear; the identifiers that refer to no variable have no
+// bindings.
+func Program(program *ast.Program) (*Info, []*Error) {
+	r := &resolver{
+		info:     &Info{Bindings: make(map[*ast.Identifier]*Binding)},
+		builtins: make(map[string]*Declaration),
+	}
+	r.enter(nil, compiler.NewSymbolTable())
+	r.body(program.Statements)
+	return r.info, r.errs
+}
+
+// resolver holds the state of the resolution of a program.
+type resolver struct {
+	info     *Info
+	errs     []*Error
+	scope    *scope // the innermost function being resolved
+	builtins map[string]*Declaration
+}
+
+// scope holds the variables of a function, or of the top level of a program.
+type scope struct {
+	outer   *scope
+	fn      *ast.FunctionLiteral
+	symbols *compiler.SymbolTable
+	decls   []*Declaration // by symbol index
+	// bound holds the names bound by let statements on every path to the
+	// statement being resolved, along with their positions.
+	bound map[string]token.Position
+}
+
+func (r *resolver) enter(fn *ast.FunctionLiteral, symbols *compiler.SymbolTable) {
+	r.scope = &scope{
+		outer:   r.scope,
+		fn:      fn,
+		symbols: symbols,
+		bound:   make(map[string]token.Position),
+	}
+}
+
+func (r *resolver) errorf(pos token.Position, format string, a ...interface{}) {
+	r.errs = append(r.errs, &Error{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+// body resolves the statements of a program or a function body, after
+// declaring the variables they bind, as the compiler does.
+func (r *resolver) body(stmts []ast.Statement) {
+	for _, s := range stmts {
+		r.declare(s)
+	}
+	for _, s := range stmts {
+		r.statement(s)
+	}
+}
+
+// declare declares the variables bound by let statements in node, other than
+// those in the bodies of function literals, which have scopes of their own.
+func (r *resolver) declare(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		r.declareVariable(node.Name, r.scope.symbols.Declare(node.Name.Value))
+		r.declare(node.Value)
+	case *ast.ExpressionStatement:
+		r.declare(node.Expression)
+	case *ast.ReturnStatement:
+		r.declare(node.ReturnValue)
+	case *ast.BlockStatement:
+		for _, statement := range node.Statements {
+			r.declare(statement)
+		}
+	case *ast.IfExpression:
+		r.declare(node.Condition)
+		r.declare(node.Consequence)
+		if node.Alternative != nil {
+			r.declare(node.Alternative)
+		}
+	case *ast.PrefixExpression:
+		r.declare(node.Right)
+	case *ast.InfixExpression:
+		r.declare(node.Left)
+		r.declare(node.Right)
+	case *ast.CallExpression:
+		r.declare(node.Function)
+		for _, a := range node.Arguments {
+			r.declare(a)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			r.declare(el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			r.declare(pair.Key)
+			r.declare(pair.Value)
+		}
+	case *ast.IndexExpression:
+		r.declare(node.Left)
+		r.declare(node.Index)
+	}
+}
+
+// declareVariable records the declaration of the variable with the specified
+// symbol in the current scope, bound by ident, unless it has been recorded
+// already.
+func (r *resolver) declareVariable(ident *ast.Identifier, symbol compiler.Symbol) {
+	s := r.scope
+	if symbol.Index < len(s.decls) {
+		return
+	}
+	kind := Local
+	switch {
+	case s.fn == nil:
+		kind = Global
+	case symbol.Index < len(s.fn.Parameters):
+		kind = Parameter
+	}
+	decl := &Declaration{Name: ident.Value, Kind: kind, Ident: ident,
+		Function: s.fn}
+	s.decls = append(s.decls, decl)
+	r.info.Declarations = append(r.info.Declarations, decl)
+}
+
+func (r *resolver) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		r.expression(s.Value)
+		if pos, ok := r.scope.bound[s.Name.Value]; ok {
+			r.errorf(s.Name.Token.Pos, "%s already bound at %s", s.Name.Value, pos)
+		}
+		r.scope.bound[s.Name.Value] = s.Name.Token.Pos
+		symbol := r.scope.symbols.Define(s.Name.Value)
+		r.bind(s.Name, symbol, false)
+	case *ast.ReturnStatement:
+		r.expression(s.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.expression(s.Expression)
+	case *ast.BlockStatement:
+		for _, statement := range s.Statements {
+			r.statement(statement)
+		}
+	}
+}
+
+func (r *resolver) expression(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		r.identifier(expr)
+	case *ast.PrefixExpression:
+		r.expression(expr.Right)
+	case *ast.InfixExpression:
+		r.expression(expr.Left)
+		r.expression(expr.Right)
+	case *ast.IfExpression:
+		r.ifExpression(expr)
+	case *ast.FunctionLiteral:
+		r.function(expr)
+	case *ast.CallExpression:
+		r.expression(expr.Function)
+		for _, a := range expr.Arguments {
+			r.expression(a)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range expr.Elements {
+			r.expression(el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range expr.Pairs {
+			r.expression(pair.Key)
+			r.expression(pair.Value)
+		}
+	case *ast.IndexExpression:
+		r.expression(expr.Left)
+		r.expression(expr.Index)
+	}
+}
+
+// ifExpression resolves an if expression. After it, the names bound on every
+// path are those bound before it and those bound in both of its branches.
+func (r *resolver) ifExpression(expr *ast.IfExpression) {
+	r.expression(expr.Condition)
+	before := copyBound(r.scope.bound)
+	r.statement(expr.Consequence)
+	if expr.Alternative == nil {
+		r.scope.bound = before
+		return
+	}
+	consequence := r.scope.bound
+	r.scope.bound = copyBound(before)
+	r.statement(expr.Alternative)
+	for name := range r.scope.bound {
+		if _, ok := consequence[name]; !ok {
+			delete(r.scope.bound, name)
+		}
+	}
+}
+
+func copyBound(bound map[string]token.Position) map[string]token.Position {
+	c := make(map[string]token.Position, len(bound))
+	for name, pos := range bound {
+		c[name] = pos
+	}
+	return c
+}
+
+// function resolves a function literal in a scope of its own.
+func (r *resolver) function(fl *ast.FunctionLiteral) {
+	r.enter(fl, compiler.NewEnclosedSymbolTable(r.scope.symbols))
+	params := make(map[string]bool)
+	for _, p := range fl.Parameters {
+		if params[p.Value] {
+			r.errorf(p.Token.Pos, "duplicate parameter %s", p.Value)
+		}
+		params[p.Value] = true
+		symbol := r.scope.symbols.DefineParameter(p.Value)
+		r.declareVariable(p, symbol)
+		r.bind(p, symbol, false)
+	}
+	r.body(fl.Body.Statements)
+	r.scope = r.scope.outer
+}
+
+// identifier resolves an identifier referring to a variable.
+func (r *resolver) identifier(ident *ast.Identifier) {
+	r.info.idents = append(r.info.idents, ident)
+	symbol, ok := r.scope.symbols.Resolve(ident.Value)
+	if !ok {
+		if _, ok := evaluator.LookupBuiltin(ident.Value); !ok {
+			r.notFound(ident)
+			return
+		}
+		symbol = r.scope.symbols.DefineBuiltin(ident.Value)
+	}
+	r.bind(ident, symbol, true)
+}
+
+// notFound reports an identifier that refers to no variable.
+func (r *resolver) notFound(ident *ast.Identifier) {
+	// The variable may be bound later in the same function.
+	for i, name := range r.scope.symbols.Names() {
+		if name == ident.Value {
+			r.errorf(ident.Token.Pos, "%s used before it is bound at %s",
+				ident.Value, r.scope.decls[i].Pos())
+			return
+		}
+	}
+	r.errorf(ident.Token.Pos, "identifier not found: %s", ident.Value)
+}
+
+// bind records the binding of ident, an identifier resolved to symbol. If use
+// is true, ident refers to the variable rather than binding it.
+func (r *resolver) bind(ident *ast.Identifier, symbol compiler.Symbol, use bool) {
+	if !use {
+		r.info.idents = append(r.info.idents, ident)
+	}
+	var decl *Declaration
+	kind := Free
+	switch symbol.Scope {
+	case compiler.GlobalScope:
+		s := r.scope
+		for s.outer != nil {
+			s = s.outer
+		}
+		decl = s.decls[symbol.Index]
+		kind = Global
+	case compiler.LocalScope:
+		decl = r.scope.decls[symbol.Index]
+		kind = decl.Kind
+	case compiler.FreeScope:
+		s := r.scope.outer
+		for i := 0; i < symbol.Depth; i++ {
+			s = s.outer
+		}
+		decl = s.decls[symbol.Index]
+	case compiler.BuiltinScope:
+		decl = r.builtins[ident.Value]
+		if decl == nil {
+			decl = &Declaration{Name: ident.Value, Kind: Builtin}
+			r.builtins[ident.Value] = decl
+			r.info.Declarations = append(r.info.Declarations, decl)
+		}
+		kind = Builtin
+	}
+	if use {
+		decl.Uses = append(decl.Uses, ident)
+	}
+	r.info.Bindings[ident] = &Binding{Decl: decl, Kind: kind, Symbol: symbol}
+}
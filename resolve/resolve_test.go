@@ -0,0 +1,154 @@
+package resolve
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/compiler"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/parser"
+	"github.com/adamvinueza/monkey/token"
+)
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x + len([])", nil},
+		{"let f = fn() { g() }; let g = fn() { f() };", nil},
+		{"let x = 1;\nx + lenght(x)", []string{"2:5: identifier not found: lenght"}},
+		{"fn(a) { b }", []string{"1:9: identifier not found: b"}},
+		{"let y = x;\nlet x = 1;", []string{"1:9: x used before it is bound at 2:5"}},
+		{"fn() { let a = a; }", []string{"1:16: a used before it is bound at 1:12"}},
+		// The variables of enclosing functions are visible throughout.
+		{"fn() { fn() { a }; let a = 1; }", nil},
+		{"let x = 1;\nlet x = 2;", []string{"2:5: x already bound at 1:5"}},
+		{"fn(n) { let n = n + 1; n }", nil},
+		{"fn(c) { if (c) { let a = 1; } else { let a = 2; } }", nil},
+		{"fn(c) { if (c) { let a = 1; } let a = 2; }", nil},
+		{"fn(c) { if (c) { let a = 1; } else { let a = 2; } let a = 3; }",
+			[]string{"1:55: a already bound at 1:42"}},
+		{"fn(c) { let a = 1; if (c) { let a = 2; } }",
+			[]string{"1:33: a already bound at 1:13"}},
+		{"fn() { let a = 1; fn() { let a = 2; } }", nil},
+		{"fn(x, y, x) { x }", []string{"1:10: duplicate parameter x"}},
+	}
+
+	for _, tt := range tests {
+		_, errs := Program(parse(tt.input))
+		var found []string
+		for _, err := range errs {
+			found = append(found, err.Error())
+		}
+		if fmt.Sprint(found) != fmt.Sprint(tt.expected) {
+			t.Errorf("wrong errors for %q. expected=%q, found=%q", tt.input,
+				tt.expected, found)
+		}
+	}
+}
+
+func TestBindings(t *testing.T) {
+	input := `let total = fn(xs) {
+  let iter = fn(i, sum) {
+    if (i == len(xs)) { return sum; }
+    iter(i + 1, sum + xs[i])
+  };
+  iter(0, 0)
+};
+total([1, 2])`
+	tests := []struct {
+		pos          token.Position // of the identifier
+		expectedKind Kind
+		expectedDecl token.Position // of the declaration
+		expected     compiler.Symbol
+	}{
+		{pos(1, 5), Global, pos(1, 5),
+			compiler.Symbol{Name: "total", Scope: compiler.GlobalScope}},
+		{pos(1, 16), Parameter, pos(1, 16),
+			compiler.Symbol{Name: "xs", Scope: compiler.LocalScope}},
+		{pos(2, 17), Parameter, pos(2, 17),
+			compiler.Symbol{Name: "i", Scope: compiler.LocalScope}},
+		{pos(3, 9), Parameter, pos(2, 17),
+			compiler.Symbol{Name: "i", Scope: compiler.LocalScope}},
+		{pos(3, 14), Builtin, token.Position{},
+			compiler.Symbol{Name: "len", Scope: compiler.BuiltinScope}},
+		{pos(3, 18), Free, pos(1, 16),
+			compiler.Symbol{Name: "xs", Scope: compiler.FreeScope}},
+		{pos(3, 33), Parameter, pos(2, 20),
+			compiler.Symbol{Name: "sum", Scope: compiler.LocalScope, Index: 1}},
+		{pos(4, 5), Free, pos(2, 7),
+			compiler.Symbol{Name: "iter", Scope: compiler.FreeScope, Index: 1}},
+		{pos(6, 3), Local, pos(2, 7),
+			compiler.Symbol{Name: "iter", Scope: compiler.LocalScope, Index: 1}},
+		{pos(8, 1), Global, pos(1, 5),
+			compiler.Symbol{Name: "total", Scope: compiler.GlobalScope}},
+	}
+
+	info, errs := Program(parse(input))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	for _, tt := range tests {
+		ident := info.At(tt.pos)
+		if ident == nil {
+			t.Errorf("no identifier at %s", tt.pos)
+			continue
+		}
+		b := info.Bindings[ident]
+		if b.Kind != tt.expectedKind {
+			t.Errorf("wrong kind for %s at %s. expected=%s, found=%s",
+				ident.Value, tt.pos, tt.expectedKind, b.Kind)
+		}
+		if b.Decl.Pos() != tt.expectedDecl {
+			t.Errorf("wrong declaration for %s at %s. expected=%s, found=%s",
+				ident.Value, tt.pos, tt.expectedDecl, b.Decl.Pos())
+		}
+		if b.Symbol != tt.expected {
+			t.Errorf("wrong symbol for %s at %s. expected=%+v, found=%+v",
+				ident.Value, tt.pos, tt.expected, b.Symbol)
+		}
+	}
+}
+
+func TestDeclarations(t *testing.T) {
+	input := `let a = 1;
+let f = fn(b) { let c = a + b; let a = c; a };
+let a = f(len([]));
+puts(a);`
+	expected := []string{
+		"global a 1:5 uses=[2:25 4:6]",
+		"global f 2:5 uses=[3:9]",
+		"parameter b 2:12 uses=[2:29]",
+		"local c 2:21 uses=[2:40]",
+		"local a 2:36 uses=[2:43]",
+		"builtin len - uses=[3:11]",
+		"builtin puts - uses=[4:1]",
+	}
+
+	info, _ := Program(parse(input))
+	var found []string
+	for _, d := range info.Declarations {
+		var uses []string
+		for _, u := range d.Uses {
+			uses = append(uses, u.Token.Pos.String())
+		}
+		found = append(found, fmt.Sprintf("%s %s %s uses=[%s]", d.Kind, d.Name,
+			d.Pos(), strings.Join(uses, " ")))
+	}
+	if fmt.Sprint(found) != fmt.Sprint(expected) {
+		t.Errorf("wrong declarations.\nexpected=%q\nfound=%q", expected, found)
+	}
+}
+
+func pos(line, column int) token.Position {
+	return token.Position{Line: line, Column: column}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
@@ -5,10 +5,32 @@ import "fmt"
 // TokenType represents the type of lexical token.
 type TokenType string
 
+// Position is the location of a token in program text. Lines and columns both
+// start at 1; the zero Position means the location is unknown.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the Position refers to an actual location.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the Position in the form "line:column", or "-" if the
+// Position is not valid.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 // Token represents a lexical token, such as a keyword, operator, or semicolon.
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // where the token starts in the program text
 }
 
 // Returns the string value of this Token.
@@ -20,10 +42,12 @@ const (
 	// Miscellaneous
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT"
 
 	// Identifiers & literals
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	STRING = "STRING"
 
 	// Operators
 	ASSIGN   = "="
@@ -40,12 +64,15 @@ const (
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
 
 	// Parentheses and Brackets
-	LPAREN = "("
-	RPAREN = ")"
-	LBRACE = "{"
-	RBRACE = "}"
+	LPAREN   = "("
+	RPAREN   = ")"
+	LBRACE   = "{"
+	RBRACE   = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
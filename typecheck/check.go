@@ -0,0 +1,632 @@
+package typecheck
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/resolve"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// Info holds the results of checking a program.
+type Info struct {
+	// Types holds the type of each expression, including the identifiers
+	// in let statements and parameter lists. The type of an identifier
+	// referring to a polymorphic function is that of the use, with the
+	// function's variables replaced.
+	Types map[ast.Expression]Type
+}
+
+// Error is a type mismatch found in a program.
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Message
+}
+
+// Program infers the types of the expressions in program, checking them
+// against its annotations and the operators and functions they are used with.
+// It returns the mismatches found, sorted by position.
+func Program(program *ast.Program) (*Info, []*Error) {
+	res, _ := resolve.Program(program)
+	c := &checker{
+		info:      &Info{Types: make(map[ast.Expression]Type)},
+		resolved:  res,
+		variables: make(map[*resolve.Declaration]*variable),
+		lets:      make(map[*resolve.Declaration]int),
+	}
+	c.countLets(program)
+	c.declare(program.Statements)
+	for _, s := range program.Statements {
+		c.statement(s)
+	}
+	c.finish()
+	return c.info, c.errs
+}
+
+// checker holds the state of the checking of a program.
+type checker struct {
+	info     *Info
+	errs     []*Error
+	resolved *resolve.Info
+
+	variables map[*resolve.Declaration]*variable
+	// lets holds the number of let statements binding each variable.
+	lets map[*resolve.Declaration]int
+
+	fn *function // the innermost function being checked, if any
+	// level is the number of let statements being checked whose values
+	// may be generalized.
+	level   int
+	changes []change // the changes made by unification, for undoing them
+
+	// sums holds the operands of the + operators, which must be integers
+	// or strings, to be checked once their types are known.
+	sums []*ast.InfixExpression
+}
+
+// variable holds what is known about the type of a variable.
+type variable struct {
+	t      Type
+	scheme *scheme // the type of the variable, if it is polymorphic
+
+	defined  bool // whether a let statement binding it has been checked
+	defining bool // whether the value of its let statement is being checked
+	early    bool // whether it is used before it is defined
+}
+
+// function holds the state of the checking of a function literal.
+type function struct {
+	result    Type
+	annotated bool   // whether the result type is annotated
+	returns   []Type // the types of the values returned, if it isn't
+}
+
+func (c *checker) errorf(pos token.Position, format string, a ...interface{}) {
+	c.errs = append(c.errs, &Error{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+// countLets counts the let statements binding each variable in program.
+func (c *checker) countLets(program *ast.Program) {
+	var visit func(node ast.Node)
+	visit = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			if b, ok := c.resolved.Bindings[node.Name]; ok {
+				c.lets[b.Decl]++
+			}
+			visit(node.Value)
+		case *ast.ExpressionStatement:
+			visit(node.Expression)
+		case *ast.ReturnStatement:
+			visit(node.ReturnValue)
+		case *ast.BlockStatement:
+			for _, s := range node.Statements {
+				visit(s)
+			}
+		case *ast.IfExpression:
+			visit(node.Condition)
+			visit(node.Consequence)
+			if node.Alternative != nil {
+				visit(node.Alternative)
+			}
+		case *ast.PrefixExpression:
+			visit(node.Right)
+		case *ast.InfixExpression:
+			visit(node.Left)
+			visit(node.Right)
+		case *ast.FunctionLiteral:
+			visit(node.Body)
+		case *ast.CallExpression:
+			visit(node.Function)
+			for _, a := range node.Arguments {
+				visit(a)
+			}
+		case *ast.ArrayLiteral:
+			for _, el := range node.Elements {
+				visit(el)
+			}
+		case *ast.HashLiteral:
+			for _, pair := range node.Pairs {
+				visit(pair.Key)
+				visit(pair.Value)
+			}
+		case *ast.IndexExpression:
+			visit(node.Left)
+			visit(node.Index)
+		}
+	}
+	for _, s := range program.Statements {
+		visit(s)
+	}
+}
+
+// declare gives a type to each variable bound by the let statements in stmts,
+// the body of a function or the top level of the program, other than those
+// in function literals, so that they may be used before they are bound.
+func (c *checker) declare(stmts []ast.Statement) {
+	for _, s := range stmts {
+		c.declareNode(s)
+	}
+}
+
+func (c *checker) declareNode(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		if b, ok := c.resolved.Bindings[node.Name]; ok &&
+			c.variables[b.Decl] == nil {
+			c.variables[b.Decl] = &variable{t: c.fresh()}
+		}
+		c.declareNode(node.Value)
+	case *ast.ExpressionStatement:
+		c.declareNode(node.Expression)
+	case *ast.ReturnStatement:
+		c.declareNode(node.ReturnValue)
+	case *ast.BlockStatement:
+		c.declare(node.Statements)
+	case *ast.IfExpression:
+		c.declareNode(node.Condition)
+		c.declareNode(node.Consequence)
+		if node.Alternative != nil {
+			c.declareNode(node.Alternative)
+		}
+	case *ast.PrefixExpression:
+		c.declareNode(node.Right)
+	case *ast.InfixExpression:
+		c.declareNode(node.Left)
+		c.declareNode(node.Right)
+	case *ast.CallExpression:
+		c.declareNode(node.Function)
+		for _, a := range node.Arguments {
+			c.declareNode(a)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			c.declareNode(el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			c.declareNode(pair.Key)
+			c.declareNode(pair.Value)
+		}
+	case *ast.IndexExpression:
+		c.declareNode(node.Left)
+		c.declareNode(node.Index)
+	}
+}
+
+// finish checks the operands of the + operators, sorts the errors and
+// replaces the variables in the recorded types by the types they were
+// unified with.
+func (c *checker) finish() {
+	for _, ie := range c.sums {
+		switch t := prune(c.info.Types[ie.Left]); t {
+		case Int, String, Any:
+		default:
+			if _, ok := t.(*Var); !ok {
+				c.errorf(ie.Token.Pos, "unknown operator: %s + %s", t, t)
+			}
+		}
+	}
+	sort.SliceStable(c.errs, func(i, j int) bool {
+		a, b := c.errs[i].Pos, c.errs[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	for expr, t := range c.info.Types {
+		c.info.Types[expr] = resolved(t)
+	}
+}
+
+func (c *checker) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		c.let(s)
+	case *ast.ReturnStatement:
+		t := c.expression(s.ReturnValue)
+		if c.fn != nil && !c.fn.annotated {
+			c.fn.returns = append(c.fn.returns, t)
+		} else if c.fn != nil && !c.unify(c.fn.result, t) {
+			c.errorf(ast.Pos(s.ReturnValue), "cannot use %s as %s in return",
+				t, c.fn.result)
+		}
+	case *ast.ExpressionStatement:
+		c.expression(s.Expression)
+	}
+}
+
+// let checks a let statement. The variable bound is polymorphic if the value
+// is a function literal, the statement is the only one binding the variable,
+// and the variable isn't used before it.
+func (c *checker) let(s *ast.LetStatement) {
+	b, ok := c.resolved.Bindings[s.Name]
+	if !ok {
+		c.expression(s.Value)
+		return
+	}
+	v := c.variables[b.Decl]
+	_, isFunction := s.Value.(*ast.FunctionLiteral)
+	if isFunction && s.Type == nil && c.lets[b.Decl] == 1 &&
+		b.Decl.Kind != resolve.Parameter && !v.early {
+		c.level++
+		v.t = c.fresh()
+		v.defining = true
+		t := c.expression(s.Value)
+		c.unify(v.t, t)
+		v.defining = false
+		c.level--
+		v.scheme = c.generalize(t)
+		v.defined = true
+		c.info.Types[s.Name] = t
+		return
+	}
+
+	t := c.expression(s.Value)
+	if s.Type != nil {
+		annotation := c.annotation(s.Type)
+		if !c.unify(annotation, t) {
+			c.errorf(ast.Pos(s.Value), "cannot use %s as %s in let %s", t,
+				annotation, s.Name.Value)
+		}
+		t = annotation
+	}
+	if !c.unify(v.t, t) {
+		c.errorf(ast.Pos(s.Value), "cannot use %s as %s in let %s", t, v.t,
+			s.Name.Value)
+	}
+	v.defined = true
+	c.info.Types[s.Name] = v.t
+}
+
+// annotation returns the type an annotation stands for.
+func (c *checker) annotation(a ast.Type) Type {
+	switch a := a.(type) {
+	case *ast.TypeName:
+		switch a.Name {
+		case "int":
+			return Int
+		case "bool":
+			return Bool
+		case "string":
+			return String
+		case "null":
+			return Null
+		case "any":
+			return Any
+		}
+		c.errorf(a.Token.Pos, "unknown type %s", a.Name)
+		return Any
+	case *ast.ArrayType:
+		return &Array{Elem: c.annotation(a.Element)}
+	case *ast.HashType:
+		return &Hash{Key: c.annotation(a.Key), Value: c.annotation(a.Value)}
+	case *ast.FunctionType:
+		params := make([]Type, len(a.Parameters))
+		for i, p := range a.Parameters {
+			params[i] = c.annotation(p)
+		}
+		return &Function{Params: params, Result: c.annotation(a.Result)}
+	}
+	return Any
+}
+
+// block checks a sequence of statements, returning the type of its value:
+// that of its last statement, if it is an expression statement, or null. The
+// value of a sequence holding a return statement is never used, so it may
+// have any type.
+func (c *checker) block(stmts []ast.Statement) Type {
+	returns := false
+	for _, s := range stmts {
+		c.statement(s)
+		if _, ok := s.(*ast.ReturnStatement); ok {
+			returns = true
+		}
+	}
+	if returns {
+		return c.fresh()
+	}
+	if len(stmts) == 0 {
+		return Null
+	}
+	if s, ok := stmts[len(stmts)-1].(*ast.ExpressionStatement); ok {
+		return c.info.Types[s.Expression]
+	}
+	return Null
+}
+
+// expression returns the type of expr, recording it.
+func (c *checker) expression(expr ast.Expression) Type {
+	t := c.infer(expr)
+	c.info.Types[expr] = t
+	return t
+}
+
+func (c *checker) infer(expr ast.Expression) Type {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.StringLiteral:
+		return String
+	case *ast.Boolean:
+		return Bool
+	case *ast.Identifier:
+		return c.identifier(expr)
+	case *ast.PrefixExpression:
+		return c.prefix(expr)
+	case *ast.InfixExpression:
+		return c.infix(expr)
+	case *ast.IfExpression:
+		return c.ifExpression(expr)
+	case *ast.FunctionLiteral:
+		return c.function(expr)
+	case *ast.CallExpression:
+		return c.call(expr)
+	case *ast.ArrayLiteral:
+		elem := Type(c.fresh())
+		for _, el := range expr.Elements {
+			if t := c.expression(el); !c.unify(elem, t) {
+				// The elements have different types.
+				elem = Any
+			}
+		}
+		return &Array{Elem: elem}
+	case *ast.HashLiteral:
+		key, value := Type(c.fresh()), Type(c.fresh())
+		for _, pair := range expr.Pairs {
+			k := c.expression(pair.Key)
+			if !hashable(k) {
+				c.errorf(ast.Pos(pair.Key), "unusable as hash key: %s", k)
+			} else if !c.unify(key, k) {
+				key = Any
+			}
+			if !c.unify(value, c.expression(pair.Value)) {
+				value = Any
+			}
+		}
+		return &Hash{Key: key, Value: value}
+	case *ast.IndexExpression:
+		return c.index(expr)
+	}
+	return Any
+}
+
+// hashable reports whether values of type t may be hash keys.
+func hashable(t Type) bool {
+	switch prune(t).(type) {
+	case *Array, *Hash, *Function:
+		return false
+	}
+	return prune(t) != Null
+}
+
+// identifier returns the type of the variable ident refers to. The
+// identifiers that refer to no variable have type Any.
+func (c *checker) identifier(ident *ast.Identifier) Type {
+	b, ok := c.resolved.Bindings[ident]
+	if !ok {
+		return Any
+	}
+	if b.Kind == resolve.Builtin {
+		return c.builtin(ident.Value)
+	}
+	v := c.variables[b.Decl]
+	if v == nil {
+		return Any
+	}
+	if v.scheme != nil {
+		return c.instantiate(v.scheme)
+	}
+	if !v.defined && !v.defining {
+		v.early = true
+	}
+	return v.t
+}
+
+// builtin returns the type of the builtin called name. The builtins this
+// package doesn't know, and puts and assert, which take varying numbers of
+// arguments, have type Any.
+func (c *checker) builtin(name string) Type {
+	switch name {
+	case "len":
+		return &Function{Params: []Type{Any}, Result: Int}
+	case "first", "last":
+		a := c.fresh()
+		return &Function{Params: []Type{&Array{Elem: a}}, Result: a}
+	case "rest":
+		a := c.fresh()
+		return &Function{Params: []Type{&Array{Elem: a}}, Result: &Array{Elem: a}}
+	case "push":
+		a := c.fresh()
+		return &Function{Params: []Type{&Array{Elem: a}, a},
+			Result: &Array{Elem: a}}
+	case "readFile":
+		return &Function{Params: []Type{String}, Result: String}
+	case "now":
+		return &Function{Params: []Type{}, Result: Int}
+	case "assertEqual":
+		a := c.fresh()
+		return &Function{Params: []Type{a, a}, Result: Null}
+	case "assertError":
+		f := &Function{Params: []Type{}, Result: Any}
+		return &Function{Params: []Type{f}, Result: String}
+	}
+	return Any
+}
+
+func (c *checker) prefix(expr *ast.PrefixExpression) Type {
+	t := c.expression(expr.Right)
+	switch expr.Operator {
+	case "-":
+		if !c.unify(t, Int) {
+			c.errorf(expr.Token.Pos, "unknown operator: -%s", t)
+			return Any
+		}
+		return Int
+	case "!":
+		return Bool
+	}
+	return Any
+}
+
+// infix returns the type of an infix expression. As when the program runs,
+// operands of different types are a type mismatch, and operands of the same
+// type that the operator doesn't apply to are an unknown operator.
+func (c *checker) infix(expr *ast.InfixExpression) Type {
+	left := c.expression(expr.Left)
+	right := c.expression(expr.Right)
+	if !c.unify(left, right) {
+		c.errorf(expr.Token.Pos, "type mismatch: %s %s %s", left,
+			expr.Operator, right)
+		return Any
+	}
+	switch expr.Operator {
+	case "+":
+		c.sums = append(c.sums, expr)
+		return left
+	case "-", "*", "/", "<", ">":
+		if !c.unify(left, Int) {
+			c.errorf(expr.Token.Pos, "unknown operator: %s %s %s", left,
+				expr.Operator, right)
+			return Any
+		}
+		if expr.Operator == "<" || expr.Operator == ">" {
+			return Bool
+		}
+		return Int
+	case "==", "!=":
+		return Bool
+	}
+	return Any
+}
+
+// ifExpression returns the type of an if expression: that of its branches,
+// if they have the same type, or Any. An if expression without an else branch
+// may be null, so it has type Any too.
+func (c *checker) ifExpression(expr *ast.IfExpression) Type {
+	c.expression(expr.Condition)
+	consequence := c.block(expr.Consequence.Statements)
+	if expr.Alternative == nil {
+		return Any
+	}
+	alternative := c.block(expr.Alternative.Statements)
+	if !c.unify(consequence, alternative) {
+		return Any
+	}
+	return consequence
+}
+
+func (c *checker) function(fl *ast.FunctionLiteral) Type {
+	t := &Function{Params: make([]Type, len(fl.Parameters))}
+	for i, p := range fl.Parameters {
+		var pt Type
+		if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+			pt = c.annotation(fl.ParameterTypes[i])
+		} else {
+			pt = c.fresh()
+		}
+		t.Params[i] = pt
+		c.info.Types[p] = pt
+		if b, ok := c.resolved.Bindings[p]; ok {
+			c.variables[b.Decl] = &variable{t: pt, defined: true}
+		}
+	}
+	if fl.ReturnType != nil {
+		t.Result = c.annotation(fl.ReturnType)
+	} else {
+		t.Result = c.fresh()
+	}
+
+	outer := c.fn
+	c.fn = &function{result: t.Result, annotated: fl.ReturnType != nil}
+	c.declare(fl.Body.Statements)
+	body := c.block(fl.Body.Statements)
+	if !c.fn.annotated {
+		// Like the branches of an if expression, the values the function
+		// may return have type any unless they have the same type.
+		for _, r := range c.fn.returns {
+			if !c.unify(body, r) {
+				body = Any
+				break
+			}
+		}
+	}
+	if !c.unify(t.Result, body) {
+		pos := ast.Pos(fl.Body)
+		if n := len(fl.Body.Statements); n > 0 {
+			pos = ast.Pos(fl.Body.Statements[n-1])
+		}
+		c.errorf(pos, "cannot use %s as %s in function result", body, t.Result)
+	}
+	c.fn = outer
+	return t
+}
+
+// call returns the type of a call expression, checking the arguments against
+// the parameters of the function called.
+func (c *checker) call(expr *ast.CallExpression) Type {
+	callee := c.expression(expr.Function)
+	args := make([]Type, len(expr.Arguments))
+	for i, a := range expr.Arguments {
+		args[i] = c.expression(a)
+	}
+	switch f := prune(callee).(type) {
+	case *Function:
+		if len(f.Params) != len(args) {
+			c.errorf(ast.Pos(expr),
+				"wrong number of arguments: expected %d, found %d",
+				len(f.Params), len(args))
+			return Any
+		}
+		for i, a := range args {
+			if !c.unify(f.Params[i], a) {
+				c.errorf(ast.Pos(expr.Arguments[i]),
+					"cannot use %s as %s in argument %d to %s", a, f.Params[i],
+					i+1, expr.Function)
+			}
+		}
+		return f.Result
+	case *Var:
+		result := c.fresh()
+		c.unify(f, &Function{Params: args, Result: result})
+		return result
+	case *Basic:
+		if f != Any {
+			c.errorf(ast.Pos(expr), "not a function: %s", f)
+		}
+		return Any
+	default:
+		c.errorf(ast.Pos(expr), "not a function: %s", f)
+		return Any
+	}
+}
+
+// index returns the type of an index expression. An array must be indexed by
+// an integer; a hash yields values of its value type. Indexing a value whose
+// type isn't known yet has type Any.
+func (c *checker) index(expr *ast.IndexExpression) Type {
+	left := c.expression(expr.Left)
+	index := c.expression(expr.Index)
+	switch l := prune(left).(type) {
+	case *Array:
+		if !c.unify(index, Int) {
+			c.errorf(ast.Pos(expr), "index operator not supported: %s[%s]",
+				left, index)
+			return Any
+		}
+		return l.Elem
+	case *Hash:
+		c.unify(l.Key, index)
+		return l.Value
+	case *Var:
+		return Any
+	default:
+		if l != Any {
+			c.errorf(ast.Pos(expr), "index operator not supported: %s[%s]",
+				left, index)
+		}
+		return Any
+	}
+}
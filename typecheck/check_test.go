@@ -0,0 +1,122 @@
+package typecheck
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+func TestTypes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the type of the last let statement's name
+	}{
+		{"let x = 5;", "int"},
+		{`let x = "a" + "b";`, "string"},
+		{"let x = 1 < 2;", "bool"},
+		{"let x = [1, 2];", "[int]"},
+		{`let x = [1, "two"];`, "[any]"},
+		{"let x = [];", "[a]"},
+		{`let x = {"a": 1}["a"];`, "int"},
+		{"let x = puts(1);", "any"},
+		{"let x = if (true) { 1 };", "any"},
+		{"let x = if (true) { 1 } else { 2 };", "int"},
+		{"let id = fn(x) { x };", "fn(a): a"},
+		{"let add = fn(a, b) { a + b };", "fn(a, a): a"},
+		{"let inc = fn(a) { a + 1 };", "fn(int): int"},
+		{"let f = fn(xs) { push(xs, first(xs) * 2) };", "fn([int]): [int]"},
+		{"let f = fn(a: string, b): bool { a == b };", "fn(string, string): bool"},
+		{"let x: [any] = [];", "[any]"},
+		{"let id = fn(x) { x }; let x = [id(1), id(2)];", "[int]"},
+		{"let x = assertError(fn() { 1 / 0 });", "string"},
+		{"let id = fn(x) { x }; let x = id(id)(true);", "bool"},
+		{`let fact = fn(n) { if (n == 0) { return 1; } n * fact(n - 1) };`,
+			"fn(int): int"},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };`,
+			"fn(int): bool"},
+		{"let f = fn() { let g = fn(x) { [x] }; g(1) };", "fn(): [int]"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		info, errs := Program(program)
+		if len(errs) != 0 {
+			t.Errorf("unexpected errors for %q: %v", tt.input, errs)
+			continue
+		}
+		last := program.Statements[len(program.Statements)-1].(*ast.LetStatement)
+		if found := info.Types[last.Name].String(); found != tt.expected {
+			t.Errorf("wrong type for %q. expected=%q, found=%q", tt.input,
+				tt.expected, found)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1 + true", []string{"1:3: type mismatch: int + bool"}},
+		{`"a" - "b"`, []string{"1:5: unknown operator: string - string"}},
+		{"[1] + [2]", []string{"1:5: unknown operator: [int] + [int]"}},
+		{`-"a"; !"a"`, []string{"1:1: unknown operator: -string"}},
+		{"1 == true", []string{"1:3: type mismatch: int == bool"}},
+		{`let x: int = "a";`, []string{"1:14: cannot use string as int in let x"}},
+		{`let x = 1;` + "\n" + `let x = "a";`,
+			[]string{"2:9: cannot use string as int in let x"}},
+		{"let x: float = 1;", []string{"1:8: unknown type float"}},
+		{"fn(a: int): bool { a }",
+			[]string{"1:20: cannot use int as bool in function result"}},
+		{`fn(): int { if (true) { return "s"; } 1 }`,
+			[]string{"1:32: cannot use string as int in return"}},
+		{`let f = fn(x) { if (x) { return 1; } "one" };` + "\n" +
+			`f(true) + 1; f(false) + "s"`, nil},
+		{`let g = fn(x) { if (x) { return 1; } 2 };` + "\n" + `g(true) + "s"`,
+			[]string{"2:9: type mismatch: int + string"}},
+		{"let f = fn(a: string) { a };\nf(1)",
+			[]string{"2:3: cannot use int as string in argument 1 to f"}},
+		{"let inc = fn(x) { x + 1 };\ninc(true)",
+			[]string{"2:5: cannot use bool as int in argument 1 to inc"}},
+		{"len([1], 2)", []string{"1:1: wrong number of arguments: expected 1, found 2"}},
+		{"first(1)", []string{"1:7: cannot use int as [a] in argument 1 to first"}},
+		{`assertEqual(len("ab"), "2")`,
+			[]string{"1:24: cannot use string as int in argument 2 to assertEqual"}},
+		{"1(2)", []string{"1:1: not a function: int"}},
+		{"let f = fn(g) { g(1) + g(true) };",
+			[]string{"1:26: cannot use bool as int in argument 1 to g"}},
+		{"[1][true]", []string{"1:1: index operator not supported: [int][bool]"}},
+		{`"abc"[0]`, []string{"1:1: index operator not supported: string[int]"}},
+		{"{[1]: 2}", []string{"1:2: unusable as hash key: [int]"}},
+		{`let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } };
+fact("ten")`,
+			[]string{`2:6: cannot use string as int in argument 1 to fact`}},
+		// Mismatches the program may not run into aren't reported.
+		{`let x = if (true) { 1 } else { "one" }; x + 1`, nil},
+		{`let xs = [1, "two"]; xs[0] + 1; xs[1] + "three"`, nil},
+		{`puts(1, "a", [true]); undefined + 1`, nil},
+		{`fn(x) { x[0] + x["a"] }`, nil},
+	}
+
+	for _, tt := range tests {
+		_, errs := Program(parse(tt.input))
+		var found []string
+		for _, err := range errs {
+			found = append(found, err.Error())
+		}
+		if fmt.Sprint(found) != fmt.Sprint(tt.expected) {
+			t.Errorf("wrong errors for %q.\nexpected=%q\nfound=%q", tt.input,
+				tt.expected, found)
+		}
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
@@ -0,0 +1,38 @@
+// Package typecheck infers the types of the expressions of Monkey programs and
+// reports the type mismatches that would make them fail when they run, such
+// as "1 + true":
+//  info, errs := typecheck.Program(program)
+//  for _, err := range errs {
+//  	fmt.Println(err) // e.g. "1:3: type mismatch: int + bool"
+//  }
+//
+// Types are int, bool, string and null; arrays, such as [int], whose
+// elements have the same type; hashes, such as {string: int}, whose keys and
+// values each have the same type; and functions, such as fn(int, int): bool.
+// Let statements and function parameters and results may be annotated with
+// types, as in "let x: int = 5;" or "fn(a: int, b: string): bool { ... }".
+// Annotations don't change what programs do: the evaluator, the compiler and
+// the translators ignore them.
+//
+// The types of unannotated code are inferred, as in Hindley–Milner type
+// systems. A variable has a single type, given by all the let statements
+// binding it and all its uses. A variable bound by a single let statement to
+// a function literal, and not used before it, is polymorphic: each use may
+// give its type variables different types, so that, after
+// "let id = fn(x) { x };", both "id(1)" and "id(true)" are accepted. The
+// types of the builtins first, last, rest, push and assertEqual are
+// polymorphic too. The requirement that the operands of + be integers or
+// strings isn't part of the types of polymorphic functions, so that, after
+// "let add = fn(a, b) { a + b };", the failure of "add(true, false)" is only
+// found when the program runs.
+//
+// Monkey programs may use values of different types where a type system
+// wouldn't allow it, and still run without failing. So that such programs
+// aren't reported, the values whose types can't be known have type any, which
+// matches every type: arrays and hashes holding values of different types hold
+// values of type any, as do if expressions whose branches have different types
+// or that have no else branch, functions without annotated results whose
+// return statements and last expressions have different types, indexes of
+// values whose types aren't known yet, and calls of puts and of builtins
+// registered by package monkey. The annotation "any" may be used too.
+package typecheck
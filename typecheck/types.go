@@ -0,0 +1,147 @@
+package typecheck
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Type is the type of a Monkey value: a *Basic, *Array, *Hash or *Function
+// type, or a *Var standing for a type that isn't known.
+type Type interface {
+	String() string
+}
+
+// Basic is a type without parts.
+type Basic struct {
+	name string
+}
+
+// The basic types. Any is the type of values whose types can't be inferred,
+// such as the elements of an array holding both integers and strings; it
+// matches every type.
+var (
+	Int    = &Basic{"int"}
+	Bool   = &Basic{"bool"}
+	String = &Basic{"string"}
+	Null   = &Basic{"null"}
+	Any    = &Basic{"any"}
+)
+
+func (b *Basic) String() string { return b.name }
+
+// Array is the type of arrays whose elements have type Elem.
+type Array struct {
+	Elem Type
+}
+
+func (a *Array) String() string { return format(a) }
+
+// Hash is the type of hashes whose keys have type Key and whose values have
+// type Value.
+type Hash struct {
+	Key, Value Type
+}
+
+func (h *Hash) String() string { return format(h) }
+
+// Function is the type of functions taking parameters of types Params and
+// returning values of type Result.
+type Function struct {
+	Params []Type
+	Result Type
+}
+
+func (f *Function) String() string { return format(f) }
+
+// Var is a type variable, standing for a type that isn't known. A function
+// whose type holds variables is polymorphic: "fn(a): a" is the type of a
+// function returning its argument, whatever its type.
+type Var struct {
+	level int  // the depth of let statements at which the variable appeared
+	ref   Type // the type the variable has been unified with, if any
+}
+
+func (v *Var) String() string { return format(v) }
+
+// prune returns the type t stands for, following the variables that have been
+// unified with other types.
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.ref == nil {
+			return t
+		}
+		t = v.ref
+	}
+}
+
+// format writes t, naming its variables a, b, c and so on, in the order they
+// appear.
+func format(t Type) string {
+	var out bytes.Buffer
+	writeType(&out, t, make(map[*Var]string))
+	return out.String()
+}
+
+func writeType(out *bytes.Buffer, t Type, names map[*Var]string) {
+	switch t := prune(t).(type) {
+	case *Var:
+		name, ok := names[t]
+		if !ok {
+			name = varName(len(names))
+			names[t] = name
+		}
+		out.WriteString(name)
+	case *Array:
+		out.WriteString("[")
+		writeType(out, t.Elem, names)
+		out.WriteString("]")
+	case *Hash:
+		out.WriteString("{")
+		writeType(out, t.Key, names)
+		out.WriteString(": ")
+		writeType(out, t.Value, names)
+		out.WriteString("}")
+	case *Function:
+		out.WriteString("fn(")
+		for i, p := range t.Params {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			writeType(out, p, names)
+		}
+		out.WriteString("): ")
+		writeType(out, t.Result, names)
+	default:
+		out.WriteString(t.String())
+	}
+}
+
+// varName returns the name of the nth variable of a type: a through z, then
+// a1 through z1, and so on.
+func varName(n int) string {
+	name := string(rune('a' + n%26))
+	if n >= 26 {
+		name += fmt.Sprint(n / 26)
+	}
+	return name
+}
+
+// resolved returns t with the variables that have been unified with other
+// types replaced by those types.
+func resolved(t Type) Type {
+	switch t := prune(t).(type) {
+	case *Array:
+		return &Array{Elem: resolved(t.Elem)}
+	case *Hash:
+		return &Hash{Key: resolved(t.Key), Value: resolved(t.Value)}
+	case *Function:
+		params := make([]Type, len(t.Params))
+		for i, p := range t.Params {
+			params[i] = resolved(p)
+		}
+		return &Function{Params: params, Result: resolved(t.Result)}
+	default:
+		return t
+	}
+}
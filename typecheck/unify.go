@@ -0,0 +1,180 @@
+package typecheck
+
+// change records a change made to a variable by unification, so that it can
+// be undone: the binding of the variable, or the lowering of its level from
+// level.
+type change struct {
+	v     *Var
+	bound bool
+	level int
+}
+
+// fresh returns a new variable at the current level.
+func (c *checker) fresh() *Var {
+	return &Var{level: c.level}
+}
+
+// unify makes a and b the same type, by binding their variables, reporting
+// whether it could. If it couldn't, a and b are left as they were.
+func (c *checker) unify(a, b Type) bool {
+	mark := len(c.changes)
+	if c.unifyTypes(a, b) {
+		return true
+	}
+	for i := len(c.changes) - 1; i >= mark; i-- {
+		ch := c.changes[i]
+		if ch.bound {
+			ch.v.ref = nil
+		} else {
+			ch.v.level = ch.level
+		}
+	}
+	c.changes = c.changes[:mark]
+	return false
+}
+
+func (c *checker) unifyTypes(a, b Type) bool {
+	a, b = prune(a), prune(b)
+	if a == b {
+		return true
+	}
+	if v, ok := a.(*Var); ok {
+		return c.bind(v, b)
+	}
+	if v, ok := b.(*Var); ok {
+		return c.bind(v, a)
+	}
+	if a == Any || b == Any {
+		return true
+	}
+	switch a := a.(type) {
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && c.unifyTypes(a.Elem, b.Elem)
+	case *Hash:
+		b, ok := b.(*Hash)
+		return ok && c.unifyTypes(a.Key, b.Key) && c.unifyTypes(a.Value, b.Value)
+	case *Function:
+		b, ok := b.(*Function)
+		if !ok || len(a.Params) != len(b.Params) {
+			return false
+		}
+		for i := range a.Params {
+			if !c.unifyTypes(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+		return c.unifyTypes(a.Result, b.Result)
+	}
+	return false
+}
+
+// bind binds v to t, unless t holds v. The variables in t are lowered to the
+// level of v, so that they aren't generalized while v may be used.
+func (c *checker) bind(v *Var, t Type) bool {
+	if c.occurs(v, t) {
+		return false
+	}
+	v.ref = t
+	c.changes = append(c.changes, change{v: v, bound: true})
+	return true
+}
+
+// occurs reports whether v appears in t, lowering the levels of the other
+// variables in t to that of v.
+func (c *checker) occurs(v *Var, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Var:
+		if t == v {
+			return true
+		}
+		if t.level > v.level {
+			c.changes = append(c.changes, change{v: t, level: t.level})
+			t.level = v.level
+		}
+	case *Array:
+		return c.occurs(v, t.Elem)
+	case *Hash:
+		return c.occurs(v, t.Key) || c.occurs(v, t.Value)
+	case *Function:
+		for _, p := range t.Params {
+			if c.occurs(v, p) {
+				return true
+			}
+		}
+		return c.occurs(v, t.Result)
+	}
+	return false
+}
+
+// scheme is the type of a polymorphic variable: a type in which the variables
+// vars stand for any types, so that each use of the variable may give them
+// different ones.
+type scheme struct {
+	vars []*Var
+	t    Type
+}
+
+// generalize returns the scheme of t in which the variables that appeared
+// within the current level stand for any types.
+func (c *checker) generalize(t Type) *scheme {
+	s := &scheme{t: t}
+	seen := make(map[*Var]bool)
+	var collect func(t Type)
+	collect = func(t Type) {
+		switch t := prune(t).(type) {
+		case *Var:
+			if t.level > c.level && !seen[t] {
+				seen[t] = true
+				s.vars = append(s.vars, t)
+			}
+		case *Array:
+			collect(t.Elem)
+		case *Hash:
+			collect(t.Key)
+			collect(t.Value)
+		case *Function:
+			for _, p := range t.Params {
+				collect(p)
+			}
+			collect(t.Result)
+		}
+	}
+	collect(t)
+	return s
+}
+
+// instantiate returns the type of s with new variables in place of those that
+// stand for any types.
+func (c *checker) instantiate(s *scheme) Type {
+	if len(s.vars) == 0 {
+		return s.t
+	}
+	fresh := make(map[*Var]Type, len(s.vars))
+	for _, v := range s.vars {
+		fresh[v] = c.fresh()
+	}
+	var copyType func(t Type) Type
+	copyType = func(t Type) Type {
+		switch t := prune(t).(type) {
+		case *Var:
+			if f, ok := fresh[t]; ok {
+				return f
+			}
+			return t
+		case *Array:
+			return &Array{Elem: copyType(t.Elem)}
+		case *Hash:
+			return &Hash{Key: copyType(t.Key), Value: copyType(t.Value)}
+		case *Function:
+			params := make([]Type, len(t.Params))
+			for i, p := range t.Params {
+				params[i] = copyType(p)
+			}
+			return &Function{Params: params, Result: copyType(t.Result)}
+		default:
+			return t
+		}
+	}
+	return copyType(s.t)
+}
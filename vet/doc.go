@@ -0,0 +1,34 @@
+// Package vet examines Monkey programs for constructs that are likely to be
+// mistakes, although the programs run:
+//  for _, d := range vet.Check(program, vet.Rules) {
+//  	fmt.Println(d) // e.g. "3:5: unused: total declared and not used"
+//  }
+//
+// Each rule checks for one kind of construct:
+//
+// resolve reports identifiers that refer to no variable and let statements
+// binding names already bound, as found by package resolve.
+//
+// unused reports variables bound by let statements that are never used.
+// Variables whose names start with an underscore, and tests, globals whose
+// names start with "test_", aren't reported.
+//
+// unreachable reports statements following a return statement in the same
+// block.
+//
+// compare reports comparisons whose results are known without running the
+// program, because they compare an expression with itself, as in "x == x", or
+// two literals, as in "1 < 2", and comparisons with < or > that always fail,
+// because an operand isn't an integer, as in "x < true".
+//
+// assign reports let statements binding a variable to itself, as in
+// "let x = x;".
+//
+// noeffect reports expression statements whose values are discarded and whose
+// evaluation has no effect other than possibly failing, such as "5;" or
+// "-a;". The last statement of a block isn't reported, since its value may be
+// that of the block.
+//
+// types reports type mismatches, such as "1 + true", as found by package
+// typecheck, which also checks the type annotations of the program.
+package vet
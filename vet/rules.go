@@ -0,0 +1,241 @@
+package vet
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/resolve"
+	"github.com/adamvinueza/monkey/typecheck"
+)
+
+// checkResolve reports the errors found by package resolve.
+func checkResolve(p *pass) {
+	for _, err := range p.errs {
+		p.reportf(err.Pos, "%s", err.Message)
+	}
+}
+
+// checkUnused reports the variables bound by let statements that are never
+// used, other than those whose names start with an underscore and tests,
+// globals whose names start with "test_", which monkey test calls.
+func checkUnused(p *pass) {
+	for _, decl := range p.info.Declarations {
+		switch {
+		case decl.Kind != resolve.Local && decl.Kind != resolve.Global,
+			len(decl.Uses) != 0, strings.HasPrefix(decl.Name, "_"),
+			decl.Kind == resolve.Global &&
+				strings.HasPrefix(decl.Name, testPrefix):
+			continue
+		}
+		p.reportf(decl.Pos(), "%s declared and not used", decl.Name)
+	}
+}
+
+// testPrefix starts the names of the globals bound to tests.
+const testPrefix = "test_"
+
+// checkUnreachable reports the first statement following a return statement
+// in each sequence of statements.
+func checkUnreachable(p *pass) {
+	p.statementLists(func(stmts []ast.Statement) {
+		for i := 0; i+1 < len(stmts); i++ {
+			if _, ok := stmts[i].(*ast.ReturnStatement); ok {
+				p.reportf(ast.Pos(stmts[i+1]), "unreachable code")
+				return
+			}
+		}
+	})
+}
+
+// checkCompare reports comparisons of an expression with itself, and of two
+// literals, and orderings of values that aren't integers, which always fail.
+func checkCompare(p *pass) {
+	inspect(p.program, func(node ast.Node) bool {
+		ie, ok := node.(*ast.InfixExpression)
+		if !ok {
+			return true
+		}
+		switch ie.Operator {
+		case "==", "!=", "<", ">":
+		default:
+			return true
+		}
+		if ie.Operator == "<" || ie.Operator == ">" {
+			for _, operand := range []ast.Expression{ie.Left, ie.Right} {
+				if !mayBeInteger(operand) {
+					p.reportf(ast.Pos(ie),
+						"comparison with %s always fails: %s isn't an integer",
+						ie.Operator, operandString(operand))
+					return true
+				}
+			}
+		}
+		if result, ok := compareLiterals(ie.Operator, ie.Left,
+			ie.Right); ok {
+			p.reportf(ast.Pos(ie), "comparison of literals is always %t", result)
+		} else if pure(ie.Left) && ast.Equal(ie.Left, ie.Right,
+			ast.CompareOptions{IgnorePositions: true, IgnoreComments: true}) {
+			// The operands are the same value, or equal integers,
+			// strings or booleans.
+			p.reportf(ast.Pos(ie), "comparison of %s with itself is always %t",
+				operandString(ie.Left), ie.Operator == "==")
+		}
+		return true
+	})
+}
+
+// mayBeInteger reports whether the value of expr may be an integer, the only
+// values that < and > can compare.
+func mayBeInteger(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.StringLiteral, *ast.Boolean, *ast.ArrayLiteral, *ast.HashLiteral,
+		*ast.FunctionLiteral:
+		return false
+	case *ast.PrefixExpression:
+		return expr.Operator != "!"
+	case *ast.InfixExpression:
+		switch expr.Operator {
+		case "==", "!=", "<", ">":
+			return false
+		}
+	}
+	return true
+}
+
+// operandString returns expr as it is written in reports, with string
+// literals quoted.
+func operandString(expr ast.Expression) string {
+	if s, ok := expr.(*ast.StringLiteral); ok {
+		return strconv.Quote(s.Value)
+	}
+	return expr.String()
+}
+
+// compareLiterals returns the result of comparing two literals of the same
+// type with operator, reporting whether it is known.
+func compareLiterals(operator string, left, right ast.Expression) (bool, bool) {
+	var cmp int
+	switch l := left.(type) {
+	case *ast.IntegerLiteral:
+		r, ok := right.(*ast.IntegerLiteral)
+		if !ok {
+			return false, false
+		}
+		cmp = compareInts(l.Value, r.Value)
+	case *ast.StringLiteral:
+		r, ok := right.(*ast.StringLiteral)
+		if !ok || operator == "<" || operator == ">" {
+			return false, false
+		}
+		cmp = strings.Compare(l.Value, r.Value)
+	case *ast.Boolean:
+		r, ok := right.(*ast.Boolean)
+		if !ok || operator == "<" || operator == ">" {
+			return false, false
+		}
+		if l.Value != r.Value {
+			cmp = 1
+		}
+	default:
+		return false, false
+	}
+	switch operator {
+	case "==":
+		return cmp == 0, true
+	case "!=":
+		return cmp != 0, true
+	case "<":
+		return cmp < 0, true
+	default:
+		return cmp > 0, true
+	}
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// pure reports whether evaluating expr twice gives the same value, and has
+// no effect other than failing. Array, hash and function literals aren't
+// pure, since each evaluation makes a new value.
+func pure(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	case *ast.PrefixExpression:
+		return pure(expr.Right)
+	case *ast.InfixExpression:
+		return pure(expr.Left) && pure(expr.Right)
+	case *ast.IndexExpression:
+		return pure(expr.Left) && pure(expr.Index)
+	}
+	return false
+}
+
+// checkAssign reports let statements binding a name to the value of the
+// variable of the same name.
+func checkAssign(p *pass) {
+	inspect(p.program, func(node ast.Node) bool {
+		let, ok := node.(*ast.LetStatement)
+		if !ok {
+			return true
+		}
+		if ident, ok := let.Value.(*ast.Identifier); ok &&
+			ident.Value == let.Name.Value {
+			p.reportf(ast.Pos(let), "self-assignment of %s", ident.Value)
+		}
+		return true
+	})
+}
+
+// checkNoEffect reports expression statements whose values are discarded and
+// whose evaluation has no effect other than failing. The last statement of a
+// sequence isn't reported, since it may be the sequence's value.
+func checkNoEffect(p *pass) {
+	p.statementLists(func(stmts []ast.Statement) {
+		for i := 0; i+1 < len(stmts); i++ {
+			es, ok := stmts[i].(*ast.ExpressionStatement)
+			if ok && noEffect(es.Expression) {
+				p.reportf(ast.Pos(es), "statement has no effect")
+			}
+		}
+	})
+}
+
+// noEffect reports whether evaluating expr has no effect other than failing.
+func noEffect(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.FunctionLiteral:
+		return true
+	case *ast.ArrayLiteral:
+		for _, el := range expr.Elements {
+			if !noEffect(el) {
+				return false
+			}
+		}
+		return true
+	case *ast.HashLiteral:
+		for _, pair := range expr.Pairs {
+			if !noEffect(pair.Key) || !noEffect(pair.Value) {
+				return false
+			}
+		}
+		return true
+	}
+	return pure(expr)
+}
+
+// checkTypes reports the type mismatches found by package typecheck.
+func checkTypes(p *pass) {
+	_, errs := typecheck.Program(p.program)
+	for _, err := range errs {
+		p.reportf(err.Pos, "%s", err.Message)
+	}
+}
@@ -0,0 +1,165 @@
+package vet
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/resolve"
+	"github.com/adamvinueza/monkey/token"
+)
+
+// Diagnostic is a suspicious construct found by a rule.
+type Diagnostic struct {
+	Pos     token.Position
+	Rule    string // the name of the rule that found it
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Rule, d.Message)
+}
+
+// Rule is a check made on programs.
+type Rule struct {
+	Name string
+	Doc  string // a one-line description of what the rule reports
+	run  func(p *pass)
+}
+
+// Rules holds all the rules, in the order they are described in the package
+// documentation.
+var Rules = []*Rule{
+	{Name: "resolve", Doc: "report undefined names and names bound twice",
+		run: checkResolve},
+	{Name: "unused", Doc: "report variables that are never used",
+		run: checkUnused},
+	{Name: "unreachable", Doc: "report statements following a return statement",
+		run: checkUnreachable},
+	{Name: "compare",
+		Doc: "report comparisons whose results are constant or that always fail",
+		run: checkCompare},
+	{Name: "assign", Doc: "report let statements binding a variable to itself",
+		run: checkAssign},
+	{Name: "noeffect", Doc: "report expression statements without effect",
+		run: checkNoEffect},
+	{Name: "types", Doc: "report type mismatches, as found by package typecheck",
+		run: checkTypes},
+}
+
+// Lookup returns the rule called name.
+func Lookup(name string) (*Rule, bool) {
+	for _, r := range Rules {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// Check checks program with the specified rules, returning what they find
+// sorted by position.
+func Check(program *ast.Program, rules []*Rule) []Diagnostic {
+	info, errs := resolve.Program(program)
+	p := &pass{program: program, info: info, errs: errs}
+	for _, r := range rules {
+		p.rule = r
+		r.run(p)
+	}
+	sort.SliceStable(p.diagnostics, func(i, j int) bool {
+		a, b := p.diagnostics[i].Pos, p.diagnostics[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return p.diagnostics
+}
+
+// pass holds the state of the checking of a program.
+type pass struct {
+	program *ast.Program
+	info    *resolve.Info
+	errs    []*resolve.Error
+	rule    *Rule // the rule being run
+
+	diagnostics []Diagnostic
+}
+
+func (p *pass) reportf(pos token.Position, format string, a ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Pos:     pos,
+		Rule:    p.rule.Name,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// inspect calls visit for node and, if visit returns true, for each of the
+// nodes within it, including those in the bodies of function literals.
+func inspect(node ast.Node, visit func(ast.Node) bool) {
+	if !visit(node) {
+		return
+	}
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			inspect(s, visit)
+		}
+	case *ast.LetStatement:
+		inspect(node.Name, visit)
+		inspect(node.Value, visit)
+	case *ast.ExpressionStatement:
+		inspect(node.Expression, visit)
+	case *ast.ReturnStatement:
+		inspect(node.ReturnValue, visit)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			inspect(s, visit)
+		}
+	case *ast.IfExpression:
+		inspect(node.Condition, visit)
+		inspect(node.Consequence, visit)
+		if node.Alternative != nil {
+			inspect(node.Alternative, visit)
+		}
+	case *ast.PrefixExpression:
+		inspect(node.Right, visit)
+	case *ast.InfixExpression:
+		inspect(node.Left, visit)
+		inspect(node.Right, visit)
+	case *ast.FunctionLiteral:
+		for _, param := range node.Parameters {
+			inspect(param, visit)
+		}
+		inspect(node.Body, visit)
+	case *ast.CallExpression:
+		inspect(node.Function, visit)
+		for _, a := range node.Arguments {
+			inspect(a, visit)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			inspect(el, visit)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			inspect(pair.Key, visit)
+			inspect(pair.Value, visit)
+		}
+	case *ast.IndexExpression:
+		inspect(node.Left, visit)
+		inspect(node.Index, visit)
+	}
+}
+
+// statementLists calls visit for each sequence of statements in the program:
+// its top level, the bodies of its functions and the branches of its if
+// expressions.
+func (p *pass) statementLists(visit func(stmts []ast.Statement)) {
+	inspect(p.program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			visit(node.Statements)
+		case *ast.BlockStatement:
+			visit(node.Statements)
+		}
+		return true
+	})
+}
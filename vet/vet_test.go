@@ -0,0 +1,125 @@
+package vet
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/parser"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule     string
+		input    string
+		expected []string
+	}{
+		{"resolve", "let x = 1;\nputs(y);", []string{
+			"2:6: resolve: identifier not found: y"}},
+		{"resolve", "let x = 1;\nlet x = 2;", []string{
+			"2:5: resolve: x already bound at 1:5"}},
+		{"unused", "let f = fn(a, b) { let c = a; let _d = 1; 2 };\nf(1, 2);",
+			[]string{"1:24: unused: c declared and not used"}},
+		{"unused", "let g = 1; let f = fn() { let h = fn() { h() }; 1 };", []string{
+			"1:5: unused: g declared and not used",
+			"1:16: unused: f declared and not used",
+		}},
+		{"unused", "let f = fn() { let x = 1; fn() { x } };\nputs(f);", nil},
+		{"unused", "let _g = 1; let test_f = fn() { let test_x = 1; 2 };",
+			[]string{"1:37: unused: test_x declared and not used"}},
+		{"unreachable", "let f = fn() {\n  return 1;\n  puts(2);\n  3\n};", []string{
+			"3:3: unreachable: unreachable code"}},
+		{"unreachable", "if (true) { return 1; }\nputs(2);", nil},
+		{"compare", "let x = 1; puts(x == x, x != x, x < x);", []string{
+			"1:17: compare: comparison of x with itself is always true",
+			"1:25: compare: comparison of x with itself is always false",
+			"1:33: compare: comparison of x with itself is always false",
+		}},
+		{"compare", `puts(1 < 2, "a" == "b", true != false, [1][0] == [1][0]);`,
+			[]string{
+				"1:6: compare: comparison of literals is always true",
+				"1:13: compare: comparison of literals is always false",
+				"1:25: compare: comparison of literals is always true",
+			}},
+		{"compare", `let x = 1; puts(x == 1, f() == f(), -x < 1, 1 == true);`, nil},
+		{"compare", `puts("a" == "a", 2 != 2, true == true);`, []string{
+			"1:6: compare: comparison of literals is always true",
+			"1:18: compare: comparison of literals is always false",
+			"1:26: compare: comparison of literals is always true",
+		}},
+		{"compare", `let x = 1; puts("a" < "b", "a" < "a", x > "a", [x] > x);`,
+			[]string{
+				`1:17: compare: comparison with < always fails: "a" isn't an integer`,
+				`1:28: compare: comparison with < always fails: "a" isn't an integer`,
+				`1:39: compare: comparison with > always fails: "a" isn't an integer`,
+				"1:48: compare: comparison with > always fails: [x] isn't an integer",
+			}},
+		{"compare", `let x = 1; puts(true < true, false > true, !x < x, (x == 2) > 1);`,
+			[]string{
+				"1:17: compare: comparison with < always fails: true isn't an integer",
+				"1:30: compare: comparison with > always fails: false isn't an integer",
+				"1:44: compare: comparison with < always fails: (!x) isn't an integer",
+				"1:53: compare: comparison with > always fails: (x == 2) isn't an integer",
+			}},
+		{"assign", "let x = 1;\nlet f = fn() { let x = x; x };", []string{
+			"2:16: assign: self-assignment of x"}},
+		{"noeffect", "let a = 1;\n5;\n-a;\nputs(a);\n[a, 1];\nfn() { a; 1 };\na",
+			[]string{
+				"2:1: noeffect: statement has no effect",
+				"3:1: noeffect: statement has no effect",
+				"5:1: noeffect: statement has no effect",
+				"6:1: noeffect: statement has no effect",
+				"6:8: noeffect: statement has no effect",
+			}},
+		{"noeffect", "let a = [];\n[push(a, 1)];\nif (true) { 1 };\n2", nil},
+		{"types", "let x: string = 1;\nputs(x + true);", []string{
+			"1:17: types: cannot use int as string in let x",
+			"2:8: types: type mismatch: string + bool",
+		}},
+	}
+
+	for _, tt := range tests {
+		rule, ok := Lookup(tt.rule)
+		if !ok {
+			t.Fatalf("no rule %q", tt.rule)
+		}
+		var found []string
+		for _, d := range Check(parse(tt.input), []*Rule{rule}) {
+			found = append(found, d.String())
+		}
+		if fmt.Sprint(found) != fmt.Sprint(tt.expected) {
+			t.Errorf("wrong diagnostics for %q.\nexpected=%q\nfound=%q", tt.input,
+				tt.expected, found)
+		}
+	}
+}
+
+func TestCheckSortsDiagnostics(t *testing.T) {
+	input := `let f = fn() {
+  let x = x;
+  return 1;
+  x == x;
+};`
+	expected := []string{
+		"1:5: unused: f declared and not used",
+		"2:3: assign: self-assignment of x",
+		"2:11: resolve: x used before it is bound at 2:7",
+		"4:3: unreachable: unreachable code",
+		"4:3: compare: comparison of x with itself is always true",
+	}
+
+	var found []string
+	for _, d := range Check(parse(input), Rules) {
+		found = append(found, d.String())
+	}
+	if fmt.Sprint(found) != fmt.Sprint(expected) {
+		t.Errorf("wrong diagnostics.\nexpected=%q\nfound=%q", expected, found)
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
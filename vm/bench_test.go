@@ -0,0 +1,59 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/adamvinueza/monkey/compiler"
+)
+
+// benchmarks are programs to time the virtual machine with.
+var benchmarks = []struct {
+	name  string
+	input string
+}{
+	{"fib", `
+let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) };
+fib(20)`},
+	{"loop", `
+let count = fn(n, acc) {
+  if (n == 0) { return acc; }
+  if (n / 2 * 2 == n) { count(n - 1, acc + 2) } else { count(n - 1, acc + 1) }
+};
+count(100000, 0)`},
+	{"map", `
+let map = fn(arr, f) {
+  let iter = fn(arr, acc) {
+    if (len(arr) == 0) { acc } else { iter(rest(arr), push(acc, f(first(arr)))) }
+  };
+  iter(arr, [])
+};
+let double = fn(x) { x * 2 };
+let go = fn(n) { if (n == 0) { return 0; } map([1, 2, 3, 4, 5, 6, 7, 8], double); go(n - 1) };
+go(5000)`},
+}
+
+// BenchmarkPeephole times programs as compiled and as optimized by
+// compiler.Peephole.
+func BenchmarkPeephole(b *testing.B) {
+	for _, bm := range benchmarks {
+		c := compiler.New()
+		if err := c.Compile(parse(bm.input)); err != nil {
+			b.Fatalf("compiler error: %s", err)
+		}
+		bytecode := c.Bytecode()
+		b.Run(bm.name+"/compiled", func(b *testing.B) {
+			runBenchmark(b, bytecode)
+		})
+		b.Run(bm.name+"/peephole", func(b *testing.B) {
+			runBenchmark(b, compiler.Peephole(bytecode))
+		})
+	}
+}
+
+func runBenchmark(b *testing.B, bytecode *compiler.Bytecode) {
+	for n := 0; n < b.N; n++ {
+		if err := New(bytecode).Run(); err != nil {
+			b.Fatalf("vm error: %s", err)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Package vm executes Monkey programs compiled to bytecode by package
+// compiler, with the same results as package evaluator, but faster.
+//
+// To use, compile a program and run the bytecode:
+//  c := compiler.New()
+//  if err := c.Compile(program); err != nil {
+//      log.Fatal(err)
+//  }
+//  machine := vm.New(c.Bytecode())
+//  if err := machine.Run(); err != nil {
+//      log.Fatal(err)
+//  }
+//  fmt.Println(machine.Result().Inspect())
+//
+// The virtual machine is a stack machine. Each function call has a frame
+// holding its local variables, which are shared with the closures created
+// during the call, so that the closures see later assignments to them, as in
+// the evaluator. Calls in tail position reuse the caller's frame.
+package vm
@@ -0,0 +1,595 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/adamvinueza/monkey/code"
+	"github.com/adamvinueza/monkey/compiler"
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/object"
+)
+
+// initialStackSize is the number of values the stack can hold before it has
+// to grow.
+const initialStackSize = 2048
+
+// MaxDepth is the maximum depth of function calls, the same as the
+// evaluator's default.
+const MaxDepth = evaluator.DefaultMaxDepth
+
+// The virtual machine shares these values with the evaluator's builtins.
+var (
+	True  = evaluator.TRUE
+	False = evaluator.FALSE
+	Null  = evaluator.NULL
+)
+
+// RuntimeError is the error returned when running a program fails, such as by
+// applying an operator to values of the wrong type. Its message is the same
+// as that of the evaluator's error in the same circumstances.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Err.Pos.IsValid() {
+		return e.Err.Pos.String() + ": " + e.Err.Message
+	}
+	return e.Err.Message
+}
+
+func newError(format string, a ...interface{}) error {
+	return &RuntimeError{Err: &object.Error{Message: fmt.Sprintf(format, a...)}}
+}
+
+// VM runs a compiled program.
+type VM struct {
+	// Sandbox restricts the builtins the program may use, as in the
+	// evaluator, if it is set before the program runs.
+	Sandbox *evaluator.Sandbox
+
+	constants    []object.Object
+	globals      []object.Object
+	globalNames  []string
+	builtins     []*object.Builtin
+	builtinNames []string
+
+	stack []object.Object
+	sp    int // the top of the stack is stack[sp-1]
+
+	frames []frame
+	fp     int // the current frame is frames[fp]
+
+	result object.Object
+}
+
+// frame holds the state of a function call.
+type frame struct {
+	cl          *object.Closure
+	ip          int
+	locals      *object.Locals // nil for the top level of the program
+	basePointer int            // the stack pointer before the call
+
+	// caller is the function that made the call, and callIP the offset of
+	// the call instruction in it, for stack traces.
+	caller *object.CompiledFunction
+	callIP int
+}
+
+// New returns a virtual machine that runs bytecode. The builtins the program
+// uses are looked up now.
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Lines:        bytecode.Lines,
+	}
+
+	builtins := make([]*object.Builtin, len(bytecode.Builtins))
+	for i, name := range bytecode.Builtins {
+		builtins[i], _ = evaluator.LookupBuiltin(name)
+	}
+
+	frames := make([]frame, 1, 16)
+	frames[0] = frame{cl: &object.Closure{Fn: mainFn}, ip: -1}
+
+	return &VM{
+		constants:    bytecode.Constants,
+		globals:      make([]object.Object, len(bytecode.Globals)),
+		globalNames:  bytecode.Globals,
+		builtins:     builtins,
+		builtinNames: bytecode.Builtins,
+		stack:        make([]object.Object, initialStackSize),
+		frames:       frames,
+	}
+}
+
+// Result returns the value of the program, once it has run: that of the last
+// statement, if it is an expression statement, or of the top-level return
+// statement that ended it. Otherwise, the result is nil.
+func (vm *VM) Result() object.Object {
+	return vm.result
+}
+
+// Run runs the program. If the program fails, the error is a *RuntimeError,
+// positioned at the instruction that failed, with a trace of the active
+// function calls.
+func (vm *VM) Run() error {
+	err := vm.run()
+	if rerr, ok := err.(*RuntimeError); ok {
+		vm.addTrace(rerr.Err)
+	}
+	return err
+}
+
+// addTrace gives err the position of the current instruction, unless it has a
+// position already, and the stack of active function calls.
+func (vm *VM) addTrace(err *object.Error) {
+	f := &vm.frames[vm.fp]
+	if !err.Pos.IsValid() {
+		err.Pos = f.cl.Fn.Lines.Pos(f.ip)
+	}
+	for fp := vm.fp; fp > 0; fp-- {
+		f := &vm.frames[fp]
+		name := f.cl.Fn.Name
+		if name == "" {
+			name = "fn"
+		}
+		err.Stack = append(err.Stack, object.Frame{
+			Function: name,
+			CallPos:  f.caller.Lines.Pos(f.callIP),
+		})
+	}
+}
+
+func (vm *VM) run() error {
+	for {
+		f := &vm.frames[vm.fp]
+		f.ip++
+		ins := f.cl.Fn.Instructions
+		ip := f.ip
+		op := code.Opcode(ins[ip])
+
+		var err error
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			f.ip += 2
+			vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpEqual,
+			code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
+			err = vm.executeBinaryOperation(op)
+
+		case code.OpMinus:
+			operand := vm.pop()
+			integer, ok := operand.(*object.Integer)
+			if !ok {
+				return newError("unknown operator: -%s", operand.Type())
+			}
+			vm.push(&object.Integer{Value: -integer.Value})
+
+		case code.OpBang:
+			vm.push(nativeBoolToBooleanObject(!isTruthy(vm.pop())))
+
+		case code.OpTrue:
+			vm.push(True)
+
+		case code.OpFalse:
+			vm.push(False)
+
+		case code.OpNull:
+			vm.push(Null)
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			f.ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			f.ip += 2
+			if !isTruthy(vm.pop()) {
+				f.ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			f.ip += 2
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			f.ip += 2
+			value := vm.globals[globalIndex]
+			if value == nil {
+				value, err = vm.lookup(vm.globalNames[globalIndex], nil)
+				if err != nil {
+					return err
+				}
+			}
+			vm.push(value)
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			f.ip++
+			f.locals.Values[localIndex] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			f.ip++
+			value := f.locals.Values[localIndex]
+			if value == nil {
+				value, err = vm.lookup(f.locals.Fn.Locals[localIndex], f.cl.Free)
+				if err != nil {
+					return err
+				}
+			}
+			vm.push(value)
+
+		case code.OpGetFree:
+			depth := code.ReadUint8(ins[ip+1:])
+			localIndex := code.ReadUint8(ins[ip+2:])
+			f.ip += 2
+			locals := f.cl.Free[depth]
+			value := locals.Values[localIndex]
+			if value == nil {
+				value, err = vm.lookup(locals.Fn.Locals[localIndex],
+					f.cl.Free[depth+1:])
+				if err != nil {
+					return err
+				}
+			}
+			vm.push(value)
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			f.ip++
+			builtin := vm.builtins[builtinIndex]
+			if builtin == nil {
+				return vm.identifierNotFound(vm.builtinNames[builtinIndex])
+			}
+			if err := vm.Sandbox.Check(builtin); err != nil {
+				return &RuntimeError{Err: err}
+			}
+			vm.push(builtin)
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			f.ip += 2
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+			vm.push(&object.Array{Elements: elements})
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			f.ip += 2
+			var hash object.Object
+			hash, err = vm.buildHash(vm.sp-numElements, vm.sp)
+			if err == nil {
+				vm.sp -= numElements
+				vm.push(hash)
+			}
+
+		case code.OpDup:
+			vm.push(vm.stack[vm.sp-1])
+
+		case code.OpHashKey:
+			key := vm.stack[vm.sp-1]
+			if _, ok := key.(object.Hashable); !ok {
+				err = newError("unusable as hash key: %s", key.Type())
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.executeIndexExpression(left, index)
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			f.ip += 2
+			vm.pushClosure(f, vm.constants[constIndex].(*object.CompiledFunction))
+
+		case code.OpCall, code.OpTailCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			f.ip++
+			err = vm.executeCall(numArgs, op == code.OpTailCall)
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.fp == 0 {
+				vm.result = returnValue
+				return nil
+			}
+			vm.sp = f.basePointer
+			vm.fp--
+			vm.push(returnValue)
+
+		case code.OpReturn:
+			if vm.fp == 0 {
+				vm.result = nil
+				return nil
+			}
+			vm.sp = f.basePointer
+			vm.fp--
+			vm.push(Null)
+
+		default:
+			return fmt.Errorf("unknown opcode %d", op)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// lookup returns the value of name when the variable the compiler resolved it
+// to hasn't been assigned, because the let statement binding it hasn't run, as
+// when it is in a branch not taken. As in the evaluator, the value is that of
+// the innermost variable called name that has been assigned, among the locals
+// of the calls in free, innermost first, and the globals, or else the builtin
+// called name.
+func (vm *VM) lookup(name string,
+	free []*object.Locals) (object.Object, error) {
+	for _, locals := range free {
+		for i := len(locals.Values) - 1; i >= 0; i-- {
+			if locals.Fn.Locals[i] == name && locals.Values[i] != nil {
+				return locals.Values[i], nil
+			}
+		}
+	}
+	for i, global := range vm.globalNames {
+		if global == name && vm.globals[i] != nil {
+			return vm.globals[i], nil
+		}
+	}
+	if builtin, ok := evaluator.LookupBuiltin(name); ok {
+		if err := vm.Sandbox.Check(builtin); err != nil {
+			return nil, &RuntimeError{Err: err}
+		}
+		return builtin, nil
+	}
+	return nil, vm.identifierNotFound(name)
+}
+
+func (vm *VM) identifierNotFound(name string) error {
+	return newError("identifier not found: %s", name)
+}
+
+func (vm *VM) push(o object.Object) {
+	if vm.sp >= len(vm.stack) {
+		stack := make([]object.Object, 2*len(vm.stack))
+		copy(stack, vm.stack)
+		vm.stack = stack
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// pushClosure pushes a closure of fn created in the call of f.
+func (vm *VM) pushClosure(f *frame, fn *object.CompiledFunction) {
+	var free []*object.Locals
+	if f.locals != nil {
+		free = make([]*object.Locals, 1+len(f.cl.Free))
+		free[0] = f.locals
+		copy(free[1:], f.cl.Free)
+	}
+	vm.push(&object.Closure{Fn: fn, Free: free})
+}
+
+// executeCall calls the function below the numArgs arguments on the top of the
+// stack. If tail is true, the call replaces the current one.
+func (vm *VM) executeCall(numArgs int, tail bool) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs, tail)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return newError("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int, tail bool) error {
+	if numArgs != cl.Fn.NumParameters {
+		return newError("wrong number of arguments: expected %d, found %d",
+			cl.Fn.NumParameters, numArgs)
+	}
+
+	locals := &object.Locals{
+		Fn:     cl.Fn,
+		Values: make([]object.Object, len(cl.Fn.Locals)),
+	}
+	copy(locals.Values, vm.stack[vm.sp-numArgs:vm.sp])
+	basePointer := vm.sp - 1 - numArgs
+
+	// A tail call's frame replaces its caller's, but, as in the evaluator,
+	// the position of the call is that of the tail call.
+	caller := vm.frames[vm.fp].cl.Fn
+	callIP := vm.frames[vm.fp].ip
+	if tail {
+		basePointer = vm.frames[vm.fp].basePointer
+	} else {
+		if vm.fp >= MaxDepth {
+			return newError("maximum call depth (%d) exceeded", MaxDepth)
+		}
+		vm.fp++
+		if vm.fp == len(vm.frames) {
+			vm.frames = append(vm.frames, frame{})
+		}
+	}
+	vm.frames[vm.fp] = frame{cl: cl, ip: -1, locals: locals,
+		basePointer: basePointer, caller: caller, callIP: callIP}
+	vm.sp = basePointer
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result := builtin.Fn(args...)
+	if err, ok := result.(*object.Error); ok {
+		return &RuntimeError{Err: err}
+	}
+	if result == nil {
+		result = Null
+	}
+
+	vm.sp = vm.sp - 1 - numArgs
+	vm.push(result)
+	return nil
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, newError("unusable as hash key: %s", key.Type())
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.Hash{Pairs: pairs}, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		i := index.(*object.Integer).Value
+		if i < 0 || i >= int64(len(elements)) {
+			vm.push(Null)
+		} else {
+			vm.push(elements[i])
+		}
+		return nil
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		pair, ok := left.(*object.Hash).Pairs[key.HashKey()]
+		if !ok {
+			vm.push(Null)
+		} else {
+			vm.push(pair.Value)
+		}
+		return nil
+	default:
+		return newError("index operator not supported: %s[%s]", left.Type(),
+			index.Type())
+	}
+}
+
+// operators holds the operator each binary opcode applies.
+var operators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return vm.executeIntegerOperation(op, left.(*object.Integer).Value,
+			right.(*object.Integer).Value)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return vm.executeStringOperation(op, left, right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operators[op],
+			right.Type())
+	case op == code.OpEqual:
+		vm.push(nativeBoolToBooleanObject(left == right))
+	case op == code.OpNotEqual:
+		vm.push(nativeBoolToBooleanObject(left != right))
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(),
+			operators[op], right.Type())
+	}
+	return nil
+}
+
+func (vm *VM) executeIntegerOperation(op code.Opcode, left, right int64) error {
+	switch op {
+	case code.OpAdd:
+		vm.push(&object.Integer{Value: left + right})
+	case code.OpSub:
+		vm.push(&object.Integer{Value: left - right})
+	case code.OpMul:
+		vm.push(&object.Integer{Value: left * right})
+	case code.OpDiv:
+		if right == 0 {
+			return newError("division by zero: %d / %d", left, right)
+		}
+		vm.push(&object.Integer{Value: left / right})
+	case code.OpEqual:
+		vm.push(nativeBoolToBooleanObject(left == right))
+	case code.OpNotEqual:
+		vm.push(nativeBoolToBooleanObject(left != right))
+	case code.OpGreaterThan:
+		vm.push(nativeBoolToBooleanObject(left > right))
+	case code.OpLessThan:
+		vm.push(nativeBoolToBooleanObject(left < right))
+	}
+	return nil
+}
+
+func (vm *VM) executeStringOperation(op code.Opcode,
+	left, right object.Object) error {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch op {
+	case code.OpAdd:
+		vm.push(&object.String{Value: leftVal + rightVal})
+	case code.OpEqual:
+		vm.push(nativeBoolToBooleanObject(leftVal == rightVal))
+	case code.OpNotEqual:
+		vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(),
+			operators[op], right.Type())
+	}
+	return nil
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case Null, False:
+		return false
+	default:
+		return true
+	}
+}
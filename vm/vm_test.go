@@ -0,0 +1,519 @@
+package vm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/adamvinueza/monkey/ast"
+	"github.com/adamvinueza/monkey/compiler"
+	"github.com/adamvinueza/monkey/evaluator"
+	"github.com/adamvinueza/monkey/lexer"
+	"github.com/adamvinueza/monkey/object"
+	"github.com/adamvinueza/monkey/parser"
+	"github.com/adamvinueza/monkey/token"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"1 - 2", -1},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"true == true", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"!true", false},
+		{"!5", false},
+		{"!!5", true},
+		{"!(if (false) { 5; })", true},
+		{`"a" == "a"`, true},
+		{`"a" != "b"`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (true) { 10 } else { 20 }", 10},
+		{"if (false) { 10 } else { 20 } ", 20},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", Null},
+		{"if (false) { 10 }", Null},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { let x = 1; }", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let one = 1; let one = one + 1; one", 2},
+		{"let x = 1;", nil},
+		{"if (true) { let x = 5; }; x", 5},
+		{"let len = fn(x) { 42 }; len([])", 42},
+		{"let a = len([1]); let len = fn(x) { 42 }; a", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringsArraysAndHashes(t *testing.T) {
+	tests := []vmTestCase{
+		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{"[1 + 2, 3 * 4]", []int{3, 12}},
+		{"[][0]", Null},
+		{"[1, 2, 3][1 + 1]", 3},
+		{"[1, 2, 3][3]", Null},
+		{"[1][-1]", Null},
+		{"{1: 2, 2: 3}[1]", 2},
+		{"{1: 1}[0]", Null},
+		{`{"a": 1, true: 2}[true]`, 2},
+		{`{"b": 1, "a": 2}`, `{"a": 2, "b": 1}`},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn() { 5 + 10; }; f();", 15},
+		{"let f = fn() { return 99; 100; }; f();", 99},
+		{"let f = fn() { }; f();", Null},
+		{"let f = fn(a, b) { a + b }; f(1, 2)", 3},
+		{"let f = fn(x, x) { x }; f(1, 2)", 2},
+		{"let f = fn() { if (true) { return 1; } 2 }; f()", 1},
+		{"let f = fn() { let x = 1; let y = x + 1; x + y }; f()", 3},
+		{"let g = 10; let f = fn(a) { let g = a + g; g }; f(1) + f(2)", 23},
+		{"return 1; 2", 1},
+		{"fn(x) { x }(3)", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{"let newAdder = fn(a) { fn(b) { a + b } }; newAdder(2)(3)", 5},
+		{`let newAdder = fn(a, b) {
+  let c = a + b;
+  fn(d) { let e = d + c; fn(f) { e + f } }
+};
+newAdder(1, 2)(3)(8)`, 14},
+		// Closures share the variables they refer to with their enclosing
+		// call.
+		{"let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() }; f()",
+			2},
+		// A variable whose let statement hasn't run, as in a branch not
+		// taken, is skipped for the enclosing variables and builtins.
+		{"let x = 1; let f = fn() { if (false) { let x = 2; }; x }; f()", 1},
+		{`let f = fn(x) {
+  let g = fn() { if (false) { let x = 3; }; x };
+  if (false) { let x = 4; };
+  g()
+};
+f(5)`, 5},
+		{"if (false) { let len = 1; }; len([1, 2])", 2},
+		// Functions can refer to functions bound after them.
+		{"let f = fn() { g() }; let g = fn() { 7 }; f()", 7},
+		{`let f = fn() {
+  let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+  let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+  isEven(10)
+};
+f()`, true},
+		{`let countDown = fn(x) { if (x == 0) { 0 } else { countDown(x - 1) } };
+countDown(1)`, 0},
+		{`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(15)`, 610},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{"let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } };\n" +
+			"loop(1000000)", 0},
+		{"let sum = fn(n, acc) { if (n == 0) { return acc; } sum(n - 1, acc + n) };\n" +
+			"sum(100000, 0)", 5000050000},
+		{"let f = fn(n) { if (n == 0) { 1 } else { 2 * f(n - 1) } }; f(10)",
+			1024},
+		{"let f = fn() { len([1, 2]) }; f()", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("hello world")`, 11},
+		{`len([1, 2, 3])`, 3},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, Null},
+		{`last([1, 2, 3])`, 3},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`push([], 1)`, []int{1}},
+		{`let map = fn(arr, f) {
+  let iter = fn(arr, acc) {
+    if (len(arr) == 0) { acc } else { iter(rest(arr), push(acc, f(first(arr)))) }
+  };
+  iter(arr, []);
+};
+map([1, 2, 3], fn(x) { x * 2 })`, []int{2, 4, 6}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"1 == true", "type mismatch: INTEGER == BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"true < false;", "unknown operator: BOOLEAN < BOOLEAN"},
+		{`"a" - "b"`, "unknown operator: STRING - STRING"},
+		{"1 / 0", "division by zero: 1 / 0"},
+		{"foobar", "identifier not found: foobar"},
+		{"let f = fn() { x }; let x = f(); 1", "identifier not found: x"},
+		{"let f = fn() { let g = fn() { y }; g(); let y = 1; }; f()",
+			"identifier not found: y"},
+		{"1(2)", "not a function: INTEGER"},
+		{"fn(x) { x }()", "wrong number of arguments: expected 1, found 0"},
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{"1[0]", "index operator not supported: INTEGER[INTEGER]"},
+		{`len(1)`, "argument 1 to `len` must be ARRAY or STRING, found INTEGER"},
+		{`len("one", "two")`,
+			"wrong number of arguments to `len`: expected 1, found 2"},
+		{"let f = fn() { 1 + f() }; f()", "maximum call depth (10000) exceeded"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil {
+			t.Errorf("expected error %q for %q, found none", tt.expectedMessage,
+				tt.input)
+			continue
+		}
+		rerr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Errorf("error is not *RuntimeError, found %T", err)
+			continue
+		}
+		if rerr.Err.Message != tt.expectedMessage {
+			t.Errorf("wrong error message for %q. expected=%q, found=%q",
+				tt.input, tt.expectedMessage, rerr.Err.Message)
+		}
+	}
+}
+
+func TestRuntimeErrorTraces(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedPos   token.Position
+		expectedStack []object.Frame
+	}{
+		{"5 + true;", token.Position{Line: 1, Column: 1}, nil},
+		{"let x = 1;\nlet y = x * -true;", token.Position{Line: 2, Column: 13},
+			nil},
+		{"if (true) {\n  foobar\n}", token.Position{Line: 2, Column: 3}, nil},
+		{"let f = fn(x) { x };\n f(1, 2)", token.Position{Line: 2, Column: 2},
+			nil},
+		{"[1, 2 / 0]", token.Position{Line: 1, Column: 5}, nil},
+		{"len(1)", token.Position{Line: 1, Column: 1}, nil},
+		{`let divide = fn(a, b) {
+  a / b
+};
+let compute = fn(x) {
+  let half = fn(y) { divide(y, 0) };
+  1 + half(x)
+};
+let alias = compute;
+alias(10);
+`, token.Position{Line: 2, Column: 3}, []object.Frame{
+			{Function: "divide", CallPos: token.Position{Line: 5, Column: 22}},
+			{Function: "compute", CallPos: token.Position{Line: 9, Column: 1}},
+		}},
+		{"fn() { -true }()", token.Position{Line: 1, Column: 8}, []object.Frame{
+			{Function: "fn", CallPos: token.Position{Line: 1, Column: 1}},
+		}},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		err := New(comp.Bytecode()).Run()
+		rerr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Errorf("expected *RuntimeError for %q, found %T (%v)", tt.input,
+				err, err)
+			continue
+		}
+		if rerr.Err.Pos != tt.expectedPos {
+			t.Errorf("wrong error position for %q. expected=%s, found=%s",
+				tt.input, tt.expectedPos, rerr.Err.Pos)
+		}
+		if fmt.Sprint(rerr.Err.Stack) != fmt.Sprint(tt.expectedStack) {
+			t.Errorf("wrong stack for %q. expected=%v, found=%v", tt.input,
+				tt.expectedStack, rerr.Err.Stack)
+		}
+	}
+}
+
+// TestEvaluatorParity checks that the virtual machine and the evaluator agree
+// on the values of programs, and on the messages and positions of errors.
+func TestEvaluatorParity(t *testing.T) {
+	inputs := []string{
+		"let a = [1, 2, 3]; let b = push(a, 4); [a, b, rest(b)]",
+		`let h = {"one": 1, "two": 2}; [h["one"], h["three"], len("two")]`,
+		"let f = fn(x) { if (x > 10) { return x; } f(x * 2) }; f(1)",
+		"let x = 1; let f = fn() { x }; let x = 2; f()",
+		"let f = fn(g) { g(g) }; f(fn(h) { 5 })",
+		"if (0) { 1 } else { 2 }",
+		"let x = if (false) { 1 }; x",
+		`"a" + 1`,
+		"[1, 2][true]",
+		"let f = fn(a, b) { a / b }; f(1, 0)",
+		"{1: 2, [3]: 4 / 0}",
+		"let x = 1; let f = fn() { if (false) { let x = 2; }; x }; f()",
+		"let f = fn() { if (false) { let y = 2; }; y }; f()",
+	}
+
+	for _, input := range inputs {
+		expected := evaluator.Eval(parse(input), object.NewEnvironment())
+
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+		var found string
+		if err := vm.Run(); err != nil {
+			found = err.(*RuntimeError).Err.Inspect()
+		} else {
+			found = vm.Result().Inspect()
+		}
+
+		if found != expected.Inspect() {
+			t.Errorf("wrong result for %q. evaluator=%q, vm=%q", input,
+				expected.Inspect(), found)
+		}
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	sandbox := &evaluator.Sandbox{Allow: []string{"core"}}
+	tests := []struct {
+		input           string
+		expectedMessage string // empty if the program should run
+	}{
+		{`len([1])`, ""},
+		{`puts(1)`, "permission denied: io.puts is not allowed"},
+		{`let call = fn(f) { f("/etc/passwd") }; call(readFile)`,
+			"permission denied: io.readFile is not allowed"},
+		// A global not yet bound falls back to the builtin of the same name.
+		{`let f = fn() { readFile("/etc/passwd") }; f(); let readFile = 1;`,
+			"permission denied: io.readFile is not allowed"},
+		{`let puts = fn(x) { x }; puts(1)`, ""},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		machine := New(comp.Bytecode())
+		machine.Sandbox = sandbox
+		err := machine.Run()
+		if tt.expectedMessage == "" {
+			if err != nil {
+				t.Errorf("unexpected error for %q: %s", tt.input, err)
+			}
+			continue
+		}
+		rerr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Errorf("expected error %q for %q, found %v", tt.expectedMessage,
+				tt.input, err)
+			continue
+		}
+		if rerr.Err.Message != tt.expectedMessage {
+			t.Errorf("wrong error message for %q. expected=%q, found=%q",
+				tt.input, tt.expectedMessage, rerr.Err.Message)
+		}
+	}
+}
+
+// TestDepthLimitTrace checks that the virtual machine and the evaluator give
+// the same trace when calls are nested too deeply.
+func TestDepthLimitTrace(t *testing.T) {
+	input := "let f = fn(n) {\n  1 + f(n + 1)\n};\nf(0)"
+	expected, ok := evaluator.Eval(parse(input),
+		object.NewEnvironment()).(*object.Error)
+	if !ok {
+		t.Fatalf("evaluator didn't fail")
+	}
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	rerr, ok := New(comp.Bytecode()).Run().(*RuntimeError)
+	if !ok {
+		t.Fatalf("vm didn't fail")
+	}
+	if len(rerr.Err.Stack) != MaxDepth {
+		t.Errorf("wrong stack depth. expected=%d, found=%d", MaxDepth,
+			len(rerr.Err.Stack))
+	}
+	if found := rerr.Err.Trace(""); found != expected.Trace("") {
+		t.Errorf("wrong trace. evaluator=%q, vm=%q", expected.Trace(""), found)
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		testExpectedObject(t, tt.input, tt.expected, vm.Result())
+	}
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{},
+	actual object.Object) {
+	t.Helper()
+
+	var err error
+	switch expected := expected.(type) {
+	case int:
+		err = testIntegerObject(int64(expected), actual)
+	case bool:
+		err = testBooleanObject(expected, actual)
+	case string:
+		switch actual := actual.(type) {
+		case *object.String:
+			if actual.Value != expected {
+				err = fmt.Errorf("wrong value. expected=%q, found=%q",
+					expected, actual.Value)
+			}
+		default:
+			if actual == nil || actual.Inspect() != expected {
+				err = fmt.Errorf("wrong value. expected=%s, found=%v",
+					expected, actual)
+			}
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			err = fmt.Errorf("object not Array: %T (%+v)", actual, actual)
+			break
+		}
+		if len(array.Elements) != len(expected) {
+			err = fmt.Errorf("wrong num of elements. expected=%d, found=%d",
+				len(expected), len(array.Elements))
+			break
+		}
+		for i, expectedElem := range expected {
+			if err = testIntegerObject(int64(expectedElem),
+				array.Elements[i]); err != nil {
+				break
+			}
+		}
+	case *object.Null:
+		if actual != Null {
+			err = fmt.Errorf("object is not Null: %T (%+v)", actual, actual)
+		}
+	case nil:
+		if actual != nil {
+			err = fmt.Errorf("expected no value, found %T (%+v)", actual, actual)
+		}
+	}
+	if err != nil {
+		t.Errorf("wrong result for %q: %s", input, err)
+	}
+}
+
+func testIntegerObject(expected int64, actual object.Object) error {
+	result, ok := actual.(*object.Integer)
+	if !ok {
+		return fmt.Errorf("object is not Integer. found=%T (%+v)", actual,
+			actual)
+	}
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. expected=%d, found=%d",
+			expected, result.Value)
+	}
+	return nil
+}
+
+func testBooleanObject(expected bool, actual object.Object) error {
+	result, ok := actual.(*object.Boolean)
+	if !ok {
+		return fmt.Errorf("object is not Boolean. found=%T (%+v)", actual,
+			actual)
+	}
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. expected=%t, found=%t",
+			expected, result.Value)
+	}
+	return nil
+}